@@ -57,6 +57,7 @@ func main() {
 	// event handlers that push data to ui
 	gmMux.HandleFunc("/portfolioUpdateEvent", handlers.MakeHandler(handlers.PortfolioUpdateEvent, tdSession))
 	gmMux.HandleFunc("/orderUpdateEvent", handlers.MakeHandler(handlers.OrderUpdateEvent, tdSession))
+	gmMux.HandleFunc("/quoteUpdateEvent", handlers.MakeHandler(handlers.QuoteUpdateEvent, tdSession))
 	gmMux.HandleFunc("/optionUpdateEvent", handlers.MakeHandler(handlers.OptionUpdateEvent, tdSession))
 
 	// "under the covers" api
@@ -64,6 +65,7 @@ func main() {
 
 	// test phase
 	gmMux.HandleFunc("/testOrderHandler", handlers.MakeHandler(handlers.TestOrderHandler, tdSession))
+	gmMux.HandleFunc("/testPreviewOrderHandler", handlers.MakeHandler(handlers.TestPreviewOrderHandler, tdSession))
 	gmMux.HandleFunc("/testCancelOrderHandler", handlers.MakeHandler(handlers.TestCancelOrderHandler, tdSession))
 
 	//file handler
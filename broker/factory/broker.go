@@ -20,8 +20,17 @@
 package factory
 
 import (
+	"log"
+
 	"github.com/marklaczynski/acidbath/broker/generic"
+	"github.com/marklaczynski/acidbath/broker/journal"
+	"github.com/marklaczynski/acidbath/broker/risk"
 	"github.com/marklaczynski/acidbath/broker/tdapi"
+	"github.com/marklaczynski/acidbath/lib/mjlog"
+)
+
+var (
+	logError = log.New(mjlog.CreateErrorFile(), "ERROR [factory]: ", log.LstdFlags|log.Lshortfile)
 )
 
 //BrokerType is an enumeration of available brokers
@@ -32,12 +41,19 @@ const (
 	TD BrokerType = iota
 )
 
-//CreateBroker returns a concrete instance of generic.Broker interface, based on the BrokerType
+//CreateBroker returns a concrete instance of generic.Broker interface, based on the BrokerType.
+//Orders sent through the returned broker are journaled, and are subject to the pre-trade risk checks
 func CreateBroker(b BrokerType) generic.Broker {
 	switch b {
 
 	case TD:
-		return tdapi.New()
+		checked := risk.New(tdapi.New(), risk.ConfiguredLimits())
+		journaled, err := journal.New(checked, journal.DefaultPath())
+		if err != nil {
+			logError.Printf("Unable to open order journal, orders will not be journaled: %s\n", err)
+			return checked
+		}
+		return journaled
 	}
 
 	return nil
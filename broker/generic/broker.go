@@ -21,6 +21,7 @@ package generic
 
 import (
 	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/commission"
 	"github.com/marklaczynski/acidbath/dm/optionchain/option"
 	"github.com/marklaczynski/acidbath/dm/order"
 	"github.com/marklaczynski/acidbath/dm/orderbook"
@@ -43,13 +44,18 @@ type Broker interface {
 	RetrieveImpliedVolatilityHistory(stockSymbol string, stock *asset.Stock) error
 	RetrievePriceHistory(stockSymbol string, stock *asset.Stock) error
 	RetrievePortfolio(newPortfolio *portfolio.Portfolio) error
+	CachedPortfolio() (*portfolio.Portfolio, bool)
+	SetMarkSource(ms portfolio.MarkSource)
 	AddStockOptionsToStream(stock *asset.Stock) error
 	RemoveStockOptionsFromStream(stock *asset.Stock) error
 	AddOptionToStrategy(opt *option.Option, strategy factory.StrategyType) ([]string, error)
 	RemoveOptionFromStrategy(opt *option.Option, strategy factory.StrategyType) ([]string, error)
 	SendSingleLegOptionTrade(order *order.Order) error
+	PreviewOrder(order *order.Order) (commission.Preview, error)
 	CancelOrder(orderids []string) error
 	RetrieveOrderBook(accountid string, ob *orderbook.OrderBook) error
+	RegisterQuoteUpdateChan(id string) chan *asset.Quote
+	DeregisterQuoteUpdateChan(id string)
 	RegisterOptionUpdateChan(id string) chan *option.Option
 	DeregisterOptionUpdateChan(id string)
 	RegisterPortfolioUpdateChan(id string) chan *portfolio.Portfolio
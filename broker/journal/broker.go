@@ -0,0 +1,205 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package journal
+
+import (
+	"strings"
+
+	"github.com/marklaczynski/acidbath/broker/generic"
+	"github.com/marklaczynski/acidbath/dm/order"
+	"github.com/marklaczynski/acidbath/dm/orderbook"
+	"github.com/marklaczynski/acidbath/dm/ordermessage"
+)
+
+const orderChanID = "journal"
+
+//Broker wraps a generic.Broker and journals every order sent, acknowledged, rejected, cancelled and every order event.
+//All other calls are passed straight through to the wrapped broker.
+//The client order id stays in the journal, TD's order string has no field to carry it, so an order that was sent
+//but never acknowledged can't be matched with the broker and there is no protection against sending it twice
+
+type Broker struct {
+	generic.Broker
+	journal   *Journal
+	recording bool
+}
+
+//New opens the journal at path and returns a Broker that records orders sent to b
+func New(b generic.Broker, path string) (*Broker, error) {
+	j, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Broker{
+		Broker:  b,
+		journal: j,
+	}, nil
+}
+
+//Journal returns the journal used by the broker
+func (b *Broker) Journal() *Journal {
+	return b.journal
+}
+
+//Login logs into the wrapped broker, starts journaling order events and reconciles the journal with the broker's order book
+func (b *Broker) Login(loginid string, pass string) error {
+	if err := b.Broker.Login(loginid, pass); err != nil {
+		return err
+	}
+
+	go b.recordEvents(b.Broker.RegisterOrderUpdateChan(orderChanID))
+	b.recording = true
+
+	if err := b.Reconcile(); err != nil {
+		// the session is usable, the journal just may be stale until the next reconcile
+		logError.Printf("Unable to reconcile journal: %s\n", err)
+	}
+
+	return nil
+}
+
+//Logout stops journaling order events and logs out of the wrapped broker
+func (b *Broker) Logout() error {
+	if b.recording {
+		b.Broker.DeregisterOrderUpdateChan(orderChanID)
+		b.recording = false
+	}
+	return b.Broker.Logout()
+}
+
+//SendSingleLegOptionTrade journals the order before sending it, then journals the broker's ack or rejection
+func (b *Broker) SendSingleLegOptionTrade(o *order.Order) error {
+	if o.ClientOrderID() == "" {
+		o.SetClientOrderID(order.NewClientOrderID())
+	}
+
+	if err := b.journal.Append(orderEntry(SubmitEntry, o, true)); err != nil {
+		// never send an order we could not record
+		logError.Printf("Order %s not sent: %s\n", o.ClientOrderID(), err)
+		return err
+	}
+
+	if err := b.Broker.SendSingleLegOptionTrade(o); err != nil {
+		e := orderEntry(RejectEntry, o, false)
+		e.Reason = err.Error()
+		if jerr := b.journal.Append(e); jerr != nil {
+			logError.Printf("Unable to journal rejection of %s: %s\n", o.ClientOrderID(), jerr)
+		}
+		return err
+	}
+
+	if err := b.journal.Append(orderEntry(AckEntry, o, true)); err != nil {
+		logError.Printf("Unable to journal ack of %s: %s\n", o.ClientOrderID(), err)
+	}
+
+	return nil
+}
+
+//CancelOrder journals the cancel request before passing it to the wrapped broker.
+//The order stays working until the broker reports it out
+func (b *Broker) CancelOrder(orderids []string) error {
+	for _, id := range orderids {
+		e := &Entry{Type: CancelEntry, ClientOrderID: b.journal.ClientOrderID(id), OrderID: id, Working: true}
+		if err := b.journal.Append(e); err != nil {
+			logError.Printf("Unable to journal cancel of %s: %s\n", id, err)
+		}
+	}
+
+	return b.Broker.CancelOrder(orderids)
+}
+
+//Reconcile compares the journal's working orders with the broker's order book. Orders the broker no longer
+//shows as working are closed out, and working orders the journal has never seen are added
+func (b *Broker) Reconcile() error {
+	ob := orderbook.New()
+	if err := b.Broker.RetrieveOrderBook("", ob); err != nil {
+		return err
+	}
+
+	for _, w := range b.journal.WorkingOrders() {
+		if w.OrderID == "" {
+			// submitted but never acknowledged. Without the client order id at the broker it can't be matched, so flag it
+			// for a human once and close it. If it did reach the broker it shows up below as an order the journal hasn't seen
+			logError.Printf("Journal has unacknowledged order %s %s, verify it with the broker\n", w.ClientOrderID, w.Symbol)
+			e := &Entry{Type: ReconcileEntry, ClientOrderID: w.ClientOrderID, Event: "unmatched", Working: false}
+			if err := b.journal.Append(e); err != nil {
+				return err
+			}
+			continue
+		}
+
+		os := ob.OrderStatus(w.OrderID)
+		if os == nil || !isWorkingStatus(os.Status()) {
+			status := "missing"
+			if os != nil {
+				status = os.Status()
+			}
+			e := &Entry{Type: ReconcileEntry, ClientOrderID: w.ClientOrderID, OrderID: w.OrderID, Event: status, Working: false}
+			if err := b.journal.Append(e); err != nil {
+				return err
+			}
+			logInfo.Printf("Reconciled %s (%s) as %s\n", w.ClientOrderID, w.OrderID, status)
+		}
+	}
+
+	for id, os := range ob.OrderStatuses() {
+		if !isWorkingStatus(os.Status()) || b.journal.ClientOrderID(id) != "" {
+			continue
+		}
+		e := &Entry{
+			Type:      ReconcileEntry,
+			OrderID:   id,
+			Symbol:    os.Symbol(),
+			Action:    os.Action().String(),
+			OrderType: os.OrderType().String(),
+			Quantity:  float64(os.Quantity()),
+			Price:     os.Price().Value.FloatString(2),
+			Event:     os.Status(),
+			Working:   true,
+		}
+		if err := b.journal.Append(e); err != nil {
+			return err
+		}
+		logInfo.Printf("Added working order %s from order book\n", id)
+	}
+
+	return nil
+}
+
+func (b *Broker) recordEvents(c chan *ordermessage.Message) {
+	for m := range c {
+		if m.OrderID() == "" {
+			continue
+		}
+
+		if err := b.journal.Append(messageEntry(m, !isTerminal(m))); err != nil {
+			logError.Printf("Unable to journal %s for %s: %s\n", m.OrderEvent(), m.OrderID(), err)
+		}
+	}
+}
+
+//isWorkingStatus returns true for the broker's display statuses of an order that can still execute
+func isWorkingStatus(status string) bool {
+	switch strings.ToLower(status) {
+	case "filled", "canceled", "cancelled", "expired", "rejected", "replaced":
+		return false
+	}
+	return status != ""
+}
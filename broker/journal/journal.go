@@ -0,0 +1,337 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package journal keeps an append-only record of every order sent to the broker, so working orders can be recovered after a restart
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/order"
+	"github.com/marklaczynski/acidbath/dm/ordermessage"
+	"github.com/marklaczynski/acidbath/lib/mjlog"
+	"github.com/marklaczynski/acidbath/lib/orderconst"
+)
+
+var (
+	logInfo  = log.New(mjlog.CreateInfoFile(), "INFO  [journal]: ", log.LstdFlags|log.Lshortfile)
+	logDebug = log.New(mjlog.CreateDebugFile(), "DEBUG [journal]: ", log.LstdFlags|log.Lshortfile)
+	logError = log.New(mjlog.CreateErrorFile(), "ERROR [journal]: ", log.LstdFlags|log.Lshortfile)
+)
+
+const journalFile = "/src/github.com/marklaczynski/acidbath/broker/journal/data/orders.journal"
+
+//DefaultPath returns the location of the order journal under GOPATH
+func DefaultPath() string {
+	return os.Getenv("GOPATH") + journalFile
+}
+
+//EntryType identifies what happened to an order
+type EntryType int
+
+//enumeration values for EntryType
+const (
+	InvalidEntry EntryType = iota
+	SubmitEntry            // the order is about to be sent to the broker
+	AckEntry               // the broker accepted the order and assigned an order id
+	RejectEntry            // the order was not accepted (risk, validation or broker error)
+	EventEntry             // an order event arrived from the broker's activity stream
+	CancelEntry            // a cancel was requested for the order
+	ReconcileEntry         // the order's state was corrected from the broker's order book
+)
+
+func (et EntryType) String() string {
+	switch et {
+	case InvalidEntry:
+		return "Invalid or Unsupported Entry Type"
+	case SubmitEntry:
+		return "submit"
+	case AckEntry:
+		return "ack"
+	case RejectEntry:
+		return "reject"
+	case EventEntry:
+		return "event"
+	case CancelEntry:
+		return "cancel"
+	case ReconcileEntry:
+		return "reconcile"
+	}
+	return ""
+}
+
+//Entry is a single line of the journal
+type Entry struct {
+	Time          time.Time
+	Type          EntryType
+	ClientOrderID string `json:",omitempty"`
+	OrderID       string `json:",omitempty"`
+	Symbol        string `json:",omitempty"`
+	Action        string `json:",omitempty"`
+	OrderType     string `json:",omitempty"`
+	Quantity      float64
+	Price         string `json:",omitempty"`
+	Event         string `json:",omitempty"` // order event name for EventEntry, order book status for ReconcileEntry
+	Working       bool   // whether the order is still working after this entry
+	Reason        string `json:",omitempty"`
+}
+
+//WorkingOrder is an order the journal believes is still live at the broker
+type WorkingOrder struct {
+	ClientOrderID string
+	OrderID       string
+	Symbol        string
+	Action        string
+	OrderType     string
+	Quantity      float64
+	FilledQty     float64
+	Price         string
+	LastEvent     string
+	Updated       time.Time
+}
+
+//Journal is an append-only order journal backed by a file. Each entry is written as a line of json and synced before returning
+type Journal struct {
+	sync.Mutex
+	file    *os.File
+	working map[string]*WorkingOrder // keyed by client order id
+	byID    map[string]string        // broker order id -> client order id
+	closed  map[string]bool          // broker order ids that ended before the journal knew their client order id
+}
+
+//Open opens (or creates) the journal at path and rebuilds the working orders from its entries
+func Open(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("unable to create journal directory: %s", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open journal %s: %s", path, err)
+	}
+
+	j := &Journal{
+		file:    file,
+		working: make(map[string]*WorkingOrder),
+		byID:    make(map[string]string),
+		closed:  make(map[string]bool),
+	}
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// a crash can leave a partial last line, skip it rather than refuse to start
+			logError.Printf("Skipping unreadable journal line %d: %s\n", line, err)
+			continue
+		}
+		j.apply(&e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to read journal %s: %s", path, err)
+	}
+
+	logInfo.Printf("Journal %s opened, %d entries, %d working orders\n", path, line, len(j.working))
+	return j, nil
+}
+
+//Close closes the journal file
+func (j *Journal) Close() error {
+	j.Lock()
+	defer j.Unlock()
+	return j.file.Close()
+}
+
+//Append writes the entry to the journal and applies it to the working orders
+func (j *Journal) Append(e *Entry) error {
+	j.Lock()
+	defer j.Unlock()
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("unable to encode journal entry: %s", err)
+	}
+
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("unable to write journal entry: %s", err)
+	}
+
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("unable to sync journal: %s", err)
+	}
+
+	logDebug.Printf("%s\n", data)
+	j.apply(e)
+	return nil
+}
+
+//WorkingOrders returns a copy of the orders still working
+func (j *Journal) WorkingOrders() []WorkingOrder {
+	j.Lock()
+	defer j.Unlock()
+
+	orders := make([]WorkingOrder, 0, len(j.working))
+	for _, w := range j.working {
+		orders = append(orders, *w)
+	}
+	return orders
+}
+
+//ClientOrderID returns the client order id for the broker's order id, or "" if it is not in the journal
+func (j *Journal) ClientOrderID(orderID string) string {
+	j.Lock()
+	defer j.Unlock()
+	return j.byID[orderID]
+}
+
+//apply updates the working order state for the entry. Caller must hold the lock.
+//The broker can report events before SendSingleLegOptionTrade returns the order id, a simulated fill always does,
+//so events keyed by the broker's id are moved to the client order id once the ack maps them, and an ack for an
+//order that already ended doesn't bring it back
+func (j *Journal) apply(e *Entry) {
+	key := e.ClientOrderID
+	if key == "" {
+		key = j.byID[e.OrderID]
+	}
+	if key == "" {
+		// orders placed outside this process are keyed by the broker's id
+		key = e.OrderID
+	}
+	if key == "" {
+		return
+	}
+
+	if e.OrderID != "" && key != e.OrderID {
+		if early, ok := j.working[e.OrderID]; ok {
+			delete(j.working, e.OrderID)
+			if _, ok := j.working[key]; !ok {
+				early.ClientOrderID = key
+				j.working[key] = early
+			}
+		}
+	}
+	if e.OrderID != "" {
+		j.byID[e.OrderID] = key
+	}
+
+	if !e.Working {
+		if e.Type == EventEntry && key == e.OrderID {
+			j.closed[e.OrderID] = true
+		}
+		delete(j.working, key)
+		return
+	}
+
+	if e.Type != EventEntry && key != e.OrderID && j.closed[e.OrderID] {
+		delete(j.closed, e.OrderID)
+		delete(j.working, key)
+		return
+	}
+
+	w, ok := j.working[key]
+	if !ok {
+		w = &WorkingOrder{ClientOrderID: e.ClientOrderID}
+		j.working[key] = w
+	}
+
+	if e.OrderID != "" {
+		w.OrderID = e.OrderID
+	}
+	if e.Symbol != "" {
+		w.Symbol = e.Symbol
+	}
+	if e.Action != "" {
+		w.Action = e.Action
+	}
+	if e.OrderType != "" {
+		w.OrderType = e.OrderType
+	}
+	if e.Price != "" {
+		w.Price = e.Price
+	}
+	if e.Type == EventEntry {
+		w.FilledQty += e.Quantity
+	} else if e.Quantity != 0 {
+		w.Quantity = e.Quantity
+	}
+	w.LastEvent = e.Type.String()
+	if e.Event != "" {
+		w.LastEvent = e.Event
+	}
+	w.Updated = e.Time
+}
+
+//orderEntry builds an entry describing o
+func orderEntry(t EntryType, o *order.Order, working bool) *Entry {
+	return &Entry{
+		Type:          t,
+		ClientOrderID: o.ClientOrderID(),
+		OrderID:       o.OrderID(),
+		Symbol:        o.Symbol(),
+		Action:        o.Action().String(),
+		OrderType:     o.OrderType().String(),
+		Quantity:      float64(o.Quantity()),
+		Price:         o.Price().Value.FloatString(2),
+		Working:       working,
+	}
+}
+
+//messageEntry builds an entry for an order event from the broker. Quantity holds the quantity filled by this event
+func messageEntry(m *ordermessage.Message, working bool) *Entry {
+	e := &Entry{
+		Type:    EventEntry,
+		OrderID: m.OrderID(),
+		Symbol:  m.Symbol(),
+		Event:   m.OrderEvent().String(),
+		Working: working,
+		Reason:  m.RejectReason(),
+	}
+
+	if m.IsExecution() {
+		e.Quantity = m.FillQuantity()
+		e.Price = m.FillPrice().Value.FloatString(2)
+	}
+
+	return e
+}
+
+//isTerminal returns true if the event means the order is no longer working at the broker
+func isTerminal(m *ordermessage.Message) bool {
+	switch m.OrderEvent() {
+	case orderconst.OrderFill, orderconst.OrderRejection, orderconst.OrderOut, orderconst.OrderBroken:
+		return true
+	case orderconst.OrderPartialFill, orderconst.OrderManualExecution:
+		return m.LeavesQuantity() == 0 && m.OriginalQuantity() > 0
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package journal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marklaczynski/acidbath/broker/generic"
+	"github.com/marklaczynski/acidbath/dm/order"
+	"github.com/marklaczynski/acidbath/dm/orderbook"
+	"github.com/marklaczynski/acidbath/dm/ordermessage"
+	"github.com/marklaczynski/acidbath/dm/orderstatus"
+	"github.com/marklaczynski/acidbath/lib/financial"
+	"github.com/marklaczynski/acidbath/lib/orderconst"
+)
+
+func tempJournal(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "orders.journal"), func() { os.RemoveAll(dir) }
+}
+
+func testOrder(clientOrderID string) *order.Order {
+	o := order.New()
+	o.SetClientOrderID(clientOrderID)
+	o.SetSymbol("XYZ_011519C105")
+	o.SetAction(orderconst.BuyToOpen)
+	o.SetOrderType(orderconst.Limit)
+	o.SetQuantity(5)
+	o.SetPrice(financial.NewMoney(1.05))
+	return o
+}
+
+func execution(orderID string, event orderconst.OrderEvent, fill float64, leaves float64) *ordermessage.Message {
+	m := ordermessage.New(orderID, event)
+	m.SetSymbol("XYZ_011519C105")
+	m.SetOriginalQuantity(5)
+	m.SetFillQuantity(fill)
+	m.SetLeavesQuantity(leaves)
+	m.SetFillPrice(financial.NewMoney(1.05))
+	return m
+}
+
+func TestReplay(t *testing.T) {
+	path, cleanup := tempJournal(t)
+	defer cleanup()
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// c1 is partially filled, c2 is filled and c3 was never acknowledged
+	acked := testOrder("c1")
+	j.Append(orderEntry(SubmitEntry, acked, true))
+	acked.SetOrderID("100")
+	j.Append(orderEntry(AckEntry, acked, true))
+	j.Append(messageEntry(execution("100", orderconst.OrderPartialFill, 2, 3), true))
+
+	filled := testOrder("c2")
+	filled.SetOrderID("200")
+	j.Append(orderEntry(AckEntry, filled, true))
+	j.Append(messageEntry(execution("200", orderconst.OrderFill, 5, 0), false))
+
+	j.Append(orderEntry(SubmitEntry, testOrder("c3"), true))
+	j.Close()
+
+	// a crash mid write leaves a partial line
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"Type":2,"ClientOrd`)
+	f.Close()
+
+	j, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	working := make(map[string]WorkingOrder)
+	for _, w := range j.WorkingOrders() {
+		working[w.ClientOrderID] = w
+	}
+	if len(working) != 2 {
+		t.Fatalf("got %d working orders, want c1 and c3: %+v", len(working), working)
+	}
+	if w := working["c1"]; w.OrderID != "100" || w.Quantity != 5 || w.FilledQty != 2 || w.LastEvent != orderconst.OrderPartialFill.String() {
+		t.Errorf("c1 replayed as %+v", w)
+	}
+	if w := working["c3"]; w.OrderID != "" || w.LastEvent != SubmitEntry.String() {
+		t.Errorf("c3 replayed as %+v", w)
+	}
+	if j.ClientOrderID("200") != "c2" {
+		t.Errorf("order 200 maps to %q, want c2", j.ClientOrderID("200"))
+	}
+}
+
+func newTestJournal() *Journal {
+	return &Journal{working: make(map[string]*WorkingOrder), byID: make(map[string]string), closed: make(map[string]bool)}
+}
+
+func TestApply(t *testing.T) {
+	j := newTestJournal()
+
+	o := testOrder("c1")
+	o.SetOrderID("100")
+	j.apply(orderEntry(AckEntry, o, true))
+
+	// broker events only carry the broker's id
+	j.apply(messageEntry(execution("100", orderconst.OrderPartialFill, 1, 4), true))
+	j.apply(messageEntry(execution("100", orderconst.OrderPartialFill, 2, 2), true))
+	if w := j.working["c1"]; w == nil || w.FilledQty != 3 || w.Quantity != 5 {
+		t.Fatalf("after partial fills got %+v, want 3 of 5 filled", w)
+	}
+
+	// orders placed outside this process are keyed by the broker's id
+	j.apply(&Entry{Type: ReconcileEntry, OrderID: "900", Symbol: "ABC", Quantity: 1, Event: "Open", Working: true})
+	if w := j.working["900"]; w == nil || w.ClientOrderID != "" || w.LastEvent != "Open" {
+		t.Errorf("outside order got %+v", w)
+	}
+
+	j.apply(messageEntry(execution("100", orderconst.OrderFill, 2, 0), false))
+	if _, ok := j.working["c1"]; ok {
+		t.Errorf("filled order is still working")
+	}
+
+	j.apply(&Entry{Type: EventEntry, Working: true})
+	if len(j.working) != 1 {
+		t.Errorf("entry without ids was applied")
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	tests := []struct {
+		name string
+		m    *ordermessage.Message
+		want bool
+	}{
+		{"fill", execution("1", orderconst.OrderFill, 5, 0), true},
+		{"rejection", ordermessage.New("1", orderconst.OrderRejection), true},
+		{"out", ordermessage.New("1", orderconst.OrderOut), true},
+		{"cancel request", ordermessage.New("1", orderconst.OrderCancel), false},
+		{"partial fill with leaves", execution("1", orderconst.OrderPartialFill, 2, 3), false},
+		{"partial fill completing the order", execution("1", orderconst.OrderPartialFill, 3, 0), true},
+		{"manual execution with leaves", execution("1", orderconst.OrderManualExecution, 2, 3), false},
+		{"manual execution completing the order", execution("1", orderconst.OrderManualExecution, 3, 0), true},
+	}
+
+	for _, test := range tests {
+		if got := isTerminal(test.m); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.name, got, test.want)
+		}
+	}
+}
+
+// bookBroker is a broker whose order book holds statuses
+type bookBroker struct {
+	generic.Broker
+	statuses []*orderstatus.OrderStatus
+}
+
+func (b *bookBroker) RetrieveOrderBook(accountid string, ob *orderbook.OrderBook) error {
+	for _, st := range b.statuses {
+		ob.AddUpdateOrderStatus(st)
+	}
+	return nil
+}
+
+func status(orderID string, s string) *orderstatus.OrderStatus {
+	st := orderstatus.New()
+	st.SetOrderID(orderID)
+	st.SetSymbol("XYZ_011519C105")
+	st.SetQuantity(2)
+	st.SetStatus(s)
+	return st
+}
+
+func TestApplyEventsBeforeAck(t *testing.T) {
+	j := newTestJournal()
+
+	// a simulated fill is processed before SendSingleLegOptionTrade returns and the ack is journaled
+	j.apply(messageEntry(execution("100", orderconst.OrderFill, 5, 0), false))
+	o := testOrder("c1")
+	o.SetOrderID("100")
+	j.apply(orderEntry(AckEntry, o, true))
+	if len(j.working) != 0 {
+		t.Errorf("order filled before its ack is working: %+v", j.working)
+	}
+	if j.byID["100"] != "c1" {
+		t.Errorf("order 100 maps to %q, want c1", j.byID["100"])
+	}
+
+	// a partial fill ahead of the ack moves to the client order id
+	j.apply(messageEntry(execution("200", orderconst.OrderPartialFill, 2, 3), true))
+	o = testOrder("c2")
+	o.SetOrderID("200")
+	j.apply(orderEntry(AckEntry, o, true))
+	if w := j.working["c2"]; w == nil || w.FilledQty != 2 || w.Quantity != 5 || w.ClientOrderID != "c2" {
+		t.Fatalf("partially filled order got %+v", w)
+	}
+	if _, ok := j.working["200"]; ok || len(j.working) != 1 {
+		t.Errorf("early partial fill left behind: %+v", j.working)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	path, cleanup := tempJournal(t)
+	defer cleanup()
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	for _, id := range []string{"100", "200", "300"} {
+		o := testOrder("c" + id)
+		o.SetOrderID(id)
+		j.Append(orderEntry(AckEntry, o, true))
+	}
+	j.Append(orderEntry(SubmitEntry, testOrder("unacked"), true))
+
+	// 100 still works, 200 filled while we were down, 300 is gone and 400 was placed elsewhere
+	bb := &bookBroker{statuses: []*orderstatus.OrderStatus{status("100", "Open"), status("200", "Filled"), status("400", "Open")}}
+	b := &Broker{Broker: bb, journal: j}
+	if err := b.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+
+	working := make(map[string]WorkingOrder)
+	for _, w := range j.WorkingOrders() {
+		working[w.OrderID] = w
+	}
+	if len(working) != 2 {
+		t.Fatalf("got %d working orders, want 100 and 400: %+v", len(working), working)
+	}
+	if _, ok := working["100"]; !ok {
+		t.Errorf("open order 100 was closed")
+	}
+	if w, ok := working["400"]; !ok || w.ClientOrderID != "" || w.Quantity != 2 {
+		t.Errorf("order 400 from the book got %+v", w)
+	}
+	// the unacknowledged order can't be matched, it is flagged once and closed
+	if _, ok := working[""]; ok {
+		t.Errorf("unacknowledged order is still working")
+	}
+}
@@ -0,0 +1,88 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package risk
+
+import (
+	"github.com/marklaczynski/acidbath/broker/generic"
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/order"
+)
+
+//Broker wraps a generic.Broker and runs every order through the risk Engine before it reaches the broker.
+//All other calls are passed straight through to the wrapped broker
+type Broker struct {
+	generic.Broker
+	engine *Engine
+}
+
+//New returns a Broker that checks orders against limits before sending them to b
+func New(b generic.Broker, limits Limits) *Broker {
+	return &Broker{
+		Broker: b,
+		engine: NewEngine(limits),
+	}
+}
+
+//Engine returns the risk engine used by the broker
+func (b *Broker) Engine() *Engine {
+	return b.engine
+}
+
+//SendSingleLegOptionTrade checks the order against the risk limits, and only sends it to the broker if it passes.
+//Rejections are returned as a *Violation
+func (b *Broker) SendSingleLegOptionTrade(o *order.Order) error {
+	logInfo.Printf("SendSingleLegOptionTrade %s\n", o.Symbol())
+
+	ctx, err := b.context(o)
+	if err != nil {
+		return err
+	}
+
+	if err := b.engine.Check(o, ctx); err != nil {
+		logError.Printf("Order rejected by risk engine: %s\n", err)
+		return err
+	}
+
+	return b.Broker.SendSingleLegOptionTrade(o)
+}
+
+//context pulls fresh quotes for the order being checked. Positions come from the broker's cached portfolio, refreshing
+//it would replace the session's portfolio and push it to the UI on every order
+func (b *Broker) context(o *order.Order) (Context, error) {
+	opt := option.NewNilOption()
+	if err := b.Broker.RetrieveSnapshot(o.Symbol(), asset.OptionType, opt); err != nil {
+		return Context{}, violation(MarketDataRule, "unable to quote %s: %s", o.Symbol(), err)
+	}
+
+	var underlying *asset.Stock
+	if opt.Underlying() != "" {
+		underlying = asset.NewStock(opt.Underlying())
+		if err := b.Broker.RetrieveSnapshot(opt.Underlying(), asset.EquityType, underlying); err != nil {
+			return Context{}, violation(MarketDataRule, "unable to quote %s: %s", opt.Underlying(), err)
+		}
+	}
+
+	p, ok := b.Broker.CachedPortfolio()
+	if !ok {
+		return Context{}, violation(MarketDataRule, "positions have not been retrieved")
+	}
+
+	return Context{Option: opt, Underlying: underlying, Portfolio: p}, nil
+}
@@ -0,0 +1,444 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package risk implements pre-trade risk checks that sit in front of a generic.Broker
+package risk
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/order"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/lib/mjlog"
+	"github.com/marklaczynski/acidbath/lib/orderconst"
+)
+
+var (
+	logInfo  = log.New(mjlog.CreateInfoFile(), "INFO  [risk]: ", log.LstdFlags|log.Lshortfile)
+	logDebug = log.New(mjlog.CreateDebugFile(), "DEBUG [risk]: ", log.LstdFlags|log.Lshortfile)
+	logError = log.New(mjlog.CreateErrorFile(), "ERROR [risk]: ", log.LstdFlags|log.Lshortfile)
+)
+
+const configFile = "/src/github.com/marklaczynski/acidbath/broker/risk/config/riskconfig.json"
+
+//Limits holds the configurable pre-trade limits. A zero value disables the corresponding check
+type Limits struct {
+	MaxContractsPerOrder     int           // max quantity on a single order
+	MaxNotional              float64       // max price * quantity * multiplier on a single order
+	MaxPositionPerUnderlying float64       // max option contracts held in one underlying after the order
+	CheckBuyingPower         bool          // reject opening orders whose requirement exceeds option buying power
+	AllowNakedShortCalls     bool          // allow selling calls that are not covered by stock or long calls
+	PriceCollarPercent       float64       // limit price must be within this fraction of the mid (ie 0.10)
+	PriceCollarMinimum       float64       // minimum width of the collar in dollars, so cheap options can still trade
+	DuplicateWindow          time.Duration // identical orders sent within this window are rejected
+}
+
+//DefaultLimits returns conservative limits that are used when no config file is present
+func DefaultLimits() Limits {
+	return Limits{
+		MaxContractsPerOrder:     10,
+		MaxNotional:              10000,
+		MaxPositionPerUnderlying: 50,
+		CheckBuyingPower:         true,
+		AllowNakedShortCalls:     false,
+		PriceCollarPercent:       0.10,
+		PriceCollarMinimum:       0.10,
+		DuplicateWindow:          5 * time.Second,
+	}
+}
+
+//ConfiguredLimits loads the limits from the risk config file under GOPATH, falling back to DefaultLimits
+func ConfiguredLimits() Limits {
+	limits, err := LoadLimits(os.Getenv("GOPATH") + configFile)
+	if err != nil {
+		logInfo.Printf("Using default risk limits: %s\n", err)
+		return DefaultLimits()
+	}
+	return limits
+}
+
+//LoadLimits reads limits from a json file. Fields missing from the file keep their default value.
+//DuplicateWindow is given in seconds in the file
+func LoadLimits(path string) (Limits, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Limits{}, fmt.Errorf("unable to open risk config %s: %s", path, err)
+	}
+	defer file.Close()
+
+	limits := DefaultLimits()
+	config := struct {
+		Limits
+		DuplicateWindowSeconds *float64
+	}{Limits: limits}
+
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return Limits{}, fmt.Errorf("unable to decode risk config %s: %s", path, err)
+	}
+
+	limits = config.Limits
+	if config.DuplicateWindowSeconds != nil {
+		limits.DuplicateWindow = time.Duration(*config.DuplicateWindowSeconds * float64(time.Second))
+	}
+
+	return limits, nil
+}
+
+//Rule identifies which pre-trade check rejected an order
+type Rule int
+
+//Rules enforced by the Engine
+const (
+	InvalidRule Rule = iota
+	MaxContractsRule
+	MaxNotionalRule
+	MaxPositionRule
+	BuyingPowerRule
+	NakedShortCallRule
+	PriceCollarRule
+	DuplicateOrderRule
+	MarketDataRule
+)
+
+func (r Rule) String() string {
+	switch r {
+	case InvalidRule:
+		return "Invalid or Unsupported Rule"
+	case MaxContractsRule:
+		return "MaxContracts"
+	case MaxNotionalRule:
+		return "MaxNotional"
+	case MaxPositionRule:
+		return "MaxPositionPerUnderlying"
+	case BuyingPowerRule:
+		return "BuyingPower"
+	case NakedShortCallRule:
+		return "NakedShortCall"
+	case PriceCollarRule:
+		return "PriceCollar"
+	case DuplicateOrderRule:
+		return "DuplicateOrder"
+	case MarketDataRule:
+		return "MarketData"
+	}
+	return ""
+}
+
+//Violation is returned when an order fails a pre-trade check
+type Violation struct {
+	Rule   Rule
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("risk check %s failed: %s", v.Rule, v.Reason)
+}
+
+func violation(rule Rule, format string, a ...interface{}) *Violation {
+	return &Violation{Rule: rule, Reason: fmt.Sprintf(format, a...)}
+}
+
+//Context holds the market and account state an order is checked against
+type Context struct {
+	Option     *option.Option       // current quote for the option being traded
+	Underlying *asset.Stock         // current quote for the option's underlying
+	Portfolio  *portfolio.Portfolio // current positions and balances
+}
+
+//Engine runs pre-trade checks against a set of Limits. It is safe for concurrent use
+type Engine struct {
+	sync.Mutex
+	limits Limits
+	recent map[string]time.Time
+	now    func() time.Time
+}
+
+//NewEngine returns an Engine enforcing limits
+func NewEngine(limits Limits) *Engine {
+	return &Engine{
+		limits: limits,
+		recent: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+//Limits returns the limits enforced by the engine
+func (e *Engine) Limits() Limits {
+	e.Lock()
+	defer e.Unlock()
+	return e.limits
+}
+
+//SetLimits replaces the limits enforced by the engine
+func (e *Engine) SetLimits(limits Limits) {
+	e.Lock()
+	defer e.Unlock()
+	e.limits = limits
+}
+
+//Check runs every pre-trade check on o. It returns a *Violation for the first check that fails.
+//An order that passes is remembered for duplicate detection
+func (e *Engine) Check(o *order.Order, ctx Context) error {
+	e.Lock()
+	defer e.Unlock()
+
+	if ctx.Option == nil {
+		return violation(MarketDataRule, "no quote available for %s", o.Symbol())
+	}
+
+	multiplier := ctx.Option.Multiplier()
+	if multiplier == 0 {
+		multiplier = 100
+	}
+
+	price, err := orderPrice(o, ctx.Option)
+	if err != nil {
+		return err
+	}
+
+	if e.limits.MaxContractsPerOrder > 0 && o.Quantity() > e.limits.MaxContractsPerOrder {
+		return violation(MaxContractsRule, "quantity %d exceeds max of %d contracts", o.Quantity(), e.limits.MaxContractsPerOrder)
+	}
+
+	notional := price * float64(o.Quantity()) * multiplier
+	if e.limits.MaxNotional > 0 && notional > e.limits.MaxNotional {
+		return violation(MaxNotionalRule, "notional %.2f exceeds max of %.2f", notional, e.limits.MaxNotional)
+	}
+
+	if err := e.checkPriceCollar(o, ctx.Option); err != nil {
+		return err
+	}
+
+	if isOpening(o.Action()) && ctx.Portfolio != nil {
+		if err := e.checkPosition(o, ctx); err != nil {
+			return err
+		}
+
+		if err := e.checkNakedCall(o, ctx, multiplier); err != nil {
+			return err
+		}
+
+		if err := e.checkBuyingPower(o, ctx, price, multiplier); err != nil {
+			return err
+		}
+	}
+
+	key := duplicateKey(o)
+	if e.limits.DuplicateWindow > 0 {
+		if last, ok := e.recent[key]; ok && e.now().Sub(last) < e.limits.DuplicateWindow {
+			return violation(DuplicateOrderRule, "identical order sent %s ago", e.now().Sub(last))
+		}
+	}
+	e.remember(key)
+
+	logDebug.Printf("order %s passed risk checks\n", o.Symbol())
+	return nil
+}
+
+func (e *Engine) remember(key string) {
+	now := e.now()
+	for k, t := range e.recent {
+		if now.Sub(t) >= e.limits.DuplicateWindow {
+			delete(e.recent, k)
+		}
+	}
+	e.recent[key] = now
+}
+
+func (e *Engine) checkPriceCollar(o *order.Order, opt *option.Option) error {
+	if e.limits.PriceCollarPercent <= 0 && e.limits.PriceCollarMinimum <= 0 {
+		return nil
+	}
+
+	if o.OrderType() != orderconst.Limit && o.OrderType() != orderconst.StopLimit {
+		return nil
+	}
+
+	bid, _ := opt.Bid().Value.Float64()
+	ask, _ := opt.Ask().Value.Float64()
+	if ask <= 0 {
+		return violation(MarketDataRule, "no ask for %s, cannot collar the limit price", o.Symbol())
+	}
+
+	mid := (bid + ask) / 2
+	width := math.Max(mid*e.limits.PriceCollarPercent, e.limits.PriceCollarMinimum)
+	limit, _ := o.Price().Value.Float64()
+
+	if limit < mid-width || limit > mid+width {
+		return violation(PriceCollarRule, "limit %.2f is outside %.2f +/- %.2f", limit, mid, width)
+	}
+
+	return nil
+}
+
+func (e *Engine) checkPosition(o *order.Order, ctx Context) error {
+	if e.limits.MaxPositionPerUnderlying <= 0 {
+		return nil
+	}
+
+	var contracts float64
+	for _, p := range ctx.Portfolio.Position(asset.OptionType) {
+		if p.UnderlyingSymbol() == ctx.Option.Underlying() {
+			contracts += math.Abs(p.Quantity())
+		}
+	}
+
+	contracts += float64(o.Quantity())
+	if contracts > e.limits.MaxPositionPerUnderlying {
+		return violation(MaxPositionRule, "%.0f contracts in %s exceeds max of %.0f", contracts, ctx.Option.Underlying(), e.limits.MaxPositionPerUnderlying)
+	}
+
+	return nil
+}
+
+func (e *Engine) checkNakedCall(o *order.Order, ctx Context, multiplier float64) error {
+	if e.limits.AllowNakedShortCalls || o.Action() != orderconst.SellToOpen || ctx.Option.OptionType() != option.CALL {
+		return nil
+	}
+
+	// coverage is counted in contracts: stock shares / multiplier plus long calls, less calls already sold
+	var coverage float64
+	for _, p := range ctx.Portfolio.Position(asset.EquityType) {
+		if p.Symbol() == ctx.Option.Underlying() {
+			coverage += p.SignedQuantity() / multiplier
+		}
+	}
+
+	for _, p := range ctx.Portfolio.Position(asset.OptionType) {
+		if p.UnderlyingSymbol() != ctx.Option.Underlying() || !isCall(p) {
+			continue
+		}
+		coverage += p.SignedQuantity()
+	}
+
+	if float64(o.Quantity()) > math.Floor(coverage) {
+		return violation(NakedShortCallRule, "selling %d calls on %s with coverage for %.0f", o.Quantity(), ctx.Option.Underlying(), math.Max(math.Floor(coverage), 0))
+	}
+
+	return nil
+}
+
+func (e *Engine) checkBuyingPower(o *order.Order, ctx Context, price float64, multiplier float64) error {
+	if !e.limits.CheckBuyingPower || ctx.Portfolio.Balance() == nil {
+		return nil
+	}
+
+	var requirement float64
+	switch o.Action() {
+	case orderconst.BuyToOpen:
+		requirement = price * float64(o.Quantity()) * multiplier
+	case orderconst.SellToOpen:
+		if ctx.Option.OptionType() == option.CALL && e.isCovered(o, ctx, multiplier) {
+			return nil
+		}
+		requirement = shortOptionRequirement(ctx.Option, ctx.Underlying) * float64(o.Quantity()) * multiplier
+	}
+
+	bp := ctx.Portfolio.Balance().OptionBuyingPower()
+	if requirement > bp {
+		return violation(BuyingPowerRule, "requirement %.2f exceeds option buying power %.2f", requirement, bp)
+	}
+
+	return nil
+}
+
+//isCovered reports whether the shares held in the underlying cover the calls being sold, after the calls
+//already short against them
+func (e *Engine) isCovered(o *order.Order, ctx Context, multiplier float64) bool {
+	var shares float64
+	for _, p := range ctx.Portfolio.Position(asset.EquityType) {
+		if p.Symbol() == ctx.Option.Underlying() {
+			shares += p.SignedQuantity()
+		}
+	}
+
+	for _, p := range ctx.Portfolio.Position(asset.OptionType) {
+		if p.UnderlyingSymbol() == ctx.Option.Underlying() && isCall(p) && p.IsShort() {
+			shares += p.SignedQuantity() * p.Multiplier()
+		}
+	}
+
+	return shares >= multiplier*float64(o.Quantity())
+}
+
+//shortOptionRequirement is the per share margin requirement of a naked short option using the
+//standard 20% of underlying less out of the money amount, with a 10% floor
+func shortOptionRequirement(opt *option.Option, underlying *asset.Stock) float64 {
+	var spot float64
+	if underlying != nil {
+		spot, _ = underlying.LastTradePrice().Value.Float64()
+	}
+
+	var otm, floor float64
+	switch opt.OptionType() {
+	case option.CALL:
+		otm = math.Max(opt.Strike()-spot, 0)
+		floor = 0.10 * spot
+	case option.PUT:
+		otm = math.Max(spot-opt.Strike(), 0)
+		floor = 0.10 * opt.Strike()
+	}
+
+	return math.Max(0.20*spot-otm, floor)
+}
+
+//orderPrice returns the price the order is expected to execute at. Market orders use the far side of the quote
+func orderPrice(o *order.Order, opt *option.Option) (float64, error) {
+	switch o.OrderType() {
+	case orderconst.Limit, orderconst.StopLimit:
+		price, _ := o.Price().Value.Float64()
+		return price, nil
+	}
+
+	var side float64
+	switch o.Action() {
+	case orderconst.BuyToOpen, orderconst.BuyToClose:
+		side, _ = opt.Ask().Value.Float64()
+	default:
+		side, _ = opt.Bid().Value.Float64()
+	}
+
+	if side <= 0 {
+		return 0, violation(MarketDataRule, "no quote to price %s order for %s", o.OrderType(), o.Symbol())
+	}
+
+	return side, nil
+}
+
+func isOpening(action orderconst.OrderAction) bool {
+	return action == orderconst.BuyToOpen || action == orderconst.SellToOpen
+}
+
+func isCall(p *portfolio.PositionType) bool {
+	if p.PutCallIndicator() != "" {
+		return p.PutCallIndicator() == "C"
+	}
+	return p.UnderlyingOption() != nil && p.UnderlyingOption().OptionType() == option.CALL
+}
+
+func duplicateKey(o *order.Order) string {
+	return fmt.Sprintf("%s|%s|%s|%d|%s", o.Symbol(), o.Action(), o.OrderType(), o.Quantity(), o.Price())
+}
@@ -0,0 +1,189 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package risk
+
+import (
+	"testing"
+
+	"github.com/marklaczynski/acidbath/broker/generic"
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/order"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/lib/financial"
+	"github.com/marklaczynski/acidbath/lib/orderconst"
+)
+
+// holding is a position in XYZ: shares when call is false, otherwise call contracts (negative when short)
+type holding struct {
+	call     bool
+	quantity float64
+}
+
+// quoteContext quotes an XYZ 105 call at 1.00 x 1.10 with XYZ at 100
+func quoteContext(buyingPower float64, holdings ...holding) Context {
+	opt := option.NewNilOption()
+	opt.SetSymbol("XYZ_011519C105")
+	opt.SetUnderlying("XYZ")
+	opt.SetOptionType(option.CALL)
+	opt.SetStrike(105)
+	opt.SetMultiplier(100)
+	opt.SetBid(financial.NewMoney(1.00))
+	opt.SetAsk(financial.NewMoney(1.10))
+
+	underlying := asset.NewStock("XYZ")
+	underlying.SetLastTradePrice(financial.NewMoney(100))
+
+	p := portfolio.NewPortfolio()
+	p.Balance().SetOptionBuyingPower(buyingPower)
+	for _, h := range holdings {
+		pos := portfolio.NewPosition()
+		pos.SetUnderlyingSymbol("XYZ")
+		pos.SetQuantity(h.quantity)
+		pos.SetPositionType(portfolio.LongPosition)
+		if h.quantity < 0 {
+			pos.SetQuantity(-h.quantity)
+			pos.SetPositionType(portfolio.ShortPosition)
+		}
+		if h.call {
+			pos.SetSymbol("XYZ_011519C110")
+			pos.SetAssetType(asset.OptionType)
+			pos.SetPutCallIndicator("C")
+			pos.SetUnderlyingOption(option.NewNilOption())
+			p.AddPosition(asset.OptionType, pos)
+		} else {
+			pos.SetSymbol("XYZ")
+			pos.SetAssetType(asset.EquityType)
+			p.AddPosition(asset.EquityType, pos)
+		}
+	}
+
+	return Context{Option: opt, Underlying: underlying, Portfolio: p}
+}
+
+func limitOrder(action orderconst.OrderAction, quantity int) *order.Order {
+	o := order.New()
+	o.SetSymbol("XYZ_011519C105")
+	o.SetAction(action)
+	o.SetOrderType(orderconst.Limit)
+	o.SetQuantity(quantity)
+	o.SetPrice(financial.NewMoney(1.05))
+	return o
+}
+
+func TestCheck(t *testing.T) {
+	// only the limits under test are set on each case
+	tests := []struct {
+		name   string
+		limits Limits
+		order  *order.Order
+		ctx    Context
+		rule   Rule // InvalidRule when the order should pass
+	}{
+		{"quantity at max", Limits{MaxContractsPerOrder: 5}, limitOrder(orderconst.BuyToOpen, 5), quoteContext(0), InvalidRule},
+		{"quantity over max", Limits{MaxContractsPerOrder: 5}, limitOrder(orderconst.BuyToOpen, 6), quoteContext(0), MaxContractsRule},
+		{"notional under max", Limits{MaxNotional: 500}, limitOrder(orderconst.BuyToOpen, 4), quoteContext(0), InvalidRule},
+		{"notional over max", Limits{MaxNotional: 500}, limitOrder(orderconst.BuyToOpen, 5), quoteContext(0), MaxNotionalRule},
+
+		{"debit within buying power", Limits{CheckBuyingPower: true}, limitOrder(orderconst.BuyToOpen, 2), quoteContext(300), InvalidRule},
+		{"debit over buying power", Limits{CheckBuyingPower: true}, limitOrder(orderconst.BuyToOpen, 2), quoteContext(200), BuyingPowerRule},
+		{"closing skips buying power", Limits{CheckBuyingPower: true}, limitOrder(orderconst.BuyToClose, 2), quoteContext(0), InvalidRule},
+
+		{"covered call", Limits{CheckBuyingPower: true}, limitOrder(orderconst.SellToOpen, 1), quoteContext(0, holding{false, 100}), InvalidRule},
+		{"two calls on 100 shares", Limits{CheckBuyingPower: true}, limitOrder(orderconst.SellToOpen, 2), quoteContext(0, holding{false, 100}), NakedShortCallRule},
+		{"two calls on 150 shares", Limits{CheckBuyingPower: true}, limitOrder(orderconst.SellToOpen, 2), quoteContext(0, holding{false, 150}), NakedShortCallRule},
+		{"shares partly covering a short call", Limits{CheckBuyingPower: true}, limitOrder(orderconst.SellToOpen, 1), quoteContext(0, holding{false, 200}, holding{true, -1}), InvalidRule},
+		{"shares covering existing short calls", Limits{CheckBuyingPower: true}, limitOrder(orderconst.SellToOpen, 1), quoteContext(0, holding{false, 200}, holding{true, -2}), NakedShortCallRule},
+		{"spread needs buying power", Limits{CheckBuyingPower: true}, limitOrder(orderconst.SellToOpen, 2), quoteContext(0, holding{false, 100}, holding{true, 1}), BuyingPowerRule},
+		{"no coverage", Limits{CheckBuyingPower: true}, limitOrder(orderconst.SellToOpen, 1), quoteContext(10000), NakedShortCallRule},
+
+		{"naked allowed over buying power", Limits{CheckBuyingPower: true, AllowNakedShortCalls: true}, limitOrder(orderconst.SellToOpen, 1), quoteContext(1000), BuyingPowerRule},
+		{"naked allowed within buying power", Limits{CheckBuyingPower: true, AllowNakedShortCalls: true}, limitOrder(orderconst.SellToOpen, 1), quoteContext(1500), InvalidRule},
+		{"naked allowed without buying power check", Limits{AllowNakedShortCalls: true}, limitOrder(orderconst.SellToOpen, 1), quoteContext(0), InvalidRule},
+	}
+
+	for _, tt := range tests {
+		err := NewEngine(tt.limits).Check(tt.order, tt.ctx)
+		if tt.rule == InvalidRule {
+			if err != nil {
+				t.Errorf("%s: got %s, want no violation", tt.name, err)
+			}
+			continue
+		}
+
+		v, ok := err.(*Violation)
+		if !ok || v.Rule != tt.rule {
+			t.Errorf("%s: got %v, want %s violation", tt.name, err, tt.rule)
+		}
+	}
+}
+
+// contextBroker quotes the orders of quoteContext and counts the calls that would change session state
+type contextBroker struct {
+	generic.Broker
+	ctx       Context
+	cached    bool
+	refreshes int
+	sent      int
+}
+
+func (cb *contextBroker) RetrieveSnapshot(symbol string, assetType asset.AssetType, security interface{}) error {
+	switch s := security.(type) {
+	case *option.Option:
+		*s = *cb.ctx.Option
+	case *asset.Stock:
+		s.SetLastTradePrice(cb.ctx.Underlying.LastTradePrice())
+	}
+	return nil
+}
+
+func (cb *contextBroker) CachedPortfolio() (*portfolio.Portfolio, bool) {
+	if !cb.cached {
+		return nil, false
+	}
+	return cb.ctx.Portfolio.Copy(), true
+}
+
+func (cb *contextBroker) RetrievePortfolio(p *portfolio.Portfolio) error {
+	cb.refreshes++
+	return nil
+}
+
+func (cb *contextBroker) SendSingleLegOptionTrade(o *order.Order) error {
+	cb.sent++
+	return nil
+}
+
+func TestBrokerUsesCachedPortfolio(t *testing.T) {
+	cb := &contextBroker{ctx: quoteContext(1000, holding{quantity: 100})}
+	b := New(cb, Limits{MaxContractsPerOrder: 5})
+
+	err := b.SendSingleLegOptionTrade(limitOrder(orderconst.BuyToOpen, 1))
+	if v, ok := err.(*Violation); !ok || v.Rule != MarketDataRule {
+		t.Errorf("without a cached portfolio got %v, want a %s violation", err, MarketDataRule)
+	}
+
+	cb.cached = true
+	if err := b.SendSingleLegOptionTrade(limitOrder(orderconst.SellToOpen, 1)); err != nil {
+		t.Errorf("covered call got %v", err)
+	}
+	if cb.sent != 1 || cb.refreshes != 0 {
+		t.Errorf("got %d sent and %d portfolio refreshes, want 1 and 0", cb.sent, cb.refreshes)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package simfill simulates the execution of an order against an option quote, so strategies can be run without sending orders
+package simfill
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/commission"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/order"
+	"github.com/marklaczynski/acidbath/dm/ordermessage"
+	"github.com/marklaczynski/acidbath/lib/financial"
+	"github.com/marklaczynski/acidbath/lib/orderconst"
+)
+
+//ErrNotMarketable is returned when a limit order would not execute against the current quote
+var ErrNotMarketable = errors.New("order is not marketable at the current quote")
+
+//ErrNoQuote is returned when the quote has no price on the side the order needs
+var ErrNoQuote = errors.New("no quote on the side of the order")
+
+//Fill executes o in full against opt's current quote. Buys fill at the ask and sells at the bid, limit orders
+//must be marketable. The returned fill message carries the fees estimated by model as its charges
+func Fill(o *order.Order, opt *option.Option, model commission.Model) (*ordermessage.Message, error) {
+	instruction := commission.Instruction(o.Action())
+
+	var side financial.Money
+	switch instruction {
+	case orderconst.Buy:
+		side = opt.Ask()
+	case orderconst.Sell:
+		side = opt.Bid()
+	default:
+		return nil, errors.New("unsupported order action " + o.Action().String())
+	}
+
+	if side.Value == nil || side.Value.Sign() <= 0 {
+		return nil, ErrNoQuote
+	}
+
+	if o.OrderType() == orderconst.Limit {
+		if instruction == orderconst.Buy && o.Price().Value.Cmp(side.Value) < 0 {
+			return nil, ErrNotMarketable
+		}
+		if instruction == orderconst.Sell && o.Price().Value.Cmp(side.Value) > 0 {
+			return nil, ErrNotMarketable
+		}
+	} else if o.OrderType() != orderconst.Market {
+		return nil, errors.New("simulated fills only support market and limit orders")
+	}
+
+	now := time.Now()
+	price, _ := side.Value.Float64()
+
+	m := ordermessage.New(o.ClientOrderID(), orderconst.OrderFill)
+	m.SetSymbol(o.Symbol())
+	m.SetUnderlying(opt.Underlying())
+	m.SetInstruction(instruction)
+	m.SetOriginalQuantity(float64(o.Quantity()))
+	m.SetFillQuantity(float64(o.Quantity()))
+	m.SetLeavesQuantity(0)
+	m.SetLimitPrice(o.Price())
+	m.SetFillPrice(financial.Money{Value: new(big.Rat).Set(side.Value)})
+	m.SetEnteredTime(now)
+	m.SetExecutionTime(now)
+	m.SetActivityTime(now)
+
+	fees := model.Estimate(commission.Trade{
+		Symbol:      o.Symbol(),
+		Instruction: instruction,
+		Quantity:    float64(o.Quantity()),
+		Price:       price,
+		Multiplier:  opt.Multiplier(),
+	})
+
+	addCharge(m, "Commission", fees.Commission)
+	addCharge(m, "Exchange Fee", fees.ExchangeFee)
+	addCharge(m, "Regulatory Fee", fees.RegulatoryFee)
+
+	return m, nil
+}
+
+func addCharge(m *ordermessage.Message, chargeType string, amount float64) {
+	if amount == 0 {
+		return
+	}
+	m.AddCharge(ordermessage.NewCharge(chargeType, financial.Money{Value: new(big.Rat).SetFloat64(amount)}))
+}
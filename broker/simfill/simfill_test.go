@@ -0,0 +1,90 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package simfill
+
+import (
+	"math"
+	"testing"
+
+	"github.com/marklaczynski/acidbath/dm/commission"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/order"
+	"github.com/marklaczynski/acidbath/lib/financial"
+	"github.com/marklaczynski/acidbath/lib/orderconst"
+)
+
+func quote(bid float64, ask float64) *option.Option {
+	opt := option.NewNilOption()
+	opt.SetSymbol("XYZ_011519C105")
+	opt.SetUnderlying("XYZ")
+	opt.SetMultiplier(100)
+	opt.SetBid(financial.NewMoney(bid))
+	opt.SetAsk(financial.NewMoney(ask))
+	return opt
+}
+
+func newOrder(action orderconst.OrderAction, orderType orderconst.OrderType, price float64) *order.Order {
+	o := order.New()
+	o.SetClientOrderID("c1")
+	o.SetSymbol("XYZ_011519C105")
+	o.SetAction(action)
+	o.SetOrderType(orderType)
+	o.SetQuantity(2)
+	o.SetPrice(financial.NewMoney(price))
+	return o
+}
+
+func TestFill(t *testing.T) {
+	model := &commission.Schedule{PerOrder: 5, PerContract: 1}
+
+	tests := []struct {
+		name    string
+		order   *order.Order
+		opt     *option.Option
+		price   float64
+		charges float64
+		err     error
+	}{
+		{"market buy at the ask", newOrder(orderconst.BuyToOpen, orderconst.Market, 0), quote(1.00, 1.10), 1.10, 7, nil},
+		{"marketable sell at the bid", newOrder(orderconst.SellToClose, orderconst.Limit, 0.95), quote(1.00, 1.10), 1.00, 7, nil},
+		{"buy below the ask", newOrder(orderconst.BuyToOpen, orderconst.Limit, 1.05), quote(1.00, 1.10), 0, 0, ErrNotMarketable},
+		{"sell above the bid", newOrder(orderconst.SellToOpen, orderconst.Limit, 1.05), quote(1.00, 1.10), 0, 0, ErrNotMarketable},
+		{"no bid", newOrder(orderconst.SellToClose, orderconst.Market, 0), quote(0, 0.05), 0, 0, ErrNoQuote},
+	}
+
+	for _, test := range tests {
+		m, err := Fill(test.order, test.opt, model)
+		if err != test.err {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		price, _ := m.FillPrice().Value.Float64()
+		charges, _ := m.TotalCharges().Value.Float64()
+		if m.FillQuantity() != 2 || m.LeavesQuantity() != 0 || math.Abs(price-test.price) > 1e-9 || math.Abs(math.Abs(charges)-test.charges) > 1e-9 {
+			t.Errorf("%s: got %.0f @ %.2f leaving %.0f with %.2f charges, want 2 @ %.2f with %.2f", test.name, m.FillQuantity(), price, m.LeavesQuantity(), charges, test.price, test.charges)
+		}
+		if m.OrderEvent() != orderconst.OrderFill || m.Underlying() != "XYZ" {
+			t.Errorf("%s: got %s on %s, want a fill on XYZ", test.name, m.OrderEvent(), m.Underlying())
+		}
+	}
+}
@@ -20,6 +20,8 @@ package amtd
 
 import (
 	"encoding/xml"
+	"strconv"
+	"strings"
 
 	"github.com/marklaczynski/acidbath/lib/financial"
 	"github.com/marklaczynski/acidbath/lib/types"
@@ -97,6 +99,23 @@ type optionXML struct {
 	DeliverableList   deliverableListXML `xml:"deliverable-list"`
 }
 
+//BidAskSizes returns the bid and ask size
+func (o *optionXML) BidAskSizes() (bidSize int32, askSize int32) {
+	return parseBidAskSize(o.BidAskSize)
+}
+
+//parseBidAskSize parses a bid ask size, like "10X25", into the bid and ask size. A size that isn't a number is 0
+func parseBidAskSize(bidAskSize string) (bidSize int32, askSize int32) {
+	sizes := strings.Split(strings.ToUpper(bidAskSize), "X")
+	if len(sizes) != 2 {
+		return 0, 0
+	}
+
+	bid, _ := strconv.ParseInt(strings.TrimSpace(sizes[0]), 10, 32)
+	ask, _ := strconv.ParseInt(strings.TrimSpace(sizes[1]), 10, 32)
+	return int32(bid), int32(ask)
+}
+
 type deliverableListXML struct {
 	XMLName                xml.Name         `xml:"deliverable-list"`
 	CashInLieuDollarAmount types.XMLFloat64 `xml:"cash-in-lieu-dollar-amount"`
@@ -21,9 +21,11 @@ package amtd
 import (
 	"encoding/xml"
 	"fmt"
+	"math/big"
 	"strings"
 	"time"
 
+	"github.com/marklaczynski/acidbath/dm/asset"
 	"github.com/marklaczynski/acidbath/dm/optionchain/option"
 	"github.com/marklaczynski/acidbath/lib/date"
 	"github.com/marklaczynski/acidbath/lib/financial"
@@ -86,6 +88,48 @@ type QuoteXML struct {
 	Offer             types.XMLFloat64 `xml:"offer"`
 }
 
+//ProcessQuote fills q with the equity fields of the snapshot. Prices TD left out of the snapshot are not set, and
+//the first invalid price is returned after the rest of the quote is filled
+func (amtdQuote QuoteXML) ProcessQuote(q *asset.Quote) error {
+	var err error
+	q.SetSymbol(amtdQuote.Symbol)
+	q.SetDescription(amtdQuote.Description)
+	q.SetExchange(amtdQuote.Exchange)
+
+	prices := []struct {
+		value  financial.Money
+		setter func(financial.Money) error
+	}{
+		{amtdQuote.Bid, q.SetBidPrice},
+		{amtdQuote.Ask, q.SetAskPrice},
+		{amtdQuote.Last, q.SetLastTradePrice},
+		{amtdQuote.Open, q.SetOpenPrice},
+		{amtdQuote.High, q.SetHighPrice},
+		{amtdQuote.Low, q.SetLowPrice},
+		{amtdQuote.Close, q.SetClosePrice},
+		{amtdQuote.YearHigh, q.SetYearHigh},
+		{amtdQuote.YearLow, q.SetYearLow},
+	}
+	for _, p := range prices {
+		if p.value.Value == nil {
+			continue
+		}
+		if setErr := p.setter(p.value); setErr != nil && err == nil {
+			err = fmt.Errorf("%s: %s", amtdQuote.Symbol, setErr)
+		}
+	}
+	q.SetChange(financial.Money{Value: (&big.Rat{}).SetFloat64(float64(amtdQuote.Change))})
+
+	q.SetVolume(int64(amtdQuote.Volume))
+	bidSize, askSize := parseBidAskSize(amtdQuote.BidAskSize)
+	q.SetBidSize(bidSize)
+	q.SetAskSize(askSize)
+	q.SetLastSize(int32(amtdQuote.LastTradeSize))
+	q.SetRealTime(amtdQuote.RealTime)
+
+	return err
+}
+
 func (amtdQuote QuoteXML) NewOption() *option.Option {
 	o := option.NewNilOption()
 	amtdQuote.ProcessOption(o)
@@ -93,7 +137,7 @@ func (amtdQuote QuoteXML) NewOption() *option.Option {
 }
 
 func (amtdQuote QuoteXML) ProcessOption(o *option.Option) {
-	location, _ := time.LoadLocation(date.LocalLocation)
+	location, _ := date.Location()
 	exp, _ := date.New(time.Date(int(amtdQuote.ExpirationYear), time.Month(amtdQuote.ExpirationMonth), int(amtdQuote.ExpirationDay), 0, 0, 0, 0, location))
 
 	if strings.Contains(strings.Split(amtdQuote.Symbol, "_")[1], "C") {
@@ -105,6 +149,8 @@ func (amtdQuote QuoteXML) ProcessOption(o *option.Option) {
 	}
 
 	o.SetSymbol(amtdQuote.Symbol)
+	o.SetOptionTickerSymbol(amtdQuote.Symbol)
+	o.SetUnderlying(amtdQuote.UnderlyingSymbol)
 	o.SetStrike(float64(amtdQuote.StrikePrice))
 	o.SetExpirationDate(exp)
 	o.SetMultiplier(float64(amtdQuote.Multiplier))
@@ -113,9 +159,16 @@ func (amtdQuote QuoteXML) ProcessOption(o *option.Option) {
 
 	o.SetBid(amtdQuote.Bid)
 	o.SetAsk(amtdQuote.Ask)
-	//o.SetVolume(int64(amtdQuote.Volume))
+	o.SetVolume(int64(amtdQuote.Volume))
+	o.SetOpenInterest(int32(amtdQuote.OpenInterest))
+	bidSize, askSize := parseBidAskSize(amtdQuote.BidAskSize)
+	o.SetBidSize(bidSize)
+	o.SetAskSize(askSize)
 	o.SetDelta(float64(amtdQuote.Delta))
 	o.SetTheta(float64(amtdQuote.Theta))
 	o.SetGamma(float64(amtdQuote.Gamma))
 	o.SetVega(float64(amtdQuote.Vega))
+	o.SetRho(float64(amtdQuote.Rho))
+	// TD sends volatility as a percent
+	o.SetImpliedVolatility(float64(amtdQuote.ImpliedVolatility) / 100)
 }
@@ -22,10 +22,10 @@ package tdapi
 import (
 	"errors"
 	"fmt"
-	"math/big"
 	"time"
 
 	"github.com/marklaczynski/acidbath/dm/order"
+	"github.com/marklaczynski/acidbath/lib/financial"
 	"github.com/marklaczynski/acidbath/lib/orderconst"
 )
 
@@ -81,20 +81,20 @@ func (o *tdOrder) validateNewOptionTrade() error {
 		if o.order.SpecialInstructions() != orderconst.None {
 			return errors.New("Market ordertype must use no speical instructions")
 		}
-		if o.order.Price().Value.Cmp(big.NewRat(0, 1)) != 0 {
+		if !o.order.Price().IsZero() {
 			return errors.New("Market ordertype must have null or 0 price")
 		}
-		if o.order.ActivatePrice().Value.Cmp(big.NewRat(0, 1)) != 0 {
+		if !o.order.ActivatePrice().IsZero() {
 			return errors.New("Market ordertype must have null or 0 activatePrice")
 		}
 	}
 
 	if o.order.OrderType() == orderconst.Limit {
-		if o.order.Price().Value.Cmp(big.NewRat(0, 1)) < 0 {
+		if o.order.Price().Sign() < 0 {
 			return errors.New("Price must be greater than 0")
 		}
 
-		if o.order.ActivatePrice().Value.Cmp(big.NewRat(0, 1)) != 0 {
+		if !o.order.ActivatePrice().IsZero() {
 			return errors.New("Market ordertype must have null or 0 activatePrice")
 		}
 
@@ -117,11 +117,11 @@ func (o *tdOrder) validateNewOptionTrade() error {
 	}
 
 	if o.order.OrderType() == orderconst.StopMarket {
-		if o.order.ActivatePrice().Value.Cmp(big.NewRat(0, 1)) < 0 {
+		if o.order.ActivatePrice().Sign() < 0 {
 			return errors.New("Active price must be greater than 0")
 		}
 
-		if o.order.Price().Value.Cmp(big.NewRat(0, 1)) != 0 {
+		if !o.order.Price().IsZero() {
 			return errors.New("Stop Market ordertype must have null or 0 price")
 		}
 
@@ -135,11 +135,11 @@ func (o *tdOrder) validateNewOptionTrade() error {
 	}
 
 	if o.order.OrderType() == orderconst.StopLimit {
-		if o.order.Price().Value.Cmp(big.NewRat(0, 1)) < 0 {
+		if o.order.Price().Sign() < 0 {
 			return errors.New("Price must be greater than 0")
 		}
 
-		if o.order.ActivatePrice().Value.Cmp(big.NewRat(0, 1)) < 0 {
+		if o.order.ActivatePrice().Sign() < 0 {
 			return errors.New("Active price must be greater than 0")
 		}
 
@@ -152,6 +152,15 @@ func (o *tdOrder) validateNewOptionTrade() error {
 		}
 	}
 
+	// the tick schedule depends on the symbol, so only a price no option can trade at, a fraction of a cent, is
+	// rejected here and the rest is left to the exchange
+	if !financial.PennyAllOptionTicks.IsValid(o.order.Price()) {
+		return fmt.Errorf("Price %s is not a valid option increment", o.order.Price())
+	}
+	if !financial.PennyAllOptionTicks.IsValid(o.order.ActivatePrice()) {
+		return fmt.Errorf("Activate price %s is not a valid option increment", o.order.ActivatePrice())
+	}
+
 	logDebug.Printf("order: %#v\n", o)
 	logDebug.Printf("order: %#v\n", o.order)
 	if o.order.ExDay() != 0 || o.order.ExMonth() != 0 || o.order.ExYear() != 0 {
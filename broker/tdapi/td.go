@@ -30,6 +30,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"net/http"
 	"net/http/cookiejar"
@@ -43,12 +44,14 @@ import (
 
 	"log"
 
+	"github.com/marklaczynski/acidbath/broker/simfill"
 	"github.com/marklaczynski/acidbath/broker/tdapi/internal/amtd"
 	"github.com/marklaczynski/acidbath/broker/tdapi/tdstream"
 	"github.com/marklaczynski/acidbath/broker/tdapi/tdstream/acctactivityfield"
 	"github.com/marklaczynski/acidbath/broker/tdapi/tdstream/optrequestfield"
 	"github.com/marklaczynski/acidbath/broker/tdapi/tdstream/quoterequestfield"
 	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/commission"
 	"github.com/marklaczynski/acidbath/dm/optionchain"
 	"github.com/marklaczynski/acidbath/dm/optionchain/option"
 	"github.com/marklaczynski/acidbath/dm/order"
@@ -121,28 +124,73 @@ type Session struct {
 	orderStatusDone chan bool
 	endSession      chan bool
 
+	quoteChanMutex       sync.RWMutex
+	quoteUpdateChans     map[string]chan *asset.Quote
 	optChanMutex         sync.RWMutex
 	optionUpdateChans    map[string]chan *option.Option
 	balChanMutex         sync.RWMutex
 	portfolioUpdateChans map[string]chan *portfolio.Portfolio
 	ordChanMutex         sync.RWMutex
 	orderUpdateChans     map[string]chan *ordermessage.Message
+
+	// actual charges from fills, reconciled against the commission model
+	ledger *commission.Ledger
+
+	// orders are filled by simfill against the current quote instead of being sent to TD
+	paperTrading bool
+
+	// streamed quotes and options by symbol. TD only sends the fields that changed, so each update is merged into
+	// the last quote or option
+	quoteMutex sync.Mutex
+	quotes     map[string]*asset.Quote
+	options    map[string]*option.Option
+
+	// last retrieved portfolio, marked from the stream and updated with fills
+	portMutex  sync.Mutex
+	portfolio  *portfolio.Portfolio
+	markSource portfolio.MarkSource
 }
 
+// feeTolerance is how far (in dollars) the actual charges on a fill can be from the commission model before it is reported
+const feeTolerance = 0.01
+
 var (
 	logInfo  = log.New(mjlog.CreateInfoFile(), "INFO  [tdapi]: ", log.LstdFlags|log.Lshortfile)
 	logDebug = log.New(mjlog.CreateDebugFile(), "DEBUG [tdapi]: ", log.LstdFlags|log.Lshortfile)
 	logError = log.New(mjlog.CreateErrorFile(), "ERROR [tdapi]: ", log.LstdFlags|log.Lshortfile)
 )
 
+//Ledger returns the actual commissions and fees charged on fills during the session
+func (s *Session) Ledger() *commission.Ledger {
+	return s.ledger
+}
+
+//MarkSource returns the price positions are marked at from the stream
+func (s *Session) MarkSource() portfolio.MarkSource {
+	s.portMutex.Lock()
+	defer s.portMutex.Unlock()
+	return s.markSource
+}
+
+//SetMarkSource sets the price positions are marked at from the stream
+func (s *Session) SetMarkSource(ms portfolio.MarkSource) {
+	s.portMutex.Lock()
+	s.markSource = ms
+	s.portMutex.Unlock()
+}
+
 //New returns a pointer to the a new broker session
 func New() *Session {
 	s := &Session{
 		orderStatusDone:      make(chan bool),
 		endSession:           make(chan bool),
+		quoteUpdateChans:     make(map[string]chan *asset.Quote),
+		quotes:               make(map[string]*asset.Quote),
+		options:              make(map[string]*option.Option),
 		optionUpdateChans:    make(map[string]chan *option.Option),
 		portfolioUpdateChans: make(map[string]chan *portfolio.Portfolio),
 		orderUpdateChans:     make(map[string]chan *ordermessage.Message),
+		ledger:               commission.NewLedger(commission.DefaultSchedule()),
 	}
 
 	// initialize all the strategies
@@ -207,6 +255,30 @@ func opURL(op operation, sourceid string, version string, param ...string) strin
 	return ""
 }
 
+//RegisterQuoteUpdateChan returns a channel that receives the equity quotes streamed for id
+func (s *Session) RegisterQuoteUpdateChan(id string) chan *asset.Quote {
+	s.quoteChanMutex.Lock()
+	s.quoteUpdateChans[id] = make(chan *asset.Quote)
+	s.quoteChanMutex.Unlock()
+	return s.quoteUpdateChans[id]
+}
+
+//DeregisterQuoteUpdateChan closes and removes id's quote channel
+func (s *Session) DeregisterQuoteUpdateChan(id string) {
+	s.quoteChanMutex.Lock()
+	close(s.quoteUpdateChans[id])
+	delete(s.quoteUpdateChans, id)
+	s.quoteChanMutex.Unlock()
+}
+
+func (s *Session) notifyQuoteUpdate(q *asset.Quote) {
+	s.quoteChanMutex.RLock()
+	for _, v := range s.quoteUpdateChans {
+		v <- q.Copy()
+	}
+	s.quoteChanMutex.RUnlock()
+}
+
 func (s *Session) RegisterOptionUpdateChan(id string) chan *option.Option {
 	s.optChanMutex.Lock()
 	s.optionUpdateChans[id] = make(chan *option.Option)
@@ -295,8 +367,10 @@ func (s *Session) Login(loginid string, pass string) error {
 		}
 
 		var config struct {
-			SourceID string
-			Version  string
+			SourceID     string
+			Version      string
+			PaperTrading bool
+			StreamDebug  bool
 		}
 
 		if err = json.NewDecoder(file).Decode(&config); err != nil {
@@ -306,6 +380,10 @@ func (s *Session) Login(loginid string, pass string) error {
 
 		s.sourceID = config.SourceID
 		s.version = config.Version
+		s.paperTrading = config.PaperTrading
+
+		// logging every streamed tick is slow, so it's off unless the config turns it on
+		tdstream.SetDebug(config.StreamDebug)
 	}
 
 	loginParams := url.Values{"userid": {loginid}, "password": {pass}, "sourceID": {s.sourceID}, "version": {s.version}}
@@ -498,6 +576,8 @@ func (s *Session) RetrievePortfolio(portfolioParam *portfolio.Portfolio) error {
 		portfolioParam.AddPosition(asset.SavingsType, tmpPos)
 	}
 
+	s.replacePortfolio(portfolioParam)
+
 	//debugMarshal(s.amtdPortfolio)
 	go func() {
 		logDebug.Printf("sending portfolio updates on chan: %#v\n", portfolioParam)
@@ -544,14 +624,9 @@ func (s *Session) RetrieveSnapshot(symbol string, assetType asset.AssetType, sec
 	case asset.EquityType:
 		a := security.(*asset.Stock)
 
-		a.SetDescription(s.amtdSnapshot.QuoteList.Quote[FirstResult].Description)
-		a.SetBidPrice(s.amtdSnapshot.QuoteList.Quote[FirstResult].Bid)
-		a.SetAskPrice(s.amtdSnapshot.QuoteList.Quote[FirstResult].Ask)
-		a.SetLastTradePrice(s.amtdSnapshot.QuoteList.Quote[FirstResult].Last)
-		//a.FiftyTwoWeekLow = s.amtdSnapshot.QuoteList.Quote[FirstResult].YearLow
-		//a.FiftyTwoWeekHigh = s.amtdSnapshot.QuoteList.Quote[FirstResult].YearHigh
-		//a.BidSize, _ = strconv.ParseInt(strings.Split(s.amtdSnapshot.QuoteList.Quote[FirstResult].BidAskSize, "X")[0], 10, 64)
-		//a.AskSize, _ = strconv.ParseInt(strings.Split(s.amtdSnapshot.QuoteList.Quote[FirstResult].BidAskSize, "X")[1], 10, 64)
+		if err := s.amtdSnapshot.QuoteList.Quote[FirstResult].ProcessQuote(&a.Quote); err != nil {
+			logError.Printf("Error processing quote: %s\n", err)
+		}
 
 	case asset.OptionType:
 		/*
@@ -788,6 +863,14 @@ func (s *Session) retrieveOptionChain(stock *asset.Stock) error {
 				o.SetGamma(float64(optStrike.Call.Gamma))
 				o.SetTheta(float64(optStrike.Call.Theta))
 				o.SetVega(float64(optStrike.Call.Vega))
+				o.SetRho(float64(optStrike.Call.Rho))
+				o.SetVolume(int64(optStrike.Call.Volume))
+				o.SetOpenInterest(int32(optStrike.Call.OpenInterest))
+				bidSize, askSize := optStrike.Call.BidAskSizes()
+				o.SetBidSize(bidSize)
+				o.SetAskSize(askSize)
+				// TD sends volatility as a percent
+				o.SetImpliedVolatility(float64(optStrike.Call.ImpliedVolatility) / 100)
 
 				if o.Error() != nil {
 					logError.Printf("Error constructing option: %s\n", o.Error())
@@ -821,6 +904,14 @@ func (s *Session) retrieveOptionChain(stock *asset.Stock) error {
 				o.SetGamma(float64(optStrike.Put.Gamma))
 				o.SetTheta(float64(optStrike.Put.Theta))
 				o.SetVega(float64(optStrike.Put.Vega))
+				o.SetRho(float64(optStrike.Put.Rho))
+				o.SetVolume(int64(optStrike.Put.Volume))
+				o.SetOpenInterest(int32(optStrike.Put.OpenInterest))
+				bidSize, askSize := optStrike.Put.BidAskSizes()
+				o.SetBidSize(bidSize)
+				o.SetAskSize(askSize)
+				// TD sends volatility as a percent
+				o.SetImpliedVolatility(float64(optStrike.Put.ImpliedVolatility) / 100)
 
 				if o.Error() != nil {
 
@@ -916,6 +1007,8 @@ func (s *Session) AddStockOptionsToStream(stock *asset.Stock) error {
 		return fmt.Errorf("Error streaming option for stock: %s\n", err)
 	}
 
+	s.cacheQuote(&stock.Quote)
+	s.cacheOptions(stock.OptionChain())
 	err = s.streamStock(stock.Symbol())
 	if err != nil {
 		logInfo.Printf("Error streaming stock: %s with error:%s\n", stock.Symbol(), err)
@@ -951,6 +1044,13 @@ func (s *Session) RemoveStockOptionsFromStream(stock *asset.Stock) error {
 		return fmt.Errorf("Error unsubscribing quote from stream for %s", stock.Symbol())
 	}
 
+	s.quoteMutex.Lock()
+	delete(s.quotes, stock.Symbol())
+	for _, symbol := range stock.OptionChain().OptionSymbols() {
+		delete(s.options, symbol)
+	}
+	s.quoteMutex.Unlock()
+
 	return nil
 }
 
@@ -1184,19 +1284,39 @@ func (cf streamingCommand) String() string {
 func fields(sid tdstream.StreamingID) string {
 	switch sid {
 	case tdstream.Quote:
-		// for right now, i'm only going to stream symbol,bid,ask,(delta)... future is to make this configurable
-		return fmt.Sprintf("%d+%d+%d", quoterequestfield.Symbol, quoterequestfield.Bid, quoterequestfield.Ask)
+		return fmt.Sprintf("%d+%d+%d+%d+%d+%d+%d+%d+%d+%d+%d+%d+%d+%d+%d+%d", quoterequestfield.Symbol,
+			quoterequestfield.Bid,
+			quoterequestfield.Ask,
+			quoterequestfield.Last,
+			quoterequestfield.BidSize,
+			quoterequestfield.AskSize,
+			quoterequestfield.Volume,
+			quoterequestfield.LastSize,
+			quoterequestfield.High,
+			quoterequestfield.Low,
+			quoterequestfield.Close,
+			quoterequestfield.Open,
+			quoterequestfield.Change,
+			quoterequestfield.WeekHigh52,
+			quoterequestfield.WeekLow52,
+			quoterequestfield.ExchangeName)
 	case tdstream.TimeSale:
 	case tdstream.Response:
 	case tdstream.Option:
-		return fmt.Sprintf("%d+%d+%d+%d+%d+%d+%d+%d", optrequestfield.Symbol,
+		return fmt.Sprintf("%d+%d+%d+%d+%d+%d+%d+%d+%d+%d+%d+%d+%d+%d", optrequestfield.Symbol,
 			optrequestfield.Bid,
 			optrequestfield.Ask,
 			optrequestfield.Last,
+			optrequestfield.Volume,
+			optrequestfield.OpenInterest,
+			optrequestfield.Volatility,
+			optrequestfield.BidSize,
+			optrequestfield.AskSize,
 			optrequestfield.DeltaIndex,
 			optrequestfield.GammaIndex,
 			optrequestfield.ThetaIndex,
-			optrequestfield.VegaIndex)
+			optrequestfield.VegaIndex,
+			optrequestfield.RhoIndex)
 	case tdstream.ActivesNYSE:
 	case tdstream.ActivesNASDAQ:
 	case tdstream.ActivesOTCBB:
@@ -1251,7 +1371,22 @@ func createClient(rawurl string, cookiesParam []*http.Cookie, isLoggedIn bool, s
 }
 
 func (s *Session) processOrderMessage(message *ordermessage.Message) {
-	logInfo.Printf("processOrderMessage: %s\n", message.OrderID())
+	logInfo.Printf("processOrderMessage: %s %s\n", message.OrderID(), message.OrderEvent())
+	if message.IsExecution() {
+		logInfo.Printf("execution: %s %s %.0f @ %s, leaves %.0f\n", message.Symbol(), message.Instruction(), message.FillQuantity(), message.FillPrice(), message.LeavesQuantity())
+
+		if r, ok := s.ledger.RecordExecution(message, s.contractMultiplier(message.Symbol())); ok && math.Abs(r.Difference()) > feeTolerance {
+			logError.Printf("charges on %s (%.2f) differ from commission model (%s)\n", r.OrderID, r.Actual, r.Estimated)
+		}
+
+		s.portMutex.Lock()
+		if s.portfolio != nil {
+			pos := s.portfolio.ApplyFill(message)
+			logInfo.Printf("%s now %.0f %s, %s\n", pos.Symbol(), pos.Quantity(), pos.PositionType(), pos.PnL())
+			s.pushPortfolioLocked()
+		}
+		s.portMutex.Unlock()
+	}
 
 	s.notifyOrderUpdate(message)
 
@@ -1262,8 +1397,148 @@ func (s *Session) processOrderMessage(message *ordermessage.Message) {
 	*/
 }
 
-func (s *Session) updateOption(newOptionData *option.Option) {
-	logInfo.Printf("updateOption")
+//contractMultiplier returns the shares per contract of symbol from the streamed option or the position held in it.
+//It returns 0, which the commission model charges as 100, when the session knows neither
+func (s *Session) contractMultiplier(symbol string) float64 {
+	s.quoteMutex.Lock()
+	o, ok := s.options[symbol]
+	var multiplier float64
+	if ok {
+		multiplier = o.Multiplier()
+	}
+	s.quoteMutex.Unlock()
+	if multiplier > 0 {
+		return multiplier
+	}
+
+	s.portMutex.Lock()
+	defer s.portMutex.Unlock()
+	if s.portfolio != nil {
+		if pos, ok := s.portfolio.FindPosition(symbol); ok {
+			return pos.Multiplier()
+		}
+	}
+	return 0
+}
+
+//CachedPortfolio returns a copy of the portfolio last retrieved, as marked from the stream and updated with fills,
+//without calling TD. It returns false until RetrievePortfolio has been called
+func (s *Session) CachedPortfolio() (*portfolio.Portfolio, bool) {
+	s.portMutex.Lock()
+	defer s.portMutex.Unlock()
+
+	if s.portfolio == nil {
+		return nil, false
+	}
+	return s.portfolio.Copy(), true
+}
+
+//replacePortfolio caches a portfolio freshly retrieved from the broker. The marks and P&L tracked from the stream
+//and fills are carried over from the cached portfolio, and the caller keeps its own copy
+func (s *Session) replacePortfolio(portfolioParam *portfolio.Portfolio) {
+	s.portMutex.Lock()
+	defer s.portMutex.Unlock()
+
+	portfolioParam.CarrySessionState(s.portfolio)
+	s.portfolio = portfolioParam.Copy()
+}
+
+//updatePositionMark marks the portfolio's position in symbol and pushes the portfolio if it holds symbol
+func (s *Session) updatePositionMark(symbol string, bid financial.Price, ask financial.Price, last financial.Price) {
+	s.portMutex.Lock()
+	defer s.portMutex.Unlock()
+
+	if s.portfolio == nil {
+		return
+	}
+	if s.portfolio.UpdateMark(symbol, portfolio.MarkPrice(s.markSource, bid, ask, last)) {
+		s.pushPortfolioLocked()
+	}
+}
+
+//pushPortfolioLocked sends a snapshot of the cached portfolio to the portfolio update channels without waiting
+//for the receivers. The caller must hold portMutex
+func (s *Session) pushPortfolioLocked() {
+	snapshot := s.portfolio.Copy()
+	go s.notifyPortfolioUpdate(snapshot)
+}
+
+//updateQuote merges a streamed quote into the last quote for its symbol, marks the portfolio's position in the
+//equity and forwards the merged quote to the quote channels
+func (s *Session) updateQuote(newQuoteData *asset.Quote, fields asset.QuoteField) {
+	// every streamed tick comes through here, only log it when the stream is being debugged
+	if tdstream.Debug() {
+		logDebug.Printf("updateQuote")
+	}
+
+	if newQuoteData == nil || newQuoteData.Symbol() == "" {
+		return
+	}
+
+	s.quoteMutex.Lock()
+	cached, ok := s.quotes[newQuoteData.Symbol()]
+	if !ok {
+		cached = asset.NewNilQuote()
+		s.quotes[newQuoteData.Symbol()] = cached
+	}
+	cached.Merge(newQuoteData, fields)
+	merged := cached.Copy()
+	s.quoteMutex.Unlock()
+
+	s.updatePositionMark(merged.Symbol(), merged.Price(asset.BidField), merged.Price(asset.AskField), merged.Price(asset.LastField))
+
+	go s.notifyQuoteUpdate(merged)
+}
+
+//cacheQuote seeds the streamed quote for a symbol, so the first updates from the stream are merged into a full quote
+func (s *Session) cacheQuote(q *asset.Quote) {
+	s.quoteMutex.Lock()
+	defer s.quoteMutex.Unlock()
+
+	s.quotes[q.Symbol()] = q.Copy()
+}
+
+//cacheOptions seeds the streamed options of a chain, so the first updates from the stream are merged into full options
+func (s *Session) cacheOptions(oc *optionchain.OptionChain) {
+	s.quoteMutex.Lock()
+	defer s.quoteMutex.Unlock()
+
+	for _, symbol := range oc.OptionSymbols() {
+		if o := oc.Option(symbol); o != nil {
+			s.options[symbol] = o.Copy()
+		}
+	}
+}
+
+//mergeOption merges a streamed option into the last option for its symbol and returns a copy of the result
+func (s *Session) mergeOption(newOptionData *option.Option, fields option.OptionField) *option.Option {
+	s.quoteMutex.Lock()
+	defer s.quoteMutex.Unlock()
+
+	cached, ok := s.options[newOptionData.OptionTickerSymbol()]
+	if !ok {
+		cached = option.NewNilOption()
+		s.options[newOptionData.OptionTickerSymbol()] = cached
+	}
+	cached.Merge(newOptionData, fields)
+	return cached.Copy()
+}
+
+//updateOption merges a streamed option into the last option for its symbol, marks the portfolio's position in it
+//and forwards the merged option to the strategies and the option channels
+func (s *Session) updateOption(newOptionData *option.Option, fields option.OptionField) {
+	// every streamed tick comes through here, only log it when the stream is being debugged
+	if tdstream.Debug() {
+		logDebug.Printf("updateOption")
+	}
+
+	if newOptionData != nil && newOptionData.OptionTickerSymbol() != "" {
+		newOptionData = s.mergeOption(newOptionData, fields)
+	}
+
+	if newOptionData != nil {
+		s.updatePositionMark(newOptionData.OptionTickerSymbol(), newOptionData.BidPrice(), newOptionData.AskPrice(), newOptionData.LastPrice())
+	}
 
 	go func() {
 
@@ -1316,6 +1591,7 @@ func streamParser(s *Session) {
 	streamReader := tdstream.NewDecoder(s.streamingBody)
 
 	sidHandler := &tdstream.SidHandlers{
+		QuoteCallback:           s.updateQuote,
 		OptionCallback:          s.updateOption,
 		AccountActivityCallback: s.processOrderMessage,
 	}
@@ -1426,7 +1702,7 @@ func postRequest(rawurl string, postParams url.Values, v interface{}, isLoggedIn
 
 	switch t := v.(type) {
 	case *[]asset.PriceHistoryType:
-		logDebug.Printf("pricehistory type parsing a %t", t)
+		logDebug.Printf("pricehistory type parsing a %T", t)
 
 		r := bufio.NewReader(resp.Body)
 
@@ -1455,14 +1731,14 @@ func postRequest(rawurl string, postParams url.Values, v interface{}, isLoggedIn
 			}
 
 			for currValIdx = 0; currValIdx < numValues; currValIdx++ {
-				_ = financial.Money{(&big.Rat{}).SetFloat64(float64(tdstream.ReadFloat32(r)))} // open
-				_ = financial.Money{(&big.Rat{}).SetFloat64(float64(tdstream.ReadFloat32(r)))} // high
-				_ = financial.Money{(&big.Rat{}).SetFloat64(float64(tdstream.ReadFloat32(r)))} //low
+				openPrice := financial.Money{(&big.Rat{}).SetFloat64(float64(tdstream.ReadFloat32(r)))}
+				highPrice := financial.Money{(&big.Rat{}).SetFloat64(float64(tdstream.ReadFloat32(r)))}
+				lowPrice := financial.Money{(&big.Rat{}).SetFloat64(float64(tdstream.ReadFloat32(r)))}
 				closePrice := financial.Money{(&big.Rat{}).SetFloat64(float64(tdstream.ReadFloat32(r)))}
-				_ = tdstream.ReadFloat32(r) // volume
+				volume := float64(tdstream.ReadFloat32(r))
 				timeStamp := time.Unix(0, tdstream.ReadInt64(r)*int64(time.Millisecond))
 
-				currHistoricalPrice := asset.NewPriceHistoryPoint(closePrice, timeStamp)
+				currHistoricalPrice := asset.NewPriceHistoryBar(openPrice, highPrice, lowPrice, closePrice, volume, timeStamp)
 
 				logDebug.Printf("price data added: %s\n", currHistoricalPrice)
 				*localHistoricalPrice = append(*localHistoricalPrice, currHistoricalPrice)
@@ -1480,7 +1756,7 @@ func postRequest(rawurl string, postParams url.Values, v interface{}, isLoggedIn
 		}
 
 	case *asset.ImpliedVolatilityTypeSlice:
-		logDebug.Printf("voldata type parsing a %t", t)
+		logDebug.Printf("voldata type parsing a %T", t)
 
 		r := bufio.NewReader(resp.Body)
 
@@ -1579,23 +1855,30 @@ func debugMarshal(data interface{}) {
 //ErrOrderValidation represents an error when validation of order structure fails brokerage rules.
 var ErrOrderValidation = errors.New("Validating order failed")
 
-//SendSingleLegOptionTrade sends the order to TD. It always validates the order before sending request.
+//SendSingleLegOptionTrade sends the order to TD. It always validates the order before sending request,
+//paper orders included so they are held to the same rules as live ones
 func (s *Session) SendSingleLegOptionTrade(order *order.Order) error {
 	logInfo.Printf("SendOptionTrade\n")
 
 	s.Lock()
-	defer s.Unlock()
-
 	tdo := &tdOrder{
-		accountID: strconv.Itoa(int(s.amtdLogin.Login.Accounts[0].AccountID)),
+		accountID: s.accountID(),
 		order:     order,
 	}
+	s.Unlock()
 
 	if err := tdo.validate(); err != nil {
 		logError.Printf("Validating order failed: %s", err)
 		return ErrOrderValidation
 	}
 
+	if s.isPaperTrading() {
+		return s.simulateOrder(order)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
 	orderString := tdo.orderString()
 	logDebug.Printf("orderString: %s", orderString)
 
@@ -1613,6 +1896,68 @@ func (s *Session) SendSingleLegOptionTrade(order *order.Order) error {
 		return fmt.Errorf("Error from TD: %s\n", s.amtdOrder.OrderWrapper.Error)
 	}
 
+	order.SetOrderID(s.amtdOrder.OrderWrapper.Order.OrderID)
+	logInfo.Printf("Order %s acknowledged with order id %s\n", order.ClientOrderID(), order.OrderID())
+
+	return nil
+}
+
+//accountID returns the id of the first account of the login, "" before logging in. The caller must hold the lock
+func (s *Session) accountID() string {
+	if s.amtdLogin == nil || len(s.amtdLogin.Login.Accounts) == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(s.amtdLogin.Login.Accounts[0].AccountID))
+}
+
+//PreviewOrder estimates the premium and fees of an order at the option's current quote, using the session's commission model.
+//Limit orders are priced at their limit, and market orders at the far side of the quote
+func (s *Session) PreviewOrder(o *order.Order) (commission.Preview, error) {
+	logInfo.Printf("PreviewOrder: %s\n", o.Symbol())
+
+	opt := option.NewNilOption()
+	if err := s.RetrieveSnapshot(o.Symbol(), asset.OptionType, opt); err != nil {
+		return commission.Preview{}, fmt.Errorf("Unable to quote %s for preview: %s", o.Symbol(), err)
+	}
+
+	var price float64
+	switch {
+	case o.OrderType() == orderconst.Limit || o.OrderType() == orderconst.StopLimit:
+		price = o.Price().Float64()
+	case commission.Instruction(o.Action()) == orderconst.Buy:
+		price = opt.Ask().Float64()
+	default:
+		price = opt.Bid().Float64()
+	}
+
+	return commission.PreviewOrder(o, s.ledger.Model(), price, opt.Multiplier()), nil
+}
+
+func (s *Session) isPaperTrading() bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.paperTrading
+}
+
+//simulateOrder fills an order against the option's current quote, and processes the fill as if it came from the stream
+func (s *Session) simulateOrder(o *order.Order) error {
+	opt := option.NewNilOption()
+	if err := s.RetrieveSnapshot(o.Symbol(), asset.OptionType, opt); err != nil {
+		logError.Printf("Unable to quote %s for a simulated fill: %s\n", o.Symbol(), err)
+		return fmt.Errorf("Unable to quote %s for a simulated fill: %s", o.Symbol(), err)
+	}
+
+	m, err := simfill.Fill(o, opt, s.ledger.Model())
+	if err != nil {
+		logError.Printf("Simulated fill of %s failed: %s\n", o.Symbol(), err)
+		return fmt.Errorf("Simulated fill of %s failed: %s", o.Symbol(), err)
+	}
+
+	o.SetOrderID(m.OrderID())
+	logInfo.Printf("Order %s filled by simulation at %s\n", o.ClientOrderID(), m.FillPrice())
+
+	go s.processOrderMessage(m)
+
 	return nil
 }
 
@@ -0,0 +1,234 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package tdapi
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/order"
+	"github.com/marklaczynski/acidbath/dm/ordermessage"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/lib/financial"
+	"github.com/marklaczynski/acidbath/lib/orderconst"
+)
+
+// brokerPortfolio is the portfolio as BalancesAndPositions reports it, holding quantity shares of XYZ
+func brokerPortfolio(quantity float64) *portfolio.Portfolio {
+	p := portfolio.NewPortfolio()
+	if quantity == 0 {
+		return p
+	}
+	pos := portfolio.NewPosition()
+	pos.SetSymbol("XYZ")
+	pos.SetAssetType(asset.EquityType)
+	pos.SetPositionType(portfolio.LongPosition)
+	pos.SetQuantity(quantity)
+	pos.SetAveragePrice(financial.NewMoney(50))
+	pos.SetClosePrice(financial.NewMoney(50))
+	pos.SetUnderlyingStock(asset.NewStock("XYZ"))
+	p.AddPosition(asset.EquityType, pos)
+	return p
+}
+
+func sell(orderid string, quantity float64, price float64) *ordermessage.Message {
+	m := ordermessage.New(orderid, orderconst.OrderFill)
+	m.SetSymbol("XYZ")
+	m.SetInstruction(orderconst.Sell)
+	m.SetOriginalQuantity(quantity)
+	m.SetFillQuantity(quantity)
+	m.SetFillPrice(financial.NewMoney(price))
+	return m
+}
+
+func TestRealizedPnLSurvivesRefresh(t *testing.T) {
+	s := New()
+	s.replacePortfolio(brokerPortfolio(100))
+
+	s.processOrderMessage(sell("1", 40, 55))
+
+	// placing the next order refreshes the positions into a new portfolio, as the risk wrapper does
+	next := brokerPortfolio(60)
+	s.replacePortfolio(next)
+	for name, p := range map[string]*portfolio.Portfolio{"caller": next, "cached": s.portfolio} {
+		if pnl := p.PnL(); math.Abs(pnl.Realized-200) > 1e-6 {
+			t.Errorf("%s portfolio realized %.2f after refresh, want 200", name, pnl.Realized)
+		}
+	}
+
+	// closing out the rest, the broker stops reporting the position
+	s.processOrderMessage(sell("2", 60, 45))
+	s.replacePortfolio(brokerPortfolio(0))
+	pos, ok := s.portfolio.FindPosition("XYZ")
+	if !ok {
+		t.Fatalf("closed position dropped from the cached portfolio")
+	}
+	if pos.Quantity() != 0 || math.Abs(pos.RealizedPnL()-(-100)) > 1e-6 {
+		t.Errorf("closed position got %.0f shares, realized %.2f, want 0 and -100", pos.Quantity(), pos.RealizedPnL())
+	}
+}
+
+func TestStreamedQuoteMerge(t *testing.T) {
+	s := New()
+	quotes := s.RegisterQuoteUpdateChan("test")
+	defer s.DeregisterQuoteUpdateChan("test")
+
+	snapshot := asset.NewNilQuote()
+	snapshot.SetSymbol("XYZ")
+	snapshot.SetBidPrice(financial.NewMoney(10.00))
+	snapshot.SetAskPrice(financial.NewMoney(10.02))
+	snapshot.SetLastTradePrice(financial.NewMoney(10.01))
+	snapshot.SetVolume(5000)
+	s.cacheQuote(snapshot)
+
+	// a bid only tick, the other fields in the frame are zero
+	tick := asset.NewNilQuote()
+	tick.SetSymbol("XYZ")
+	tick.SetBidPrice(financial.NewMoney(10.01))
+	tick.SetRealTime(true)
+	s.updateQuote(tick, asset.SymbolField|asset.BidField|asset.RealTimeField)
+
+	select {
+	case q := <-quotes:
+		if q.Price(asset.BidField) != 100100 || q.Price(asset.AskField) != 100200 || q.Price(asset.LastField) != 100100 ||
+			q.Volume() != 5000 || !q.RealTime() {
+			t.Errorf("merged quote got bid %s ask %s last %s volume %d", q.BidPrice(), q.AskPrice(), q.LastTradePrice(), q.Volume())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no quote update sent")
+	}
+}
+
+func TestStreamedOptionMerge(t *testing.T) {
+	s := New()
+
+	o, err := option.NewOption("XYZ", 50, time.Now().AddDate(0, 1, 0), option.CALL, 100)
+	if err != nil {
+		t.Fatalf("Error setting up test: %s", err)
+	}
+	o.SetOptionTickerSymbol("XYZ_C50")
+	o.SetBid(financial.NewMoney(1.00))
+	o.SetAsk(financial.NewMoney(1.10))
+	oc := optionchain.NewOptionChain("XYZ")
+	if err := oc.AddOption(o); err != nil {
+		t.Fatalf("Error setting up test: %s", err)
+	}
+	s.cacheOptions(oc)
+
+	p := portfolio.NewPortfolio()
+	pos := portfolio.NewPosition()
+	pos.SetSymbol("XYZ_C50")
+	pos.SetAssetType(asset.OptionType)
+	pos.SetPositionType(portfolio.LongPosition)
+	pos.SetQuantity(1)
+	pos.SetUnderlyingOption(o.Copy())
+	p.AddPosition(asset.OptionType, pos)
+	s.replacePortfolio(p)
+
+	// a bid only tick, the ask in the frame is zero
+	tick := option.NewNilOption()
+	tick.SetOptionTickerSymbol("XYZ_C50")
+	tick.SetBid(financial.NewMoney(1.02))
+	s.updateOption(tick, option.SymbolField|option.BidField)
+
+	held, _ := s.portfolio.FindPosition("XYZ_C50")
+	if math.Abs(held.Mark()-1.06) > 1e-9 {
+		t.Errorf("position marked at %.4f, want the merged mid 1.06", held.Mark())
+	}
+	if merged := s.options["XYZ_C50"]; merged.BidPrice() != 10200 || merged.AskPrice() != 11000 || merged.Strike() != 50 {
+		t.Errorf("merged option got bid %s ask %s strike %.2f", merged.BidPrice(), merged.AskPrice(), merged.Strike())
+	}
+}
+
+func TestValidateOptionPriceIncrement(t *testing.T) {
+	tests := []struct {
+		price string
+		valid bool
+	}{
+		{"1.07", true},
+		{"5.23", true}, // SPY, QQQ and IWM options trade in pennies above $3
+		{"5.235", false},
+	}
+
+	for _, test := range tests {
+		o := order.New()
+		o.SetSymbol("SPY_011519C250")
+		o.SetAction(orderconst.BuyToOpen)
+		o.SetOrderType(orderconst.Limit)
+		o.SetExpire(orderconst.Day)
+		o.SetQuantity(1)
+		price, err := financial.ParseMoney(test.price)
+		if err != nil {
+			t.Fatalf("Error setting up test: %s", err)
+		}
+		o.SetPrice(price)
+
+		err = (&tdOrder{accountID: "123", order: o}).validate()
+		if (err == nil) != test.valid {
+			t.Errorf("%s got %v, want valid %t", test.price, err, test.valid)
+		}
+	}
+}
+
+func TestPaperOrdersAreValidated(t *testing.T) {
+	s := New()
+	s.paperTrading = true
+
+	o := order.New()
+	o.SetSymbol("XYZ_C50")
+	o.SetAction(orderconst.BuyToOpen)
+	o.SetOrderType(orderconst.Market)
+	o.SetQuantity(0)
+
+	// validation fails before the simulation asks TD for a quote
+	if err := s.SendSingleLegOptionTrade(o); err != ErrOrderValidation {
+		t.Errorf("invalid paper order got %v, want %v", err, ErrOrderValidation)
+	}
+}
+
+func TestContractMultiplier(t *testing.T) {
+	s := New()
+
+	mini := option.NewNilOption()
+	mini.SetOptionTickerSymbol("XYZ7_C50")
+	mini.SetMultiplier(10)
+	s.options[mini.OptionTickerSymbol()] = mini
+
+	p := portfolio.NewPortfolio()
+	pos := portfolio.NewPosition()
+	pos.SetSymbol("XYZ_C55")
+	pos.SetAssetType(asset.OptionType)
+	pos.SetQuantity(1)
+	p.AddPosition(asset.OptionType, pos)
+	s.replacePortfolio(p)
+
+	if m := s.contractMultiplier("XYZ7_C50"); m != 10 {
+		t.Errorf("streamed mini option got %.0f, want 10", m)
+	}
+	if m := s.contractMultiplier("XYZ_C55"); m != 100 {
+		t.Errorf("held option got %.0f, want 100", m)
+	}
+	if m := s.contractMultiplier("ABC_C10"); m != 0 {
+		t.Errorf("unknown option got %.0f, want 0", m)
+	}
+}
@@ -20,7 +20,12 @@ package acctactivityfield
 
 import (
 	"encoding/xml"
+	"math/big"
+	"time"
 
+	"github.com/marklaczynski/acidbath/dm/ordermessage"
+	"github.com/marklaczynski/acidbath/lib/financial"
+	"github.com/marklaczynski/acidbath/lib/orderconst"
 	"github.com/marklaczynski/acidbath/lib/types"
 )
 
@@ -255,3 +260,58 @@ type executionInformationXML struct {
 	LeavesQuantity        float32
 	ID                    string
 }
+
+//NewMessage maps the order portion of an account activity message into an ordermessage.Message for event
+func (o orderXML) NewMessage(group orderGroupIDXML, event orderconst.OrderEvent) *ordermessage.Message {
+	m := ordermessage.New(o.OrderKey, event)
+
+	m.SetSymbol(o.Security.Symbol)
+	m.SetUnderlying(o.Security.SymbolUnderlying)
+	m.SetInstruction(instruction(o.OrderInstructions))
+	m.SetOriginalQuantity(float64(o.OriginalQuantity))
+	m.SetCancelledQuantity(float64(o.CancelledQuantity))
+	m.SetLimitPrice(money(o.OrderPricing.Limit))
+	m.SetEnteredTime(parseTime(o.OrderEnteredDateTime))
+	m.SetActivityTime(parseTime(group.ActivityTimestamp))
+	m.SetRejectCode(o.RejectCode)
+	m.SetRejectReason(o.RejectReason)
+
+	// RemainingQuantity is only populated on some message types, ExecutionInformation wins when it's present
+	m.SetLeavesQuantity(float64(o.RemainingQuantity))
+	if o.ExecutionInformation.Type != "" || o.ExecutionInformation.Quantity != 0 {
+		m.SetFillQuantity(float64(o.ExecutionInformation.Quantity))
+		m.SetFillPrice(money(o.ExecutionInformation.ExecutionPrice))
+		m.SetLeavesQuantity(float64(o.ExecutionInformation.LeavesQuantity))
+		m.SetExecutionID(o.ExecutionInformation.ID)
+		m.SetExecutionTime(parseTime(o.ExecutionInformation.Timestamp))
+	}
+
+	for _, c := range o.Charges.Charge {
+		m.AddCharge(ordermessage.NewCharge(c.Type, money(c.Amount)))
+	}
+
+	return m
+}
+
+func instruction(s string) orderconst.OrderInstruction {
+	switch s {
+	case "Buy", "BUY", "B":
+		return orderconst.Buy
+	case "Sell", "SELL", "S":
+		return orderconst.Sell
+	}
+	return orderconst.InvalidOrderInstruction
+}
+
+func money(f float32) financial.Money {
+	return financial.Money{Value: new(big.Rat).SetFloat64(float64(f))}
+}
+
+//parseTime parses the timestamps sent by TD (ie 2016-06-21T10:43:13.123-04:00). Returns a zero time if it cannot be parsed
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
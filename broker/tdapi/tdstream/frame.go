@@ -0,0 +1,149 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package tdstream
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+/*
+	frame holds the payload of one streamed message and reads its fields in place. The decoder reuses the same
+	frame for every message, and the fields are read with encoding/binary's byte order functions instead of
+	binary.Read, which goes through reflection and allocates for every field. Only strings allocate.
+
+	Like the Read* functions, reading past the end of the payload panics (see the design decision in tdstream.go).
+*/
+type frame struct {
+	buf []byte
+	off int
+}
+
+//fill reads the next n bytes of r into the frame, reusing its buffer when it is big enough
+func (f *frame) fill(r io.Reader, n int) error {
+	if cap(f.buf) < n {
+		f.buf = make([]byte, n)
+	}
+	f.buf = f.buf[:n]
+	f.off = 0
+
+	_, err := io.ReadFull(r, f.buf)
+	return err
+}
+
+//next returns the next n bytes of the payload
+func (f *frame) next(n int) []byte {
+	if n < 0 || f.off+n > len(f.buf) {
+		logError.Printf("frame read of %d bytes at %d overruns the %d byte payload\n", n, f.off, len(f.buf))
+		panic("Unexpected failure")
+	}
+	b := f.buf[f.off : f.off+n]
+	f.off += n
+	return b
+}
+
+func (f *frame) readBool() bool {
+	return f.readInt8() != 0
+}
+
+func (f *frame) readInt8() int8 {
+	return int8(f.next(1)[0])
+}
+
+func (f *frame) readInt16() int16 {
+	return int16(binary.BigEndian.Uint16(f.next(2)))
+}
+
+func (f *frame) readInt32() int32 {
+	return int32(binary.BigEndian.Uint32(f.next(4)))
+}
+
+func (f *frame) readInt64() int64 {
+	return int64(binary.BigEndian.Uint64(f.next(8)))
+}
+
+func (f *frame) readFloat32() float32 {
+	return math.Float32frombits(binary.BigEndian.Uint32(f.next(4)))
+}
+
+func (f *frame) readFloat64() float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(f.next(8)))
+}
+
+//readString reads a string of length bytes
+func (f *frame) readString(length int) string {
+	return string(f.next(length))
+}
+
+//skipString skips a string field without allocating it
+func (f *frame) skipString() {
+	f.next(int(f.readInt16()))
+}
+
+//readFull reads len(b) bytes from r, panicking on failure like the rest of the Read* functions
+func readFull(r io.Reader, b []byte) []byte {
+	if _, err := io.ReadFull(r, b); err != nil {
+		logError.Printf("read of %d bytes failed: %s\n", len(b), err)
+		panic("Unexpected failure")
+	}
+	return b
+}
+
+// These functions should become public library for myself
+func ReadBool(r io.Reader) bool {
+	if ReadInt8(r) == 0 {
+		return false
+	}
+	return true
+}
+
+func ReadInt8(r io.Reader) int8 {
+	var b [1]byte
+	return int8(readFull(r, b[:])[0])
+}
+
+func ReadInt16(r io.Reader) int16 {
+	var b [2]byte
+	return int16(binary.BigEndian.Uint16(readFull(r, b[:])))
+}
+
+func ReadInt32(r io.Reader) int32 {
+	var b [4]byte
+	return int32(binary.BigEndian.Uint32(readFull(r, b[:])))
+}
+
+func ReadInt64(r io.Reader) int64 {
+	var b [8]byte
+	return int64(binary.BigEndian.Uint64(readFull(r, b[:])))
+}
+
+func ReadString(r io.Reader, length int) string {
+	return string(readFull(r, make([]byte, length)))
+}
+
+func ReadFloat32(r io.Reader) float32 {
+	var b [4]byte
+	return math.Float32frombits(binary.BigEndian.Uint32(readFull(r, b[:])))
+}
+
+func ReadFloat64(r io.Reader) float64 {
+	var b [8]byte
+	return math.Float64frombits(binary.BigEndian.Uint64(readFull(r, b[:])))
+}
@@ -20,18 +20,19 @@ package tdstream
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/binary"
 	"encoding/xml"
 	"io"
 	"log"
-	"math/big"
+	"sync/atomic"
 	"time"
 
 	"github.com/marklaczynski/acidbath/broker/tdapi/tdstream/acctactivityfield"
 	"github.com/marklaczynski/acidbath/broker/tdapi/tdstream/optrequestfield"
 	"github.com/marklaczynski/acidbath/broker/tdapi/tdstream/quoterequestfield"
+	"github.com/marklaczynski/acidbath/dm/asset"
 	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/optionchain/symbology"
 	"github.com/marklaczynski/acidbath/dm/ordermessage"
 	"github.com/marklaczynski/acidbath/lib/financial"
 	"github.com/marklaczynski/acidbath/lib/mjlog"
@@ -44,21 +45,60 @@ var (
 	logError = log.New(mjlog.CreateErrorFile(), "ERROR [tdstream]: ", log.LstdFlags|log.Lshortfile)
 )
 
+// debug is 1 when the decoder logs to logDebug. Formatting a line for every column costs more than decoding it, and
+// the stream runs thousands of columns a second during busy opens, so it is off by default
+var debug int32
+
+//Debug returns true if the decoder writes what it decodes to the debug log
+func Debug() bool {
+	return atomic.LoadInt32(&debug) == 1
+}
+
+//SetDebug turns the decoder's debug log on or off. The tdapi session sets it from StreamDebug in its config at login
+func SetDebug(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&debug, 1)
+	} else {
+		atomic.StoreInt32(&debug, 0)
+	}
+}
+
 //Decoder holds stream reader information
 type Decoder struct {
 	//FUTURE: test this as just an io.Reader... i think it should work
-	reader *bufio.Reader
+	reader  *bufio.Reader
+	payload frame                       // reused for the payload of every message
+	scratch [8]byte                     // reused for the fields read outside of the payload
+	symbols map[string]symbology.Symbol // option symbols already parsed, every tick of an option repeats its symbol
 }
 
 //NewDecoder returns a new Decoder
 func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{bufio.NewReader(r)}
+	return &Decoder{reader: bufio.NewReader(r), symbols: make(map[string]symbology.Symbol)}
 
 }
 
+func (d *Decoder) readInt8() int8 {
+	return int8(readFull(d.reader, d.scratch[:1])[0])
+}
+
+func (d *Decoder) readInt16() int16 {
+	return int16(binary.BigEndian.Uint16(readFull(d.reader, d.scratch[:2])))
+}
+
+func (d *Decoder) readInt32() int32 {
+	return int32(binary.BigEndian.Uint32(readFull(d.reader, d.scratch[:4])))
+}
+
+func (d *Decoder) readInt64() int64 {
+	return int64(binary.BigEndian.Uint64(readFull(d.reader, d.scratch[:8])))
+}
+
 //DecodeHeartbeat decodes a TD Heartbeat message
 func (d *Decoder) DecodeHeartbeat() {
-	logDebug.Printf("Heartbeat\n")
+	if Debug() {
+		logDebug.Printf("Heartbeat\n")
+	}
 	subType, err := d.reader.ReadByte()
 	if err != nil {
 		logError.Printf("Error reading subtype: %s\n", err)
@@ -66,13 +106,11 @@ func (d *Decoder) DecodeHeartbeat() {
 	switch subType {
 	case 'T':
 		//read time
-		var t int64
-		err := binary.Read(d.reader, binary.BigEndian, &t)
-		if err != nil {
-			logError.Printf("binary.Read failed: ", err)
-		}
+		t := d.readInt64()
 		//td sends millisecond, Unix() expects seconds
-		logDebug.Printf("Time: %s\n", time.Unix(t/1000, 0))
+		if Debug() {
+			logDebug.Printf("Time: %s\n", time.Unix(t/1000, 0))
+		}
 		return
 
 	case 'H':
@@ -105,98 +143,11 @@ func (d *Decoder) DecodeHeartbeat() {
 	executive decision is to manually add 1 more byte to payloadLen
 */
 
-// These functions should become public library for myself
-func ReadBool(r io.Reader) bool {
-	if ReadInt8(r) == 0 {
-		return false
-	}
-	return true
-}
-
-func ReadInt8(r io.Reader) int8 {
-	var i int8
-
-	err := binary.Read(r, binary.BigEndian, &i)
-	if err != nil {
-		logError.Printf("binary.Read failed: ", err)
-		panic("Unexpected failure")
-	}
-
-	return i
-}
-
-func ReadInt16(r io.Reader) int16 {
-	var i int16
-	err := binary.Read(r, binary.BigEndian, &i)
-	if err != nil {
-		logError.Printf("binary.Read failed: ", err)
-		panic("Unexpected failure")
-	}
-
-	return i
-}
-
-func ReadInt32(r io.Reader) int32 {
-	var i int32
-	err := binary.Read(r, binary.BigEndian, &i)
-	if err != nil {
-		logError.Printf("binary.Read failed: ", err)
-		panic("Unexpected failure")
-	}
-
-	return i
-}
-
-func ReadInt64(r io.Reader) int64 {
-	var i int64
-	err := binary.Read(r, binary.BigEndian, &i)
-	if err != nil {
-		logError.Printf("binary.Read failed: ", err)
-		panic("Unexpected failure")
-	}
-
-	return i
-}
-
-func ReadString(r io.Reader, length int) string {
-	logDebug.Printf("string len: %d\n", length)
-
-	str := make([]byte, length, length)
-	err := binary.Read(r, binary.BigEndian, &str)
-	if err != nil {
-		logError.Printf("binary.Read failed: ", err)
-		panic("Unexpected failure")
-	}
-
-	return string(str)
-
-}
-
-func ReadFloat32(r io.Reader) float32 {
-	var f float32
-	err := binary.Read(r, binary.BigEndian, &f)
-	if err != nil {
-		logError.Printf("binary.Read failed: ", err)
-		panic("Unexpected failure")
-	}
-
-	return f
-}
-
-func ReadFloat64(r io.Reader) float64 {
-	var f float64
-	err := binary.Read(r, binary.BigEndian, &f)
-	if err != nil {
-		logError.Printf("binary.Read failed: ", err)
-		panic("Unexpected failure")
-	}
-
-	return f
-}
-
 //DecodeHeader reads TD header from the stream and returns its value. In case there's an EOF error, it returns 'X'
 func (d *Decoder) DecodeHeader() byte {
-	logDebug.Printf("DecodeHeader\n")
+	if Debug() {
+		logDebug.Printf("DecodeHeader\n")
+	}
 	header, err := d.reader.ReadByte()
 
 	if err == io.EOF {
@@ -208,7 +159,9 @@ func (d *Decoder) DecodeHeader() byte {
 		return 'Y'
 	}
 
-	logDebug.Printf("parsed header: %c\n", header)
+	if Debug() {
+		logDebug.Printf("parsed header: %c\n", header)
+	}
 
 	return header
 }
@@ -217,88 +170,95 @@ const delimiterSizeBytes = 1
 
 //SidHandlers is a stuct to hold callback functions
 type SidHandlers struct {
+	QuoteCallback           UpdateQuoteAction
 	OptionCallback          UpdateOptionAction
 	AccountActivityCallback AcctActivityAction
 }
 
-//UpdateOptionAction is the function that is called once option data is parsed from the stream
-type UpdateOptionAction func(newOptionData *option.Option)
+//UpdateQuoteAction is the function that is called once equity quote data is parsed from the stream.
+//Only the fields of newQuoteData in fields were sent, the rest should be merged from the last quote
+type UpdateQuoteAction func(newQuoteData *asset.Quote, fields asset.QuoteField)
+
+//UpdateOptionAction is the function that is called once option data is parsed from the stream.
+//TD only sends the fields that changed, fields says which ones newOptionData holds
+type UpdateOptionAction func(newOptionData *option.Option, fields option.OptionField)
 
 //AcctActivityAction is the function that is called once an account activity (order update) comes in
 type AcctActivityAction func(message *ordermessage.Message)
 
 //DecodeCommonStreamingHeader parses the Common Streaming Header from the TD stream
 func (d *Decoder) DecodeCommonStreamingHeader(sh *SidHandlers) {
-	logDebug.Printf("DecodeCommonStreamingHeader\n")
-	payloadLen := ReadInt16(d.reader) + delimiterSizeBytes // this is a difference from snapshot... so common code will need to adjust for this
-	logDebug.Printf("Payload Length: %d\n", payloadLen)
+	if Debug() {
+		logDebug.Printf("DecodeCommonStreamingHeader\n")
+	}
+	payloadLen := int(d.readInt16()) + delimiterSizeBytes // this is a difference from snapshot... so common code will need to adjust for this
+	if Debug() {
+		logDebug.Printf("Payload Length: %d\n", payloadLen)
+	}
 
-	payload := make([]byte, payloadLen, payloadLen)
-	err := binary.Read(d.reader, binary.BigEndian, &payload)
-	if err != nil {
-		logError.Printf("binary.Read failed: ", err)
+	d.decodePayload(payloadLen, sh)
+}
+
+//decodePayload reads the payload into the reused frame, parses it and reads the ending delimiter
+func (d *Decoder) decodePayload(payloadLen int, sh *SidHandlers) {
+	if err := d.payload.fill(d.reader, payloadLen); err != nil {
+		logError.Printf("Error reading %d byte payload: %s\n", payloadLen, err)
+		panic("Unexpected failure")
 	}
 
 	// parse the actual payload
-	payloadReader := bytes.NewReader(payload)
-	parsePayload(payloadReader, sh)
+	parsePayload(&d.payload, sh, d.symbols)
 
 	// parseEnding Delim
-	endingDelim := byte(ReadInt8(d.reader))
+	endingDelim := byte(d.readInt8())
 	if endingDelim != 0x0A {
-		logError.Printf("Error reading endingDelim: %s\n", err)
+		logError.Printf("Error reading endingDelim: %x\n", endingDelim)
 	}
 }
 
 //DecodeSnapshotResponse parses the Snapshot Response from the TD stream
 func (d *Decoder) DecodeSnapshotResponse(sh *SidHandlers) {
-	logDebug.Printf("DecodeSnapshotResponse\n")
-	snapshotLen := ReadInt16(d.reader)
-	logDebug.Printf("SnapshotID Len: %d\n", snapshotLen)
+	if Debug() {
+		logDebug.Printf("DecodeSnapshotResponse\n")
+	}
+	snapshotLen := d.readInt16()
+	if Debug() {
+		logDebug.Printf("SnapshotID Len: %d\n", snapshotLen)
+	}
 
 	//parse SID string (this is just the way Snapshot Response does it)
 	//i don't think i need to make any decision
 	sidString := ReadString(d.reader, int(snapshotLen))
-	logDebug.Printf("SnapshotID: %s\n", sidString)
-
-	// reused code from decode common streaming header
-	payloadLen := ReadInt32(d.reader) + delimiterSizeBytes // this is a difference from snapshot... so common code will need to adjust for this
-	logDebug.Printf("Payload Length: %d\n", payloadLen)
-
-	payload := make([]byte, payloadLen, payloadLen)
-	err := binary.Read(d.reader, binary.BigEndian, &payload)
-	if err != nil {
-		logError.Printf("binary.Read failed: ", err)
+	if Debug() {
+		logDebug.Printf("SnapshotID: %s\n", sidString)
 	}
 
-	// parse the actual payload
-	payloadReader := bytes.NewReader(payload)
-	parsePayload(payloadReader, sh)
-
-	// parseEnding Delim
-	endingDelim := byte(ReadInt8(d.reader))
-	if endingDelim != 0x0A {
-		logError.Printf("Error reading endingDelim: %s\n", err)
+	// reused code from decode common streaming header
+	payloadLen := int(d.readInt32()) + delimiterSizeBytes // this is a difference from snapshot... so common code will need to adjust for this
+	if Debug() {
+		logDebug.Printf("Payload Length: %d\n", payloadLen)
 	}
-	// End resued code
 
+	d.decodePayload(payloadLen, sh)
 }
 
-func parsePayload(r io.Reader, sh *SidHandlers) {
+func parsePayload(r *frame, sh *SidHandlers, symbols map[string]symbology.Symbol) {
 	//payload starts with SID
-	sid := ReadInt16(r)
+	sid := r.readInt16()
 
-	logDebug.Printf("SID: %d: %s\n", sid, StreamingID(sid))
+	if Debug() {
+		logDebug.Printf("SID: %d: %s\n", sid, StreamingID(sid))
+	}
 
 	// once i have sid, i switch on which way to parse rest of data
 	switch StreamingID(sid) {
 	case Quote:
-		parseQuote(r)
+		parseQuote(r, sh.QuoteCallback)
 	case TimeSale:
 	case Response:
 		parseResponse(r) // see STREAMER SERVER in documentation
 	case Option:
-		parseOption(r, sh.OptionCallback)
+		parseOption(r, sh.OptionCallback, symbols)
 	case ActivesNYSE:
 	case ActivesNASDAQ:
 	case ActivesOTCBB:
@@ -322,42 +282,66 @@ func parsePayload(r io.Reader, sh *SidHandlers) {
 	}
 }
 
-func parseAcctActivity(r io.Reader, callback AcctActivityAction) {
-	logDebug.Printf("parseAcctActivity\n")
+func parseAcctActivity(r *frame, callback AcctActivityAction) {
+	if Debug() {
+		logDebug.Printf("parseAcctActivity\n")
+	}
 	//while column # != 0xFF continue
-	buf := ReadInt8(r)
+	buf := r.readInt8()
 	var key, acctNum, messageType, data string
 
 	for byte(buf) != delimiter {
 		columnNum := acctactivityfield.AcctActivityNumber(buf)
-		logDebug.Printf("Column num: %d: %s", columnNum, acctactivityfield.AcctActivityNumber(columnNum))
+		if Debug() {
+			logDebug.Printf("Column num: %d: %s", columnNum, acctactivityfield.AcctActivityNumber(columnNum))
+		}
 
 		switch columnNum {
 		case acctactivityfield.SubscriptionKey:
-			key = ReadString(r, int(ReadInt16(r)))
-			logDebug.Printf("key: %s\n", key)
+			key = r.readString(int(r.readInt16()))
+			if Debug() {
+				logDebug.Printf("key: %s\n", key)
+			}
 		case acctactivityfield.AccountNumber:
-			acctNum = ReadString(r, int(ReadInt16(r)))
-			logDebug.Printf("account number: %s\n", acctNum)
+			acctNum = r.readString(int(r.readInt16()))
+			if Debug() {
+				logDebug.Printf("account number: %s\n", acctNum)
+			}
 		case acctactivityfield.MessageType:
-			messageType = ReadString(r, int(ReadInt16(r)))
-			logDebug.Printf("message type: %s\n", messageType)
+			messageType = r.readString(int(r.readInt16()))
+			if Debug() {
+				logDebug.Printf("message type: %s\n", messageType)
+			}
 		case acctactivityfield.MessageData:
-			data = ReadString(r, int(ReadInt16(r)))
-			logDebug.Printf("message data: %s\n", data)
+			data = r.readString(int(r.readInt16()))
+			if Debug() {
+				logDebug.Printf("message data: %s\n", data)
+			}
 
-			if len(data) > 0 {
-				logDebug.Printf("about to parse data\n")
+			if messageType == string(acctactivityfield.Subscribed) {
+				//nil data, but let listeners know the account activity stream is live
+				if Debug() {
+					logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+				}
+				callback(ordermessage.New("", orderconst.OrderSubscribed))
+			} else if len(data) > 0 {
+				if Debug() {
+					logDebug.Printf("about to parse data\n")
+				}
 				switch messageType {
-				case string(acctactivityfield.Subscribed):
-					//nil
-
 				case string(acctactivityfield.Error):
 					//txt
-					logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					if Debug() {
+						logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					}
+					orderMsg := ordermessage.New("", orderconst.OrderError)
+					orderMsg.SetRejectReason(data)
+					callback(orderMsg)
 
 				case string(acctactivityfield.UrOut):
-					logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					if Debug() {
+						logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					}
 					//xml
 					var msg acctactivityfield.UROUTMessage
 					err := xml.Unmarshal([]byte(data), &msg)
@@ -365,11 +349,13 @@ func parseAcctActivity(r io.Reader, callback AcctActivityAction) {
 						logError.Printf("Error unmarshaling response: %s\n", err)
 					}
 
-					orderMsg := ordermessage.New(msg.Order.OrderKey, orderconst.OrderOut)
+					orderMsg := msg.Order.NewMessage(msg.OrderGroupID, orderconst.OrderOut)
 					callback(orderMsg)
 
 				case string(acctactivityfield.OrderCancelReplaceRequest):
-					logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					if Debug() {
+						logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					}
 					//xml
 					var msg acctactivityfield.OrderCancelReplaceRequestMessage
 					err := xml.Unmarshal([]byte(data), &msg)
@@ -377,11 +363,13 @@ func parseAcctActivity(r io.Reader, callback AcctActivityAction) {
 						logError.Printf("Error unmarshaling response: %s\n", err)
 					}
 
-					orderMsg := ordermessage.New(msg.Order.OrderKey, orderconst.OrderCancelReplace)
+					orderMsg := msg.Order.NewMessage(msg.OrderGroupID, orderconst.OrderCancelReplace)
 					callback(orderMsg)
 
 				case string(acctactivityfield.BrokenTrade):
-					logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					if Debug() {
+						logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					}
 					//xml
 					var msg acctactivityfield.BrokenTradeMessage
 					err := xml.Unmarshal([]byte(data), &msg)
@@ -389,11 +377,13 @@ func parseAcctActivity(r io.Reader, callback AcctActivityAction) {
 						logError.Printf("Error unmarshaling response: %s\n", err)
 					}
 
-					orderMsg := ordermessage.New(msg.Order.OrderKey, orderconst.OrderBroken)
+					orderMsg := msg.Order.NewMessage(msg.OrderGroupID, orderconst.OrderBroken)
 					callback(orderMsg)
 
 				case string(acctactivityfield.ManualExecution):
-					logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					if Debug() {
+						logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					}
 					//xml
 					var msg acctactivityfield.ManualExecutionMessage
 					err := xml.Unmarshal([]byte(data), &msg)
@@ -401,11 +391,13 @@ func parseAcctActivity(r io.Reader, callback AcctActivityAction) {
 						logError.Printf("Error unmarshaling response: %s\n", err)
 					}
 
-					orderMsg := ordermessage.New(msg.Order.OrderKey, orderconst.OrderManualExecution)
+					orderMsg := msg.Order.NewMessage(msg.OrderGroupID, orderconst.OrderManualExecution)
 					callback(orderMsg)
 
 				case string(acctactivityfield.OrderActivation):
-					logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					if Debug() {
+						logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					}
 					//xml
 					var msg acctactivityfield.OrderActivationMessage
 					err := xml.Unmarshal([]byte(data), &msg)
@@ -413,11 +405,13 @@ func parseAcctActivity(r io.Reader, callback AcctActivityAction) {
 						logError.Printf("Error unmarshaling response: %s\n", err)
 					}
 
-					orderMsg := ordermessage.New(msg.Order.OrderKey, orderconst.OrderActivation)
+					orderMsg := msg.Order.NewMessage(msg.OrderGroupID, orderconst.OrderActivation)
 					callback(orderMsg)
 
 				case string(acctactivityfield.OrderCancelRequest):
-					logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					if Debug() {
+						logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					}
 					//xml
 					var msg acctactivityfield.OrderCancelRequestMessage
 					err := xml.Unmarshal([]byte(data), &msg)
@@ -425,11 +419,13 @@ func parseAcctActivity(r io.Reader, callback AcctActivityAction) {
 						logError.Printf("Error unmarshaling response: %s\n", err)
 					}
 
-					orderMsg := ordermessage.New(msg.Order.OrderKey, orderconst.OrderCancel)
+					orderMsg := msg.Order.NewMessage(msg.OrderGroupID, orderconst.OrderCancel)
 					callback(orderMsg)
 
 				case string(acctactivityfield.OrderEntryRequest):
-					logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					if Debug() {
+						logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					}
 					//xml
 
 					var msg acctactivityfield.OrderEntryRequestMessage
@@ -438,11 +434,13 @@ func parseAcctActivity(r io.Reader, callback AcctActivityAction) {
 						logError.Printf("Error unmarshaling response: %s\n", err)
 					}
 
-					orderMsg := ordermessage.New(msg.Order.OrderKey, orderconst.OrderEntry)
+					orderMsg := msg.Order.NewMessage(msg.OrderGroupID, orderconst.OrderEntry)
 					callback(orderMsg)
 
 				case string(acctactivityfield.OrderFill):
-					logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					if Debug() {
+						logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					}
 					//xml
 					var msg acctactivityfield.OrderFillMessage
 					err := xml.Unmarshal([]byte(data), &msg)
@@ -450,11 +448,13 @@ func parseAcctActivity(r io.Reader, callback AcctActivityAction) {
 						logError.Printf("Error unmarshaling response: %s\n", err)
 					}
 
-					orderMsg := ordermessage.New(msg.Order.OrderKey, orderconst.OrderFill)
+					orderMsg := msg.Order.NewMessage(msg.OrderGroupID, orderconst.OrderFill)
 					callback(orderMsg)
 
 				case string(acctactivityfield.OrderPartialFill):
-					logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					if Debug() {
+						logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					}
 					//xml
 					var msg acctactivityfield.OrderPartialFillMessage
 					err := xml.Unmarshal([]byte(data), &msg)
@@ -462,11 +462,13 @@ func parseAcctActivity(r io.Reader, callback AcctActivityAction) {
 						logError.Printf("Error unmarshaling response: %s\n", err)
 					}
 
-					orderMsg := ordermessage.New(msg.Order.OrderKey, orderconst.OrderPartialFill)
+					orderMsg := msg.Order.NewMessage(msg.OrderGroupID, orderconst.OrderPartialFill)
 					callback(orderMsg)
 
 				case string(acctactivityfield.OrderRejection):
-					logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					if Debug() {
+						logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					}
 					//xml
 					var msg acctactivityfield.OrderRejectionMessage
 					err := xml.Unmarshal([]byte(data), &msg)
@@ -474,11 +476,13 @@ func parseAcctActivity(r io.Reader, callback AcctActivityAction) {
 						logError.Printf("Error unmarshaling response: %s\n", err)
 					}
 
-					orderMsg := ordermessage.New(msg.Order.OrderKey, orderconst.OrderRejection)
+					orderMsg := msg.Order.NewMessage(msg.OrderGroupID, orderconst.OrderRejection)
 					callback(orderMsg)
 
 				case string(acctactivityfield.TooLateToCancel):
-					logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					if Debug() {
+						logDebug.Printf("MESSAGTYPE: %s\n", messageType)
+					}
 					//xml
 					var msg acctactivityfield.TooLateToCancelMessage
 					err := xml.Unmarshal([]byte(data), &msg)
@@ -486,285 +490,427 @@ func parseAcctActivity(r io.Reader, callback AcctActivityAction) {
 						logError.Printf("Error unmarshaling response: %s\n", err)
 					}
 
-					orderMsg := ordermessage.New(msg.Order.OrderKey, orderconst.OrderTooLateToCancel)
+					orderMsg := msg.Order.NewMessage(msg.OrderGroupID, orderconst.OrderTooLateToCancel)
 					callback(orderMsg)
 				}
 			}
 		}
 
-		buf = ReadInt8(r)
+		buf = r.readInt8()
 	}
 }
 
 const delimiter = 0xFF
 
-func parseQuote(r io.Reader) {
-	logDebug.Printf("parseQuote\n")
+func parseQuote(r *frame, callback UpdateQuoteAction) {
+	if Debug() {
+		logDebug.Printf("parseQuote\n")
+	}
+
+	// TD only sends the columns that changed, fields records which ones were in the frame
+	newQuoteData := asset.NewNilQuote()
+	var fields asset.QuoteField
 
 	//while column # != 0xFF continue
-	buf := ReadInt8(r)
+	buf := r.readInt8()
 	for byte(buf) != delimiter {
 		columnNum := quoterequestfield.QuoteColumnNumber(buf)
-		logDebug.Printf("Column num: %d: %s", columnNum, quoterequestfield.QuoteColumnNumber(columnNum))
+		if Debug() {
+			logDebug.Printf("Column num: %d: %s", columnNum, quoterequestfield.QuoteColumnNumber(columnNum))
+		}
 		switch columnNum {
 		case quoterequestfield.Symbol:
-			logDebug.Printf("Symbol: %s", ReadString(r, int(ReadInt16(r))))
+			symbol := r.readString(int(r.readInt16()))
+			if Debug() {
+				logDebug.Printf("Symbol: %s", symbol)
+			}
+			newQuoteData.SetSymbol(symbol)
+			fields |= asset.SymbolField
 		case quoterequestfield.Bid:
-			bid := ReadFloat32(r)
-			logDebug.Printf("Bid: %.2f", bid)
+			bid := r.readFloat32()
+			if Debug() {
+				logDebug.Printf("Bid: %.2f", bid)
+			}
+			newQuoteData.SetPrice(asset.BidField, financial.PriceFromFloat32(bid))
+			fields |= asset.BidField
 		case quoterequestfield.Ask:
-			ask := ReadFloat32(r)
-			logDebug.Printf("Ask: %.2f", ask)
+			ask := r.readFloat32()
+			if Debug() {
+				logDebug.Printf("Ask: %.2f", ask)
+			}
+			newQuoteData.SetPrice(asset.AskField, financial.PriceFromFloat32(ask))
+			fields |= asset.AskField
 		case quoterequestfield.Last:
-			ReadFloat32(r)
+			newQuoteData.SetPrice(asset.LastField, financial.PriceFromFloat32(r.readFloat32()))
+			fields |= asset.LastField
 		case quoterequestfield.BidSize:
-			ReadInt32(r)
+			newQuoteData.SetBidSize(r.readInt32())
+			fields |= asset.BidSizeField
 		case quoterequestfield.AskSize:
-			ReadInt32(r)
+			newQuoteData.SetAskSize(r.readInt32())
+			fields |= asset.AskSizeField
 		case quoterequestfield.BidID:
 			// char in td terminology
-			ReadInt16(r)
+			r.readInt16()
 		case quoterequestfield.AskID:
 			// char in td terminology
-			ReadInt16(r)
+			r.readInt16()
 		case quoterequestfield.Volume:
 			// Long in td terminlogy
-			ReadInt64(r)
+			newQuoteData.SetVolume(r.readInt64())
+			fields |= asset.VolumeField
 		case quoterequestfield.LastSize:
-			ReadInt32(r)
+			newQuoteData.SetLastSize(r.readInt32())
+			fields |= asset.LastSizeField
 		case quoterequestfield.TradeTime:
-			ReadInt32(r)
+			r.readInt32()
 		case quoterequestfield.QuoteTime:
-			ReadInt32(r)
+			r.readInt32()
 		case quoterequestfield.High:
-			ReadFloat32(r)
+			newQuoteData.SetPrice(asset.HighField, financial.PriceFromFloat32(r.readFloat32()))
+			fields |= asset.HighField
 		case quoterequestfield.Low:
-			ReadFloat32(r)
+			newQuoteData.SetPrice(asset.LowField, financial.PriceFromFloat32(r.readFloat32()))
+			fields |= asset.LowField
 		case quoterequestfield.Tick:
 			// char in td terminology
-			ReadInt16(r)
+			r.readInt16()
 		case quoterequestfield.Close:
-			ReadFloat32(r)
+			newQuoteData.SetPrice(asset.CloseField, financial.PriceFromFloat32(r.readFloat32()))
+			fields |= asset.CloseField
 		case quoterequestfield.EXChange:
-			ReadInt16(r)
+			r.readInt16()
 		case quoterequestfield.Marginable:
-			ReadBool(r)
+			r.readBool()
 		case quoterequestfield.Shortable:
-			ReadBool(r)
+			r.readBool()
 		case quoterequestfield.QuoteDate:
 			// # days since 1/1/1970
-			ReadInt32(r)
+			r.readInt32()
 		case quoterequestfield.TradeDate:
-			ReadInt32(r)
+			r.readInt32()
 		case quoterequestfield.Volatility:
-			ReadFloat32(r)
+			r.readFloat32()
 		case quoterequestfield.Description:
-			ReadString(r, int(ReadInt16(r)))
+			newQuoteData.SetDescription(r.readString(int(r.readInt16())))
+			fields |= asset.DescriptionField
 		case quoterequestfield.TradeID:
-			ReadInt16(r)
+			r.readInt16()
 		case quoterequestfield.Digits:
-			ReadInt32(r)
+			r.readInt32()
 		case quoterequestfield.Open:
-			ReadFloat32(r)
+			newQuoteData.SetPrice(asset.OpenField, financial.PriceFromFloat32(r.readFloat32()))
+			fields |= asset.OpenField
 		case quoterequestfield.Change:
-			ReadFloat32(r)
+			newQuoteData.SetPrice(asset.ChangeField, financial.PriceFromFloat32(r.readFloat32()))
+			fields |= asset.ChangeField
 		case quoterequestfield.WeekHigh52:
-			ReadFloat32(r)
+			newQuoteData.SetPrice(asset.YearHighField, financial.PriceFromFloat32(r.readFloat32()))
+			fields |= asset.YearHighField
 		case quoterequestfield.WeekLow52:
-			ReadFloat32(r)
+			newQuoteData.SetPrice(asset.YearLowField, financial.PriceFromFloat32(r.readFloat32()))
+			fields |= asset.YearLowField
 		case quoterequestfield.PERatio:
-			ReadFloat32(r)
+			r.readFloat32()
 		case quoterequestfield.DividendAmt:
-			ReadFloat32(r)
+			r.readFloat32()
 		case quoterequestfield.DividendYield:
-			ReadFloat32(r)
+			r.readFloat32()
 		case quoterequestfield.Nav:
-			ReadFloat32(r)
+			r.readFloat32()
 		case quoterequestfield.Fund:
-			ReadFloat32(r)
+			r.readFloat32()
 		case quoterequestfield.ExchangeName:
-			ReadString(r, int(ReadInt16(r)))
+			newQuoteData.SetExchange(r.readString(int(r.readInt16())))
+			fields |= asset.ExchangeField
 		case quoterequestfield.DividendDate:
-			ReadString(r, int(ReadInt16(r)))
+			r.readString(int(r.readInt16()))
 		case quoterequestfield.LastMarketHours:
-			ReadFloat32(r)
+			r.readFloat32()
 		case quoterequestfield.LastSizeMarketHours:
-			ReadInt32(r)
+			r.readInt32()
 		case quoterequestfield.TradeDateMarketHours:
-			ReadInt32(r)
+			r.readInt32()
 		case quoterequestfield.TradeTimeMarketHours:
-			ReadInt32(r)
+			r.readInt32()
 		case quoterequestfield.ChangeMarketHours:
-			ReadFloat32(r)
+			r.readFloat32()
 		case quoterequestfield.IsRegularMarketQuote:
-			ReadBool(r)
+			r.readBool()
 		case quoterequestfield.IsRegularMarketTrade:
-			ReadBool(r)
+			r.readBool()
 
 		}
 
-		buf = ReadInt8(r)
-		logDebug.Printf("Buf byte %x\n", buf)
+		buf = r.readInt8()
+		if Debug() {
+			logDebug.Printf("Buf byte %x\n", buf)
+		}
+	}
+	if Debug() {
+		logDebug.Printf("Exit for column loop\n")
 	}
-	logDebug.Printf("Exit for column loop\n")
 
+	// streamed quotes are real time
+	newQuoteData.SetRealTime(true)
+	fields |= asset.RealTimeField
+	if callback != nil {
+		callback(newQuoteData, fields)
+	}
 }
 
-func parseOption(r io.Reader, callback UpdateOptionAction) {
-	logDebug.Printf("parseOption\n")
+//parseOption parses an option tick. Its symbol is parsed the first time it streams and looked up in symbols after
+func parseOption(r *frame, callback UpdateOptionAction, symbols map[string]symbology.Symbol) {
+	if Debug() {
+		logDebug.Printf("parseOption\n")
+	}
 
+	// TD only sends the columns that changed, fields records which ones were in the frame
 	var newOptionData *option.Option = option.NewNilOption()
+	var fields option.OptionField
 
-	buf := ReadInt8(r)
+	buf := r.readInt8()
 	for byte(buf) != delimiter {
 		columnNum := optrequestfield.OptionColumnNumber(buf)
-		logDebug.Printf("Column num: %d: %s", columnNum, optrequestfield.OptionColumnNumber(columnNum))
+		if Debug() {
+			logDebug.Printf("Column num: %d: %s", columnNum, optrequestfield.OptionColumnNumber(columnNum))
+		}
 		switch columnNum {
 		case optrequestfield.Symbol:
-			optSymbol := ReadString(r, int(ReadInt16(r)))
-			logDebug.Printf("Symbol: %s\n", optSymbol)
+			optSymbol := r.readString(int(r.readInt16()))
+			if Debug() {
+				logDebug.Printf("Symbol: %s\n", optSymbol)
+			}
 			newOptionData.SetOptionTickerSymbol(optSymbol)
+			fields |= option.SymbolField
+			parsed, ok := symbols[optSymbol]
+			if !ok {
+				var err error
+				if parsed, _, err = symbology.Parse(optSymbol); err != nil {
+					logError.Printf("Unable to parse option symbol %s: %s\n", optSymbol, err)
+					break
+				}
+				symbols[optSymbol] = parsed
+			}
+			if err := parsed.Fill(newOptionData); err != nil {
+				logError.Printf("Unable to fill option %s: %s\n", optSymbol, err)
+			} else {
+				fields |= option.ContractField
+			}
 		case optrequestfield.Contract:
-			ReadString(r, int(ReadInt16(r)))
+			r.readString(int(r.readInt16()))
 		case optrequestfield.Bid:
-			bid := financial.Money{(&big.Rat{}).SetFloat64(float64(ReadFloat32(r)))}
-			logDebug.Printf("Bid: %.2f", bid)
-			logDebug.Printf("option: %#v ", newOptionData)
+			bid := financial.PriceFromFloat32(r.readFloat32())
+			if Debug() {
+				logDebug.Printf("Bid: %s", bid)
+				logDebug.Printf("option: %#v ", newOptionData)
+			}
 			// this is needed, because it seems like even though we have UNSUBS from all options,
 			// there are old lingering options still streaming.
 			if newOptionData != nil {
-				newOptionData.SetBid(bid)
+				newOptionData.SetBidPrice(bid)
+				fields |= option.BidField
 			}
 		case optrequestfield.Ask:
-			ask := financial.Money{(&big.Rat{}).SetFloat64(float64(ReadFloat32(r)))}
-			logDebug.Printf("Ask: %.2f", ask)
-			logDebug.Printf("option: %#v ", newOptionData)
+			ask := financial.PriceFromFloat32(r.readFloat32())
+			if Debug() {
+				logDebug.Printf("Ask: %s", ask)
+				logDebug.Printf("option: %#v ", newOptionData)
+			}
 			if newOptionData != nil {
-				newOptionData.SetAsk(ask)
+				newOptionData.SetAskPrice(ask)
+				fields |= option.AskField
 			}
 		case optrequestfield.Last:
-			last := financial.Money{(&big.Rat{}).SetFloat64(float64(ReadFloat32(r)))}
-			logDebug.Printf("Last: %.2f", last)
-			logDebug.Printf("option: %#v ", newOptionData)
+			last := financial.PriceFromFloat32(r.readFloat32())
+			if Debug() {
+				logDebug.Printf("Last: %s", last)
+				logDebug.Printf("option: %#v ", newOptionData)
+			}
 			if newOptionData != nil {
-				newOptionData.SetLast(last)
+				newOptionData.SetLastPrice(last)
+				fields |= option.LastField
 			}
 
 		case optrequestfield.High:
-			ReadFloat32(r)
+			r.readFloat32()
 		case optrequestfield.Low:
-			ReadFloat32(r)
+			r.readFloat32()
 		case optrequestfield.Close:
-			ReadFloat32(r)
+			r.readFloat32()
 		case optrequestfield.Volume:
-			ReadInt64(r)
+			volume := r.readInt64()
+			if newOptionData != nil && volume >= 0 {
+				newOptionData.SetVolume(volume)
+				fields |= option.VolumeField
+			}
 		case optrequestfield.OpenInterest:
-			ReadInt32(r)
+			openInterest := r.readInt32()
+			if newOptionData != nil && openInterest >= 0 {
+				newOptionData.SetOpenInterest(openInterest)
+				fields |= option.OpenInterestField
+			}
 		case optrequestfield.Volatility:
-			ReadFloat32(r)
+			// TD sends volatility as a percent
+			volatility := float64(r.readFloat32()) / 100
+			if newOptionData != nil && volatility >= 0 {
+				newOptionData.SetImpliedVolatility(volatility)
+				fields |= option.ImpliedVolatilityField
+			}
 		case optrequestfield.QuoteTime:
-			ReadInt32(r)
+			r.readInt32()
 		case optrequestfield.TradeTime:
-			ReadInt32(r)
+			r.readInt32()
 		case optrequestfield.InTheMoney:
-			ReadFloat32(r)
+			r.readFloat32()
 		case optrequestfield.QuoteDate:
-			ReadInt32(r)
+			r.readInt32()
 		case optrequestfield.TradeDate:
-			ReadInt32(r)
+			r.readInt32()
 		case optrequestfield.Year:
-			ReadInt32(r)
+			r.readInt32()
 		case optrequestfield.Multiplier:
-			ReadFloat32(r)
+			multiplier := float64(r.readFloat32())
+			if newOptionData != nil && multiplier > 0 {
+				newOptionData.SetMultiplier(multiplier)
+				fields |= option.MultiplierField
+			}
 		case optrequestfield.Open:
-			ReadFloat32(r)
+			r.readFloat32()
 		case optrequestfield.BidSize:
-			ReadInt32(r)
+			bidSize := r.readInt32()
+			if newOptionData != nil && bidSize >= 0 {
+				newOptionData.SetBidSize(bidSize)
+				fields |= option.BidSizeField
+			}
 		case optrequestfield.AskSize:
-			ReadInt32(r)
+			askSize := r.readInt32()
+			if newOptionData != nil && askSize >= 0 {
+				newOptionData.SetAskSize(askSize)
+				fields |= option.AskSizeField
+			}
 		case optrequestfield.LastSize:
-			ReadInt32(r)
+			r.readInt32()
 		case optrequestfield.Change:
-			ReadFloat32(r)
+			r.readFloat32()
 		case optrequestfield.Strike:
-			ReadFloat32(r)
+			r.readFloat32()
 		case optrequestfield.ContractType:
-			ReadInt16(r) //char
+			r.readInt16() //char
 		case optrequestfield.Underlying:
-			ReadString(r, int(ReadInt16(r)))
+			r.readString(int(r.readInt16()))
 		case optrequestfield.Month:
-			ReadInt32(r)
+			r.readInt32()
 		case optrequestfield.Note:
-			ReadString(r, int(ReadInt16(r)))
+			r.readString(int(r.readInt16()))
 		case optrequestfield.TimeValue:
-			ReadFloat32(r)
+			r.readFloat32()
 		case optrequestfield.DaysToExp:
-			ReadInt32(r)
+			r.readInt32()
 		case optrequestfield.DeltaIndex:
-			delta := float64(ReadFloat32(r))
-			logDebug.Printf("delta: %.2f", delta)
-			logDebug.Printf("option: %#v ", newOptionData)
+			delta := float64(r.readFloat32())
+			if Debug() {
+				logDebug.Printf("delta: %.2f", delta)
+				logDebug.Printf("option: %#v ", newOptionData)
+			}
 			if newOptionData != nil {
 				newOptionData.SetDelta(delta)
+				fields |= option.DeltaField
 			}
 		case optrequestfield.GammaIndex:
-			gamma := float64(ReadFloat32(r))
-			logDebug.Printf("gamma: %.2f", gamma)
-			logDebug.Printf("option: %#v ", newOptionData)
+			gamma := float64(r.readFloat32())
+			if Debug() {
+				logDebug.Printf("gamma: %.2f", gamma)
+				logDebug.Printf("option: %#v ", newOptionData)
+			}
 			if newOptionData != nil {
 				newOptionData.SetGamma(gamma)
+				fields |= option.GammaField
 			}
 		case optrequestfield.ThetaIndex:
-			theta := float64(ReadFloat32(r))
-			logDebug.Printf("theta: %.2f", theta)
-			logDebug.Printf("option: %#v ", newOptionData)
+			theta := float64(r.readFloat32())
+			if Debug() {
+				logDebug.Printf("theta: %.2f", theta)
+				logDebug.Printf("option: %#v ", newOptionData)
+			}
 			if newOptionData != nil {
 				newOptionData.SetTheta(theta)
+				fields |= option.ThetaField
 			}
 		case optrequestfield.VegaIndex:
-			vega := float64(ReadFloat32(r))
-			logDebug.Printf("vega: %.2f", vega)
-			logDebug.Printf("option: %#v ", newOptionData)
+			vega := float64(r.readFloat32())
+			if Debug() {
+				logDebug.Printf("vega: %.2f", vega)
+				logDebug.Printf("option: %#v ", newOptionData)
+			}
 			if newOptionData != nil {
 				newOptionData.SetVega(vega)
+				fields |= option.VegaField
 			}
 		case optrequestfield.RhoIndex:
-			ReadFloat32(r)
+			rho := float64(r.readFloat32())
+			if Debug() {
+				logDebug.Printf("rho: %.2f", rho)
+			}
+			if newOptionData != nil {
+				newOptionData.SetRho(rho)
+				fields |= option.RhoField
+			}
+		}
+		buf = r.readInt8()
+		if Debug() {
+			logDebug.Printf("Buf byte %x\n", buf)
 		}
-		buf = ReadInt8(r)
-		logDebug.Printf("Buf byte %x\n", buf)
 	}
 
-	callback(newOptionData)
-	logDebug.Printf("Exit for column loop\n")
+	callback(newOptionData, fields)
+	if Debug() {
+		logDebug.Printf("Exit for column loop\n")
+	}
 }
 
-func parseResponse(r io.Reader) {
-	logDebug.Printf("parseResponse\n")
+func parseResponse(r *frame) {
+	if Debug() {
+		logDebug.Printf("parseResponse\n")
+	}
 
-	columnNum := ReadInt8(r)
-	logDebug.Printf("Column num: %d", columnNum)
+	columnNum := r.readInt8()
+	if Debug() {
+		logDebug.Printf("Column num: %d", columnNum)
+	}
 
-	sid := ReadInt16(r)
-	logDebug.Printf("Service ID: %d\n", sid)
+	sid := r.readInt16()
+	if Debug() {
+		logDebug.Printf("Service ID: %d\n", sid)
+	}
 
-	columnNum = ReadInt8(r)
-	logDebug.Printf("Column num: %d", columnNum)
+	columnNum = r.readInt8()
+	if Debug() {
+		logDebug.Printf("Column num: %d", columnNum)
+	}
 
-	returnCode := ReadInt16(r)
-	logDebug.Printf("Return Code: %d", returnCode)
+	returnCode := r.readInt16()
+	if Debug() {
+		logDebug.Printf("Return Code: %d", returnCode)
+	}
 
-	columnNum = ReadInt8(r)
-	logDebug.Printf("Column num: %d", columnNum)
+	columnNum = r.readInt8()
+	if Debug() {
+		logDebug.Printf("Column num: %d", columnNum)
+	}
 
-	descriptionLen := ReadInt16(r)
-	logDebug.Printf("Description Len: %d", descriptionLen)
+	descriptionLen := r.readInt16()
+	if Debug() {
+		logDebug.Printf("Description Len: %d", descriptionLen)
+	}
 
-	description := ReadString(r, int(descriptionLen))
-	logDebug.Printf("Description: %s", description)
+	description := r.readString(int(descriptionLen))
+	if Debug() {
+		logDebug.Printf("Description: %s", description)
+	}
 
 	// parseLastField
-	lastField := byte(ReadInt8(r))
+	lastField := byte(r.readInt8())
 	if lastField != delimiter {
 		logError.Printf("Error reading lastfield\n")
 	}
@@ -0,0 +1,245 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package tdstream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/marklaczynski/acidbath/broker/tdapi/tdstream/optrequestfield"
+	"github.com/marklaczynski/acidbath/broker/tdapi/tdstream/quoterequestfield"
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/lib/financial"
+)
+
+// payload builds the payload of a streamed message
+type payload struct {
+	bytes.Buffer
+}
+
+func (p *payload) column(col int, value interface{}) {
+	p.WriteByte(byte(col))
+	if s, ok := value.(string); ok {
+		binary.Write(p, binary.BigEndian, int16(len(s)))
+		p.WriteString(s)
+		return
+	}
+	binary.Write(p, binary.BigEndian, value)
+}
+
+// message returns the payload of sid as a common streaming message
+func (p *payload) message(sid StreamingID) []byte {
+	var b bytes.Buffer
+	b.WriteByte('S')
+	binary.Write(&b, binary.BigEndian, int16(p.Len()+2)) // the length doesn't include the column delimiter
+	binary.Write(&b, binary.BigEndian, int16(sid))
+	b.Write(p.Bytes())
+	b.WriteByte(delimiter)
+	b.WriteByte(0x0A)
+	return b.Bytes()
+}
+
+func optionMessage() []byte {
+	var p payload
+	p.column(int(optrequestfield.Symbol), "SPY_061518P100")
+	p.column(optrequestfield.Bid, float32(1.20))
+	p.column(optrequestfield.Ask, float32(1.35))
+	p.column(optrequestfield.Last, float32(1.30))
+	p.column(optrequestfield.Volume, int64(1520))
+	p.column(optrequestfield.OpenInterest, int32(10400))
+	p.column(optrequestfield.Volatility, float32(18.5))
+	p.column(optrequestfield.BidSize, int32(30))
+	p.column(optrequestfield.AskSize, int32(45))
+	p.column(optrequestfield.DeltaIndex, float32(-0.16))
+	p.column(optrequestfield.GammaIndex, float32(0.02))
+	p.column(optrequestfield.ThetaIndex, float32(-0.03))
+	p.column(optrequestfield.VegaIndex, float32(0.11))
+	p.column(optrequestfield.RhoIndex, float32(-0.01))
+	return p.message(Option)
+}
+
+func quoteMessage() []byte {
+	var p payload
+	p.column(int(quoterequestfield.Symbol), "SPY")
+	p.column(quoterequestfield.Bid, float32(210.15))
+	p.column(quoterequestfield.Ask, float32(210.17))
+	p.column(quoterequestfield.Last, float32(210.16))
+	p.column(quoterequestfield.BidSize, int32(12))
+	p.column(quoterequestfield.AskSize, int32(8))
+	p.column(quoterequestfield.Volume, int64(81250300))
+	p.column(quoterequestfield.WeekHigh52, float32(213.78))
+	p.column(quoterequestfield.WeekLow52, float32(181.02))
+	p.column(quoterequestfield.ExchangeName, "PACIFIC")
+	return p.message(Quote)
+}
+
+func TestDecodeOption(t *testing.T) {
+	var got *option.Option
+	var fields option.OptionField
+	sh := &SidHandlers{OptionCallback: func(o *option.Option, f option.OptionField) { got, fields = o, f }}
+
+	d := NewDecoder(bytes.NewReader(optionMessage()))
+	if h := d.DecodeHeader(); h != 'S' {
+		t.Fatalf("header got %c", h)
+	}
+	d.DecodeCommonStreamingHeader(sh)
+
+	if got == nil {
+		t.Fatalf("option callback was not called")
+	}
+	if got.Underlying() != "SPY" || got.OptionType() != option.PUT || got.Strike() != 100 {
+		t.Errorf("symbol parsed to %s %s %.2f", got.Underlying(), got.OptionType(), got.Strike())
+	}
+	if got.BidPrice() != 12000 || got.AskPrice() != 13500 || got.LastPrice() != 13000 {
+		t.Errorf("prices got %s %s %s", got.BidPrice(), got.AskPrice(), got.LastPrice())
+	}
+	if got.Volume() != 1520 || got.OpenInterest() != 10400 || got.BidSize() != 30 || got.AskSize() != 45 {
+		t.Errorf("sizes got %d %d %d %d", got.Volume(), got.OpenInterest(), got.BidSize(), got.AskSize())
+	}
+	if float32(got.Delta()) != -0.16 || float32(got.ImpliedVolatility()) != 0.185 {
+		t.Errorf("delta %.4f iv %.4f", got.Delta(), got.ImpliedVolatility())
+	}
+	// every column but the multiplier was in the frame
+	if want := option.AllOptionFields &^ option.MultiplierField; fields != want {
+		t.Errorf("fields got %b, want %b", fields, want)
+	}
+	if h := d.DecodeHeader(); h != 'X' {
+		t.Errorf("the whole message should be read, got header %c", h)
+	}
+}
+
+func TestDecodeOptionParsesSymbolOnce(t *testing.T) {
+	var strikes []float64
+	sh := &SidHandlers{OptionCallback: func(o *option.Option, f option.OptionField) {
+		if f&option.ContractField != 0 {
+			strikes = append(strikes, o.Strike())
+		}
+	}}
+
+	d := NewDecoder(bytes.NewReader(append(optionMessage(), optionMessage()...)))
+	for i := 0; i < 2; i++ {
+		if h := d.DecodeHeader(); h != 'S' {
+			t.Fatalf("header %d got %c", i, h)
+		}
+		d.DecodeCommonStreamingHeader(sh)
+	}
+
+	if len(strikes) != 2 || strikes[0] != 100 || strikes[1] != 100 {
+		t.Errorf("both ticks should be filled from the symbol, got strikes %v", strikes)
+	}
+	if len(d.symbols) != 1 {
+		t.Errorf("the symbol should be parsed and cached once, got %d cached", len(d.symbols))
+	}
+}
+
+func TestDecodeQuote(t *testing.T) {
+	var got *asset.Quote
+	var fields asset.QuoteField
+	sh := &SidHandlers{QuoteCallback: func(q *asset.Quote, f asset.QuoteField) { got, fields = q, f }}
+
+	// the second message reuses the decoder's payload buffer
+	d := NewDecoder(bytes.NewReader(append(optionMessage(), quoteMessage()...)))
+	for i := 0; i < 2; i++ {
+		d.DecodeHeader()
+		d.DecodeCommonStreamingHeader(&SidHandlers{QuoteCallback: sh.QuoteCallback, OptionCallback: func(*option.Option, option.OptionField) {}})
+	}
+
+	if got == nil {
+		t.Fatalf("quote callback was not called")
+	}
+	if got.Symbol() != "SPY" || got.Exchange() != "PACIFIC" || got.Volume() != 81250300 || got.BidSize() != 12 {
+		t.Errorf("got %s %s %d %d", got.Symbol(), got.Exchange(), got.Volume(), got.BidSize())
+	}
+	if financial.PriceFromMoney(got.YearHigh()) != 2137800 || financial.PriceFromMoney(got.BidPrice()) != 2101500 {
+		t.Errorf("prices got %s %s", got.YearHigh(), got.BidPrice())
+	}
+
+	// only the columns in the frame are marked for merging
+	sent := asset.SymbolField | asset.BidField | asset.AskField | asset.LastField | asset.BidSizeField | asset.AskSizeField |
+		asset.VolumeField | asset.YearHighField | asset.YearLowField | asset.ExchangeField | asset.RealTimeField
+	if fields != sent {
+		t.Errorf("fields got %b, want %b", fields, sent)
+	}
+}
+
+// repeater endlessly repeats a stream of messages
+type repeater struct {
+	data []byte
+	off  int
+}
+
+func (r *repeater) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], r.data[r.off:])
+		n += c
+		r.off = (r.off + c) % len(r.data)
+	}
+	return n, nil
+}
+
+func benchmarkDecode(b *testing.B, message []byte, sh *SidHandlers) {
+	d := NewDecoder(&repeater{data: message})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if d.DecodeHeader() != 'S' {
+			b.Fatal("lost the message boundary")
+		}
+		d.DecodeCommonStreamingHeader(sh)
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "frames/s")
+}
+
+func BenchmarkDecodeOption(b *testing.B) {
+	benchmarkDecode(b, optionMessage(), &SidHandlers{OptionCallback: func(*option.Option, option.OptionField) {}})
+}
+
+func BenchmarkDecodeQuote(b *testing.B) {
+	benchmarkDecode(b, quoteMessage(), &SidHandlers{QuoteCallback: func(*asset.Quote, asset.QuoteField) {}})
+}
+
+// BenchmarkMarkOption decodes streamed options and marks them, as the session does for every option tick
+func BenchmarkMarkOption(b *testing.B) {
+	var mark float64
+	benchmarkDecode(b, optionMessage(), &SidHandlers{OptionCallback: func(o *option.Option, _ option.OptionField) {
+		mark = portfolio.MarkPrice(portfolio.MarkMid, o.BidPrice(), o.AskPrice(), o.LastPrice())
+	}})
+	if mark == 0 {
+		b.Fatal("option was not marked")
+	}
+}
+
+// BenchmarkMarkQuote decodes streamed quotes, merges them into the last quote and marks them, as the session
+// does for every equity tick
+func BenchmarkMarkQuote(b *testing.B) {
+	var mark float64
+	cached := asset.NewNilQuote()
+	benchmarkDecode(b, quoteMessage(), &SidHandlers{QuoteCallback: func(q *asset.Quote, fields asset.QuoteField) {
+		cached.Merge(q, fields)
+		mark = portfolio.MarkPrice(portfolio.MarkMid, cached.Price(asset.BidField), cached.Price(asset.AskField), cached.Price(asset.LastField))
+	}})
+	if mark == 0 {
+		b.Fatal("quote was not marked")
+	}
+}
@@ -0,0 +1,421 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package analytics computes the market implied odds of an option or multi-leg position: expected move,
+//probability of expiring in the money, probability of touch and probability of profit, along with the
+//breakevens and maximum profit and loss at expiration.
+//Probabilities are risk neutral, the underlying is lognormal with the option's implied volatility
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/pricing"
+	"github.com/marklaczynski/acidbath/lib/mjlog"
+)
+
+var (
+	logInfo  = log.New(mjlog.CreateInfoFile(), "INFO  [analytics]: ", log.LstdFlags|log.Lshortfile)
+	logDebug = log.New(mjlog.CreateDebugFile(), "DEBUG [analytics]: ", log.LstdFlags|log.Lshortfile)
+	logError = log.New(mjlog.CreateErrorFile(), "ERROR [analytics]: ", log.LstdFlags|log.Lshortfile)
+)
+
+//errors returned by the analytics
+var (
+	ErrNoUnderlyingPrice  = errors.New("underlying has no price")
+	ErrNoVolatility       = errors.New("option has no implied volatility")
+	ErrExpired            = errors.New("option has expired")
+	ErrNoLegs             = errors.New("position has no legs")
+	ErrMixedExpirations   = errors.New("legs expire on different dates, there is no single expiration payoff")
+	ErrDifferentUnderlyer = errors.New("legs are on different underlyings")
+)
+
+//Config holds the market inputs that aren't on the option or stock
+type Config struct {
+	Rate          float64   // risk free rate
+	DividendYield float64   // continuous dividend yield
+	Now           time.Time // time.Now() if zero
+}
+
+func (cfg Config) now() time.Time {
+	if cfg.Now.IsZero() {
+		return time.Now()
+	}
+	return cfg.Now
+}
+
+//Spot returns the stock's last trade price, or the mid of its quote when it hasn't traded
+func Spot(stock *asset.Stock) (float64, error) {
+	spot, _ := stock.LastTradePrice().Value.Float64()
+	if spot <= 0 {
+		bid, _ := stock.BidPrice().Value.Float64()
+		ask, _ := stock.AskPrice().Value.Float64()
+		spot = (bid + ask) / 2
+		if bid <= 0 || ask <= 0 {
+			spot = math.Max(bid, ask)
+		}
+	}
+	if spot <= 0 {
+		return 0, ErrNoUnderlyingPrice
+	}
+	return spot, nil
+}
+
+//lognormal is the risk neutral distribution of the underlying at an expiration
+type lognormal struct {
+	spot  float64
+	drift float64 // (r - q - vol^2/2) * t
+	sd    float64 // vol * sqrt(t)
+	vol   float64
+	time  float64
+}
+
+func newLognormal(o *option.Option, stock *asset.Stock, cfg Config) (lognormal, error) {
+	spot, err := Spot(stock)
+	if err != nil {
+		return lognormal{}, err
+	}
+
+	vol := o.ImpliedVolatility()
+	if vol <= 0 {
+		return lognormal{}, ErrNoVolatility
+	}
+
+	t := pricing.TimeToExpiry(o.ExpirationDate(), cfg.now())
+	if t <= 0 {
+		return lognormal{}, ErrExpired
+	}
+
+	return lognormal{
+		spot:  spot,
+		drift: (cfg.Rate - cfg.DividendYield - vol*vol/2) * t,
+		sd:    vol * math.Sqrt(t),
+		vol:   vol,
+		time:  t,
+	}, nil
+}
+
+//above returns the probability the underlying finishes above price
+func (ln lognormal) above(price float64) float64 {
+	if price <= 0 {
+		return 1
+	}
+	return pricing.NormCDF((math.Log(ln.spot/price) + ln.drift) / ln.sd)
+}
+
+//touch returns the probability the underlying trades at price before expiration
+func (ln lognormal) touch(price float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+
+	// reflection principle for a brownian motion with drift mu per year
+	mu := ln.drift / ln.time
+	b := math.Log(price / ln.spot)
+	if b == 0 {
+		return 1
+	}
+
+	t := ln.time
+	sd := ln.sd
+	if b > 0 {
+		return pricing.NormCDF((-b+mu*t)/sd) + math.Exp(2*mu*b/(ln.vol*ln.vol))*pricing.NormCDF((-b-mu*t)/sd)
+	}
+	return pricing.NormCDF((b-mu*t)/sd) + math.Exp(2*mu*b/(ln.vol*ln.vol))*pricing.NormCDF((b+mu*t)/sd)
+}
+
+//ExpectedMove returns the one standard deviation move of the underlying by o's expiration implied by o's
+//volatility, in dollars
+func ExpectedMove(o *option.Option, stock *asset.Stock, cfg Config) (float64, error) {
+	ln, err := newLognormal(o, stock, cfg)
+	if err != nil {
+		return 0, err
+	}
+	return ln.spot * ln.sd, nil
+}
+
+//ProbabilityITM returns the probability o expires in the money
+func ProbabilityITM(o *option.Option, stock *asset.Stock, cfg Config) (float64, error) {
+	ln, err := newLognormal(o, stock, cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	if o.OptionType() == option.CALL {
+		return ln.above(o.Strike()), nil
+	}
+	return 1 - ln.above(o.Strike()), nil
+}
+
+//ProbabilityOTM returns the probability o expires out of the money
+func ProbabilityOTM(o *option.Option, stock *asset.Stock, cfg Config) (float64, error) {
+	itm, err := ProbabilityITM(o, stock, cfg)
+	if err != nil {
+		return 0, err
+	}
+	return 1 - itm, nil
+}
+
+//ProbabilityOfTouch returns the probability the underlying trades at o's strike before expiration. It is 1 for
+//an option that is already in the money
+func ProbabilityOfTouch(o *option.Option, stock *asset.Stock, cfg Config) (float64, error) {
+	ln, err := newLognormal(o, stock, cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	if (o.OptionType() == option.CALL && ln.spot >= o.Strike()) || (o.OptionType() == option.PUT && ln.spot <= o.Strike()) {
+		return 1, nil
+	}
+	return math.Min(ln.touch(o.Strike()), 1), nil
+}
+
+//Leg is one leg of a position. Option is nil for a stock leg
+type Leg struct {
+	Option   *option.Option
+	Quantity float64 // contracts or shares, negative when short
+	Price    float64 // entry price per share
+}
+
+func (l Leg) multiplier() float64 {
+	if l.Option == nil {
+		return 1
+	}
+	if l.Option.Multiplier() == 0 {
+		return 100
+	}
+	return l.Option.Multiplier()
+}
+
+//payoff returns the leg's profit at expiration with the underlying at price
+func (l Leg) payoff(price float64) float64 {
+	value := price
+	if l.Option != nil {
+		if l.Option.OptionType() == option.CALL {
+			value = math.Max(price-l.Option.Strike(), 0)
+		} else {
+			value = math.Max(l.Option.Strike()-price, 0)
+		}
+	}
+	return l.Quantity * l.multiplier() * (value - l.Price)
+}
+
+//slope returns the change of the leg's payoff per dollar of underlying above all of its strikes
+func (l Leg) slope() float64 {
+	if l.Option != nil && l.Option.OptionType() == option.PUT {
+		return 0
+	}
+	return l.Quantity * l.multiplier()
+}
+
+//Payoff returns the profit of legs at expiration with the underlying at price
+func Payoff(legs []Leg, price float64) float64 {
+	var p float64
+	for _, l := range legs {
+		p += l.payoff(price)
+	}
+	return p
+}
+
+//Extremes returns the maximum profit and loss of legs at expiration, treating every option leg as expiring
+//together. MaxProfit is +Inf and MaxLoss -Inf when unlimited
+func Extremes(legs []Leg) (maxProfit float64, maxLoss float64) {
+	kinks := []float64{0}
+	var slope float64
+	for _, l := range legs {
+		if l.Option != nil {
+			kinks = append(kinks, l.Option.Strike())
+		}
+		slope += l.slope()
+	}
+
+	maxProfit, maxLoss = math.Inf(-1), math.Inf(1)
+	for _, k := range kinks {
+		p := Payoff(legs, k)
+		maxProfit = math.Max(maxProfit, p)
+		maxLoss = math.Min(maxLoss, p)
+	}
+
+	switch {
+	case slope > 0:
+		maxProfit = math.Inf(1)
+	case slope < 0:
+		maxLoss = math.Inf(-1)
+	}
+	return maxProfit, maxLoss
+}
+
+//Analysis is the expiration profile and odds of a position
+type Analysis struct {
+	Expiration          time.Time
+	Spot                float64
+	ExpectedMove        float64   // one standard deviation move in dollars, from the implied volatility nearest the money
+	Breakevens          []float64 // underlying prices where the position's P&L at expiration crosses 0, ascending
+	MaxProfit           float64   // +Inf when unlimited
+	MaxLoss             float64   // as a negative P&L, -Inf when unlimited
+	ProbabilityOfProfit float64   // probability the position makes money at expiration
+}
+
+func (a *Analysis) String() string {
+	return fmt.Sprintf("expiration: %s spot: %.2f expected move: %.2f breakevens: %v max profit: %.2f max loss: %.2f pop: %.2f%%",
+		a.Expiration.Format("2006-01-02"), a.Spot, a.ExpectedMove, a.Breakevens, a.MaxProfit, a.MaxLoss, a.ProbabilityOfProfit*100)
+}
+
+//Analyze returns the expiration profile and odds of legs. Every option leg must expire on the same date.
+//The probability of finishing beyond each breakeven uses the implied volatility of the leg struck nearest to it,
+//so a position's odds reflect the skew between its strikes
+func Analyze(legs []Leg, stock *asset.Stock, cfg Config) (*Analysis, error) {
+	var options []*option.Option
+	for _, l := range legs {
+		if l.Option == nil {
+			continue
+		}
+		if len(options) > 0 {
+			if !l.Option.ExpirationDate().Equal(options[0].ExpirationDate()) {
+				return nil, ErrMixedExpirations
+			}
+			if l.Option.Underlying() != options[0].Underlying() {
+				return nil, ErrDifferentUnderlyer
+			}
+		}
+		options = append(options, l.Option)
+	}
+	if len(options) == 0 {
+		return nil, ErrNoLegs
+	}
+
+	spot, err := Spot(stock)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Analysis{Expiration: options[0].ExpirationDate(), Spot: spot}
+
+	nearest := func(price float64) *option.Option {
+		best := options[0]
+		for _, o := range options[1:] {
+			if math.Abs(o.Strike()-price) < math.Abs(best.Strike()-price) {
+				best = o
+			}
+		}
+		return best
+	}
+
+	atm, err := newLognormal(nearest(spot), stock, cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.ExpectedMove = spot * atm.sd
+
+	a.MaxProfit, a.MaxLoss = Extremes(legs)
+
+	// the payoff is linear between strikes, so its zero crossings are found between the kinks
+	kinks := []float64{0}
+	for _, o := range options {
+		kinks = append(kinks, o.Strike())
+	}
+	sort.Float64s(kinks)
+
+	var slope float64
+	for _, l := range legs {
+		slope += l.slope()
+	}
+
+	for i := 1; i < len(kinks); i++ {
+		k := kinks[i]
+		p := Payoff(legs, k)
+		prev := Payoff(legs, kinks[i-1])
+		if p == 0 {
+			a.Breakevens = appendBreakeven(a.Breakevens, k)
+		} else if (prev < 0 && p > 0) || (prev > 0 && p < 0) {
+			a.Breakevens = appendBreakeven(a.Breakevens, kinks[i-1]+(k-kinks[i-1])*(-prev)/(p-prev))
+		}
+	}
+
+	last := kinks[len(kinks)-1]
+	lastPayoff := Payoff(legs, last)
+	if (slope > 0 && lastPayoff < 0) || (slope < 0 && lastPayoff > 0) {
+		a.Breakevens = appendBreakeven(a.Breakevens, last-lastPayoff/slope)
+	}
+
+	a.ProbabilityOfProfit, err = probabilityOfProfit(legs, a.Breakevens, last, nearest, stock, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	logDebug.Printf("%s\n", a)
+	return a, nil
+}
+
+func appendBreakeven(breakevens []float64, price float64) []float64 {
+	if n := len(breakevens); n > 0 && math.Abs(breakevens[n-1]-price) < 1e-9 {
+		return breakevens
+	}
+	return append(breakevens, price)
+}
+
+//probabilityOfProfit adds up the probability of each region between breakevens where the position makes money
+func probabilityOfProfit(legs []Leg, breakevens []float64, last float64, nearest func(float64) *option.Option, stock *asset.Stock, cfg Config) (float64, error) {
+	bounds := append([]float64{0}, breakevens...)
+	bounds = append(bounds, math.Inf(1))
+
+	above := func(price float64) (float64, error) {
+		if price <= 0 {
+			return 1, nil
+		}
+		if math.IsInf(price, 1) {
+			return 0, nil
+		}
+		ln, err := newLognormal(nearest(price), stock, cfg)
+		if err != nil {
+			return 0, err
+		}
+		return ln.above(price), nil
+	}
+
+	var pop float64
+	for i := 1; i < len(bounds); i++ {
+		lo, hi := bounds[i-1], bounds[i]
+		mid := (lo + hi) / 2
+		if math.IsInf(hi, 1) {
+			mid = math.Max(lo, last) + 1
+		}
+		if Payoff(legs, mid) <= 0 {
+			continue
+		}
+
+		plo, err := above(lo)
+		if err != nil {
+			return 0, err
+		}
+		phi, err := above(hi)
+		if err != nil {
+			return 0, err
+		}
+		pop += plo - phi
+	}
+
+	return pop, nil
+}
@@ -0,0 +1,152 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package analytics
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/pricing"
+	"github.com/marklaczynski/acidbath/lib/financial"
+)
+
+var (
+	now = time.Date(2016, 6, 1, 16, 0, 0, 0, time.UTC)
+	exp = time.Date(2016, 7, 1, 0, 0, 0, 0, time.UTC)
+	cfg = Config{Now: now}
+)
+
+func testStock() *asset.Stock {
+	s := asset.NewStock("XYZ")
+	s.SetLastTradePrice(financial.Money{Value: big.NewRat(100, 1)})
+	return s
+}
+
+func testOption(t *testing.T, strike float64, typ option.TypeOfOption, iv float64) *option.Option {
+	o, err := option.NewOption("XYZ", strike, exp, typ, 100)
+	if err != nil {
+		t.Fatalf("Error creating option: %v", err)
+	}
+	o.SetImpliedVolatility(iv)
+	return o
+}
+
+func TestSingleOption(t *testing.T) {
+	stock := testStock()
+	call := testOption(t, 110, option.CALL, 0.30)
+
+	move, err := ExpectedMove(call, stock, cfg)
+	want := 100 * 0.30 * math.Sqrt(pricing.TimeToExpiry(call.ExpirationDate(), now))
+	if err != nil || math.Abs(move-want) > 1e-9 {
+		t.Errorf("expected move got %.4f %v, want %.4f", move, err, want)
+	}
+
+	itm, _ := ProbabilityITM(call, stock, cfg)
+	otm, _ := ProbabilityOTM(call, stock, cfg)
+	if itm <= 0 || itm >= 0.5 || math.Abs(itm+otm-1) > 1e-12 {
+		t.Errorf("otm call itm %.4f otm %.4f", itm, otm)
+	}
+
+	// with no drift the chance of touching is about twice the chance of finishing beyond the strike
+	touch, _ := ProbabilityOfTouch(call, stock, cfg)
+	if touch <= itm || math.Abs(touch-2*itm) > 0.02 {
+		t.Errorf("touch got %.4f, want about %.4f", touch, 2*itm)
+	}
+
+	put := testOption(t, 105, option.PUT, 0.30)
+	if touch, _ := ProbabilityOfTouch(put, stock, cfg); touch != 1 {
+		t.Errorf("an itm put has already touched, got %.4f", touch)
+	}
+
+	if _, err := ExpectedMove(testOption(t, 100, option.CALL, 0), stock, cfg); err != ErrNoVolatility {
+		t.Errorf("expected ErrNoVolatility, got %v", err)
+	}
+}
+
+func TestShortPut(t *testing.T) {
+	stock := testStock()
+	put := testOption(t, 95, option.PUT, 0.25)
+
+	a, err := Analyze([]Leg{{Option: put, Quantity: -1, Price: 2}}, stock, cfg)
+	if err != nil {
+		t.Fatalf("Error analyzing: %v", err)
+	}
+
+	if len(a.Breakevens) != 1 || math.Abs(a.Breakevens[0]-93) > 1e-9 {
+		t.Errorf("breakevens got %v, want [93]", a.Breakevens)
+	}
+	if a.MaxProfit != 200 || a.MaxLoss != -9300 {
+		t.Errorf("max profit/loss got %.2f/%.2f, want 200/-9300", a.MaxProfit, a.MaxLoss)
+	}
+
+	ln, _ := newLognormal(put, stock, cfg)
+	if math.Abs(a.ProbabilityOfProfit-ln.above(93)) > 1e-12 {
+		t.Errorf("pop got %.4f, want %.4f", a.ProbabilityOfProfit, ln.above(93))
+	}
+}
+
+func TestIronCondor(t *testing.T) {
+	stock := testStock()
+	legs := []Leg{
+		{Option: testOption(t, 85, option.PUT, 0.32), Quantity: 1, Price: 0.50},
+		{Option: testOption(t, 90, option.PUT, 0.28), Quantity: -1, Price: 1.20},
+		{Option: testOption(t, 110, option.CALL, 0.22), Quantity: -1, Price: 1.00},
+		{Option: testOption(t, 115, option.CALL, 0.20), Quantity: 1, Price: 0.40},
+	}
+
+	a, err := Analyze(legs, stock, cfg)
+	if err != nil {
+		t.Fatalf("Error analyzing: %v", err)
+	}
+
+	// 1.30 credit on 5 wide wings
+	if math.Abs(a.MaxProfit-130) > 1e-9 || math.Abs(a.MaxLoss+370) > 1e-9 {
+		t.Errorf("max profit/loss got %.2f/%.2f, want 130/-370", a.MaxProfit, a.MaxLoss)
+	}
+	if len(a.Breakevens) != 2 || math.Abs(a.Breakevens[0]-88.7) > 1e-9 || math.Abs(a.Breakevens[1]-111.3) > 1e-9 {
+		t.Errorf("breakevens got %v, want [88.7 111.3]", a.Breakevens)
+	}
+	if a.ProbabilityOfProfit <= 0.5 || a.ProbabilityOfProfit >= 1 {
+		t.Errorf("pop got %.4f", a.ProbabilityOfProfit)
+	}
+}
+
+func TestCoveredCall(t *testing.T) {
+	legs := []Leg{
+		{Quantity: 100, Price: 100},
+		{Option: testOption(t, 105, option.CALL, 0.25), Quantity: -1, Price: 1.5},
+	}
+
+	a, err := Analyze(legs, testStock(), cfg)
+	if err != nil {
+		t.Fatalf("Error analyzing: %v", err)
+	}
+	if math.Abs(a.MaxProfit-650) > 1e-9 || math.Abs(a.MaxLoss+9850) > 1e-9 {
+		t.Errorf("max profit/loss got %.2f/%.2f, want 650/-9850", a.MaxProfit, a.MaxLoss)
+	}
+
+	long := []Leg{{Option: testOption(t, 105, option.CALL, 0.25), Quantity: 1, Price: 1.5}}
+	if a, _ := Analyze(long, testStock(), cfg); !math.IsInf(a.MaxProfit, 1) || a.Breakevens[0] != 106.5 {
+		t.Errorf("long call got %s", a)
+	}
+}
@@ -20,25 +20,190 @@ package asset
 
 import (
 	"errors"
-	"math/big"
 
 	"github.com/marklaczynski/acidbath/lib/financial"
 )
 
+//Quote is the level one quote of an equity. Prices are held as fixed-point, so streamed quotes can be
+//decoded and merged without allocating
 type Quote struct {
 	symbol         string
 	description    string
-	bidPrice       financial.Money
-	askPrice       financial.Money
-	lastTradePrice financial.Money
-	closePrice     financial.Money
+	exchange       string
+	bidPrice       financial.Price
+	askPrice       financial.Price
+	lastTradePrice financial.Price
+	openPrice      financial.Price
+	highPrice      financial.Price
+	lowPrice       financial.Price
+	closePrice     financial.Price
+	change         financial.Price // last trade price less the previous close, can be negative
+	yearHigh       financial.Price
+	yearLow        financial.Price
+	volume         int64
+	bidSize        int32
+	askSize        int32
+	lastSize       int32
+	realTime       bool
 }
 
+//QuoteField is a set of Quote fields, used to merge a partial update such as a streamed quote into a quote
+type QuoteField uint32
+
+//enumeration values for QuoteField, which can be or'ed together
+const (
+	SymbolField QuoteField = 1 << iota
+	DescriptionField
+	ExchangeField
+	BidField
+	AskField
+	LastField
+	OpenField
+	HighField
+	LowField
+	CloseField
+	ChangeField
+	YearHighField
+	YearLowField
+	VolumeField
+	BidSizeField
+	AskSizeField
+	LastSizeField
+	RealTimeField
+
+	AllQuoteFields = RealTimeField<<1 - 1
+)
+
+//NewQuote resets every price of the quote to 0
 func (q *Quote) NewQuote() {
-	q.bidPrice.Value = big.NewRat(0, 1)
-	q.askPrice.Value = big.NewRat(0, 1)
-	q.lastTradePrice.Value = big.NewRat(0, 1)
-	q.closePrice.Value = big.NewRat(0, 1)
+	q.bidPrice = 0
+	q.askPrice = 0
+	q.lastTradePrice = 0
+	q.openPrice = 0
+	q.highPrice = 0
+	q.lowPrice = 0
+	q.closePrice = 0
+	q.change = 0
+	q.yearHigh = 0
+	q.yearLow = 0
+}
+
+//NewNilQuote returns a quote with every price initialized to 0
+func NewNilQuote() *Quote {
+	return &Quote{}
+}
+
+//Copy returns a copy of the quote
+func (q *Quote) Copy() *Quote {
+	c := *q
+	return &c
+}
+
+//priceField returns the price held for field, nil if field isn't a single price field
+func (q *Quote) priceField(field QuoteField) *financial.Price {
+	switch field {
+	case BidField:
+		return &q.bidPrice
+	case AskField:
+		return &q.askPrice
+	case LastField:
+		return &q.lastTradePrice
+	case OpenField:
+		return &q.openPrice
+	case HighField:
+		return &q.highPrice
+	case LowField:
+		return &q.lowPrice
+	case CloseField:
+		return &q.closePrice
+	case ChangeField:
+		return &q.change
+	case YearHighField:
+		return &q.yearHigh
+	case YearLowField:
+		return &q.yearLow
+	}
+	return nil
+}
+
+//Price returns the price for field as fixed-point, 0 if field isn't a price field
+func (q *Quote) Price(field QuoteField) financial.Price {
+	if p := q.priceField(field); p != nil {
+		return *p
+	}
+	return 0
+}
+
+//SetPrice sets the price for field from a fixed-point price. Only the change can be negative
+func (q *Quote) SetPrice(field QuoteField, newPrice financial.Price) error {
+	p := q.priceField(field)
+	if p == nil {
+		return errors.New("not a price field of the quote")
+	}
+	if newPrice < 0 && field != ChangeField {
+		return errors.New("price cannot be less than 0")
+	}
+
+	*p = newPrice
+	return nil
+}
+
+//Merge copies the fields of update that are in fields onto q, leaving the rest of q as it is
+func (q *Quote) Merge(update *Quote, fields QuoteField) {
+	if fields&SymbolField != 0 {
+		q.symbol = update.symbol
+	}
+	if fields&DescriptionField != 0 {
+		q.description = update.description
+	}
+	if fields&ExchangeField != 0 {
+		q.exchange = update.exchange
+	}
+	if fields&BidField != 0 {
+		q.bidPrice = update.bidPrice
+	}
+	if fields&AskField != 0 {
+		q.askPrice = update.askPrice
+	}
+	if fields&LastField != 0 {
+		q.lastTradePrice = update.lastTradePrice
+	}
+	if fields&OpenField != 0 {
+		q.openPrice = update.openPrice
+	}
+	if fields&HighField != 0 {
+		q.highPrice = update.highPrice
+	}
+	if fields&LowField != 0 {
+		q.lowPrice = update.lowPrice
+	}
+	if fields&CloseField != 0 {
+		q.closePrice = update.closePrice
+	}
+	if fields&ChangeField != 0 {
+		q.change = update.change
+	}
+	if fields&YearHighField != 0 {
+		q.yearHigh = update.yearHigh
+	}
+	if fields&YearLowField != 0 {
+		q.yearLow = update.yearLow
+	}
+	if fields&VolumeField != 0 {
+		q.volume = update.volume
+	}
+	if fields&BidSizeField != 0 {
+		q.bidSize = update.bidSize
+	}
+	if fields&AskSizeField != 0 {
+		q.askSize = update.askSize
+	}
+	if fields&LastSizeField != 0 {
+		q.lastSize = update.lastSize
+	}
+	if fields&RealTimeField != 0 {
+		q.realTime = update.realTime
+	}
 }
 
 func (q *Quote) Symbol() string {
@@ -59,45 +224,223 @@ func (q *Quote) SetDescription(newValue string) {
 
 //BidPrice returns the bid price
 func (q *Quote) BidPrice() financial.Money {
-	return q.bidPrice
+	return q.bidPrice.Money()
 }
 
 //SetBidPrice sets the bid price
 func (q *Quote) SetBidPrice(newBidPrice financial.Money) error {
-	if newBidPrice.Value.Cmp(big.NewRat(0, 1)) < 0 {
+	if newBidPrice.Sign() < 0 {
 		return errors.New("BidPrice cannot be less than 0")
 	}
 
-	q.bidPrice.Value.Set(newBidPrice.Value)
+	q.bidPrice = financial.PriceFromMoney(newBidPrice)
 	return nil
 }
 
 //AskPrice returns the ask price
 func (q *Quote) AskPrice() financial.Money {
-	return q.askPrice
+	return q.askPrice.Money()
 }
 
 //SetAskPrice sets the ask price
 func (q *Quote) SetAskPrice(newAskPrice financial.Money) error {
-	if newAskPrice.Value.Cmp(big.NewRat(0, 1)) < 0 {
+	if newAskPrice.Sign() < 0 {
 		return errors.New("AskPrice cannot be less than 0")
 	}
 
-	q.askPrice.Value.Set(newAskPrice.Value)
+	q.askPrice = financial.PriceFromMoney(newAskPrice)
 	return nil
 }
 
 //Last returns the last trade price
 func (q *Quote) LastTradePrice() financial.Money {
-	return q.lastTradePrice
+	return q.lastTradePrice.Money()
 }
 
 //SetLast sets the last trade price
 func (q *Quote) SetLastTradePrice(newLastTradePrice financial.Money) error {
-	if newLastTradePrice.Value.Cmp(big.NewRat(0, 1)) < 0 {
+	if newLastTradePrice.Sign() < 0 {
 		return errors.New("Last trade price cannot be less than 0")
 	}
 
-	q.lastTradePrice.Value.Set(newLastTradePrice.Value)
+	q.lastTradePrice = financial.PriceFromMoney(newLastTradePrice)
+	return nil
+}
+
+//Exchange returns the exchange the symbol is listed on
+func (q *Quote) Exchange() string {
+	return q.exchange
+}
+
+//SetExchange sets the exchange the symbol is listed on
+func (q *Quote) SetExchange(newValue string) {
+	q.exchange = newValue
+}
+
+//OpenPrice returns the day's opening price
+func (q *Quote) OpenPrice() financial.Money {
+	return q.openPrice.Money()
+}
+
+//SetOpenPrice sets the day's opening price
+func (q *Quote) SetOpenPrice(newOpenPrice financial.Money) error {
+	if newOpenPrice.Sign() < 0 {
+		return errors.New("Open price cannot be less than 0")
+	}
+
+	q.openPrice = financial.PriceFromMoney(newOpenPrice)
+	return nil
+}
+
+//HighPrice returns the day's high
+func (q *Quote) HighPrice() financial.Money {
+	return q.highPrice.Money()
+}
+
+//SetHighPrice sets the day's high
+func (q *Quote) SetHighPrice(newHighPrice financial.Money) error {
+	if newHighPrice.Sign() < 0 {
+		return errors.New("High price cannot be less than 0")
+	}
+
+	q.highPrice = financial.PriceFromMoney(newHighPrice)
 	return nil
 }
+
+//LowPrice returns the day's low
+func (q *Quote) LowPrice() financial.Money {
+	return q.lowPrice.Money()
+}
+
+//SetLowPrice sets the day's low
+func (q *Quote) SetLowPrice(newLowPrice financial.Money) error {
+	if newLowPrice.Sign() < 0 {
+		return errors.New("Low price cannot be less than 0")
+	}
+
+	q.lowPrice = financial.PriceFromMoney(newLowPrice)
+	return nil
+}
+
+//ClosePrice returns the previous session's closing price
+func (q *Quote) ClosePrice() financial.Money {
+	return q.closePrice.Money()
+}
+
+//SetClosePrice sets the previous session's closing price
+func (q *Quote) SetClosePrice(newClosePrice financial.Money) error {
+	if newClosePrice.Sign() < 0 {
+		return errors.New("Close price cannot be less than 0")
+	}
+
+	q.closePrice = financial.PriceFromMoney(newClosePrice)
+	return nil
+}
+
+//Change returns the last trade price less the previous close
+func (q *Quote) Change() financial.Money {
+	return q.change.Money()
+}
+
+//SetChange sets the last trade price less the previous close
+func (q *Quote) SetChange(newChange financial.Money) {
+	q.change = financial.PriceFromMoney(newChange)
+}
+
+//ChangePercent returns the change as a fraction of the previous close, ie 0.01 for up 1%, and 0 without a close
+func (q *Quote) ChangePercent() float64 {
+	if q.closePrice <= 0 {
+		return 0
+	}
+	return q.change.Float64() / q.closePrice.Float64()
+}
+
+//YearHigh returns the 52 week high
+func (q *Quote) YearHigh() financial.Money {
+	return q.yearHigh.Money()
+}
+
+//SetYearHigh sets the 52 week high
+func (q *Quote) SetYearHigh(newYearHigh financial.Money) error {
+	if newYearHigh.Sign() < 0 {
+		return errors.New("52 week high cannot be less than 0")
+	}
+
+	q.yearHigh = financial.PriceFromMoney(newYearHigh)
+	return nil
+}
+
+//YearLow returns the 52 week low
+func (q *Quote) YearLow() financial.Money {
+	return q.yearLow.Money()
+}
+
+//SetYearLow sets the 52 week low
+func (q *Quote) SetYearLow(newYearLow financial.Money) error {
+	if newYearLow.Sign() < 0 {
+		return errors.New("52 week low cannot be less than 0")
+	}
+
+	q.yearLow = financial.PriceFromMoney(newYearLow)
+	return nil
+}
+
+//YearRangePosition returns where the last trade sits in the 52 week range, 0 at the low and 1 at the high.
+//It returns false when the range isn't known
+func (q *Quote) YearRangePosition() (float64, bool) {
+	high, low, last := q.yearHigh, q.yearLow, q.lastTradePrice
+	if high <= low || low <= 0 || last <= 0 {
+		return 0, false
+	}
+	return float64(last-low) / float64(high-low), true
+}
+
+//Volume returns the number of shares traded on the day
+func (q *Quote) Volume() int64 {
+	return q.volume
+}
+
+//SetVolume sets the number of shares traded on the day
+func (q *Quote) SetVolume(newValue int64) {
+	q.volume = newValue
+}
+
+//BidSize returns the size at the bid
+func (q *Quote) BidSize() int32 {
+	return q.bidSize
+}
+
+//SetBidSize sets the size at the bid
+func (q *Quote) SetBidSize(newValue int32) {
+	q.bidSize = newValue
+}
+
+//AskSize returns the size at the ask
+func (q *Quote) AskSize() int32 {
+	return q.askSize
+}
+
+//SetAskSize sets the size at the ask
+func (q *Quote) SetAskSize(newValue int32) {
+	q.askSize = newValue
+}
+
+//LastSize returns the size of the last trade
+func (q *Quote) LastSize() int32 {
+	return q.lastSize
+}
+
+//SetLastSize sets the size of the last trade
+func (q *Quote) SetLastSize(newValue int32) {
+	q.lastSize = newValue
+}
+
+//RealTime returns true if the quote is real time, false if it is delayed
+func (q *Quote) RealTime() bool {
+	return q.realTime
+}
+
+//SetRealTime sets whether the quote is real time
+func (q *Quote) SetRealTime(newValue bool) {
+	q.realTime = newValue
+}
@@ -96,6 +96,7 @@ type Stock struct {
 	Quote
 	historicalImpliedVol ImpliedVolatilityTypeSlice
 	historicalPrice      []PriceHistoryType
+	dividends            []Dividend // sorted by ex-dividend date
 	optionChain          *optionchain.OptionChain
 	//TODO: make use of the optionChain here... this is a major refactoring, and should be part of the effort where i create a caching system
 }
@@ -137,6 +138,22 @@ func (s *Stock) Beta(targetDailyCloseChange *statistics.Float64) float64 {
 	return statistics.Covariance(&sourceDailyCloseChange, targetDailyCloseChange) / statistics.Variance(targetDailyCloseChange)
 }
 
+//BetaTo returns the beta of the daily log returns of s to benchmark over the last lookback returns. Only the
+//days both have a close for are used, so an index benchmark with a different holiday calendar still lines up
+func (s *Stock) BetaTo(benchmark *Stock, lookback int) (float64, error) {
+	source, target, _ := alignedReturns(s, benchmark)
+	if lookback < 2 || lookback > len(source) {
+		return 0, ErrNotEnoughHistory
+	}
+
+	source, target = source[len(source)-lookback:], target[len(target)-lookback:]
+	variance := statistics.Covariance(&target, &target)
+	if variance == 0 {
+		return 0, ErrNotEnoughHistory
+	}
+	return statistics.Covariance(&source, &target) / variance, nil
+}
+
 func (s *Stock) SetHistoricalImpliedVol(newVolArray *ImpliedVolatilityTypeSlice) {
 	s.historicalImpliedVol = *newVolArray
 }
@@ -153,6 +170,31 @@ func (s *Stock) HistoricalPrice() []PriceHistoryType {
 	return s.historicalPrice
 }
 
+//SetDividends sets the schedule of expected cash dividends, sorting it by ex-dividend date
+func (s *Stock) SetDividends(dividends []Dividend) {
+	s.dividends = make([]Dividend, len(dividends))
+	copy(s.dividends, dividends)
+	sort.Slice(s.dividends, func(i, j int) bool {
+		return s.dividends[i].exDate.Before(s.dividends[j].exDate)
+	})
+}
+
+//Dividends returns the schedule of expected cash dividends, sorted by ex-dividend date
+func (s *Stock) Dividends() []Dividend {
+	return s.dividends
+}
+
+//DividendsBetween returns the dividends that go ex after from and on or before to
+func (s *Stock) DividendsBetween(from time.Time, to time.Time) []Dividend {
+	var divs []Dividend
+	for _, d := range s.dividends {
+		if d.exDate.After(from) && !d.exDate.After(to) {
+			divs = append(divs, d)
+		}
+	}
+	return divs
+}
+
 func (s *Stock) CurrentImpliedVolatility() float32 {
 	return s.historicalImpliedVol[len(s.historicalImpliedVol)-1].impliedVolatility
 }
@@ -171,25 +213,98 @@ func (s *Stock) CurrentImpliedVolatilityRank() float32 {
 	return rank
 }
 
-// TODO: HIGH : FInish
-func (s *Stock) Correlation(targetStock *Stock, length int) float32 {
-	// corrlate s to targetStock
-	return 0.0
+//Correlation returns the correlation of the daily log returns of s and targetStock over the last length returns.
+//Only the days both stocks have a close for are used, so a missing bar doesn't mismatch the returns
+func (s *Stock) Correlation(targetStock *Stock, length int) (float64, error) {
+	source, target, _ := alignedReturns(s, targetStock)
+	if length < 2 || length > len(source) {
+		return 0, ErrNotEnoughHistory
+	}
+
+	return correlation(source[len(source)-length:], target[len(target)-length:]), nil
+}
+
+//RollingCorrelation returns the correlation of the daily log returns of s and targetStock over every window
+//return period, each stamped with the date of its last day
+func (s *Stock) RollingCorrelation(targetStock *Stock, window int) ([]CorrelationPoint, error) {
+	source, target, dates := alignedReturns(s, targetStock)
+	if window < 2 || window > len(source) {
+		return nil, ErrNotEnoughHistory
+	}
+
+	points := make([]CorrelationPoint, 0, len(source)-window+1)
+	for end := window; end <= len(source); end++ {
+		points = append(points, CorrelationPoint{
+			Correlation: correlation(source[end-window:end], target[end-window:end]),
+			TimeStamp:   dates[end-1],
+		})
+	}
+
+	return points, nil
+}
+
+//CorrelationPoint is a correlation as of a timestamp
+type CorrelationPoint struct {
+	Correlation float64
+	TimeStamp   time.Time
+}
+
+func (cp CorrelationPoint) String() string {
+	return fmt.Sprintf("correlation: %.4f on %s", cp.Correlation, cp.TimeStamp)
 }
 
-/* FUTURE
-func (s *Stock) CurrentImnpliedVolatailityPercentile() float64 {
-	for key, val := range s.historicalImpliedVol {
+//alignedReturns returns the log returns of a and b between the consecutive days both have a close for,
+//with the date each return ends on
+func alignedReturns(a *Stock, b *Stock) (statistics.Float64, statistics.Float64, []time.Time) {
+	closes := make(map[string]float64, len(b.HistoricalPrice()))
+	for _, ph := range b.HistoricalPrice() {
+		c, _ := ph.Close().Value.Float64()
+		closes[ph.TimeStamp().Format("2006-01-02")] = c
+	}
+
+	var ra, rb statistics.Float64
+	var dates []time.Time
+	var prevA, prevB float64
+	for _, ph := range a.HistoricalPrice() {
+		cb, ok := closes[ph.TimeStamp().Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		ca, _ := ph.Close().Value.Float64()
+		if ca <= 0 || cb <= 0 {
+			continue
+		}
+		if prevA > 0 {
+			ra = append(ra, math.Log(ca/prevA))
+			rb = append(rb, math.Log(cb/prevB))
+			dates = append(dates, ph.TimeStamp())
+		}
+		prevA, prevB = ca, cb
 	}
-	return 0.0
+
+	return ra, rb, dates
 }
-*/
 
+//correlation returns the Pearson correlation of a and b, or 0 if either doesn't move
+func correlation(a statistics.Float64, b statistics.Float64) float64 {
+	sd := statistics.Sd(&a) * statistics.Sd(&b)
+	if sd == 0 {
+		return 0
+	}
+	return statistics.Covariance(&a, &b) / sd
+}
+
+//PriceHistoryType is one bar of price history. Bars created with NewPriceHistoryPoint only carry the close
 type PriceHistoryType struct {
+	openPrice  financial.Money
+	highPrice  financial.Money
+	lowPrice   financial.Money
 	closePrice financial.Money
+	volume     float64
 	timeStamp  time.Time
 }
 
+//NewPriceHistoryPoint returns a bar with only a closing price
 func NewPriceHistoryPoint(c financial.Money, ts time.Time) PriceHistoryType {
 	return PriceHistoryType{
 		closePrice: c,
@@ -197,6 +312,43 @@ func NewPriceHistoryPoint(c financial.Money, ts time.Time) PriceHistoryType {
 	}
 }
 
+//NewPriceHistoryBar returns a bar with open, high, low, close and volume
+func NewPriceHistoryBar(o financial.Money, h financial.Money, l financial.Money, c financial.Money, volume float64, ts time.Time) PriceHistoryType {
+	return PriceHistoryType{
+		openPrice:  o,
+		highPrice:  h,
+		lowPrice:   l,
+		closePrice: c,
+		volume:     volume,
+		timeStamp:  ts,
+	}
+}
+
+//Open returns the opening price, Value is nil if the bar only has a close
+func (ph PriceHistoryType) Open() financial.Money {
+	return ph.openPrice
+}
+
+//High returns the high price, Value is nil if the bar only has a close
+func (ph PriceHistoryType) High() financial.Money {
+	return ph.highPrice
+}
+
+//Low returns the low price, Value is nil if the bar only has a close
+func (ph PriceHistoryType) Low() financial.Money {
+	return ph.lowPrice
+}
+
+//Volume returns the number of shares traded
+func (ph PriceHistoryType) Volume() float64 {
+	return ph.volume
+}
+
+//HasRange returns true if the bar has open, high and low prices as well as the close
+func (ph PriceHistoryType) HasRange() bool {
+	return ph.openPrice.Value != nil && ph.highPrice.Value != nil && ph.lowPrice.Value != nil
+}
+
 func (ph PriceHistoryType) Close() financial.Money {
 	return ph.closePrice
 }
@@ -214,5 +366,36 @@ func (ph PriceHistoryType) SetTimeStamp(newVal time.Time) {
 }
 
 func (ph PriceHistoryType) String() string {
+	if ph.HasRange() {
+		return fmt.Sprintf("data: Open: %s High: %s Low: %s Close: %s Volume: %.0f on %s", ph.Open(), ph.High(), ph.Low(), ph.Close(), ph.Volume(), ph.TimeStamp())
+	}
 	return fmt.Sprintf("data: Close: %s on %s", ph.Close(), ph.TimeStamp())
 }
+
+//Dividend is a cash dividend paid per share
+type Dividend struct {
+	exDate time.Time
+	amount float64
+}
+
+//NewDividend returns a dividend of amount per share going ex on exDate
+func NewDividend(exDate time.Time, amount float64) Dividend {
+	return Dividend{
+		exDate: exDate,
+		amount: amount,
+	}
+}
+
+//ExDate returns the ex-dividend date
+func (d Dividend) ExDate() time.Time {
+	return d.exDate
+}
+
+//Amount returns the cash paid per share
+func (d Dividend) Amount() float64 {
+	return d.amount
+}
+
+func (d Dividend) String() string {
+	return fmt.Sprintf("dividend: %.4f ex %s", d.amount, d.exDate.Format("2006-01-02"))
+}
@@ -0,0 +1,309 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package asset
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+//TradingDaysPerYear is used to annualize daily volatility
+const TradingDaysPerYear = 252
+
+//errors returned by the volatility estimators
+var (
+	ErrNotEnoughHistory = errors.New("not enough history for the requested window")
+	ErrNoRange          = errors.New("price history has no open, high and low")
+	ErrInvalidPrice     = errors.New("price history has a price <= 0")
+)
+
+//HVEstimator is the method used to estimate realized (historical) volatility
+//reference http://www.todaysgroep.nl/media/236846/measuring_historic_volatility.pdf
+type HVEstimator int
+
+//enumeration values for HVEstimator
+const (
+	CloseToClose HVEstimator = iota // standard deviation of log close to close returns
+	Parkinson                       // uses the high/low range
+	GarmanKlass                     // uses open, high, low and close
+	YangZhang                       // handles opening gaps and drift
+)
+
+func (e HVEstimator) String() string {
+	switch e {
+	case CloseToClose:
+		return "CloseToClose"
+	case Parkinson:
+		return "Parkinson"
+	case GarmanKlass:
+		return "GarmanKlass"
+	case YangZhang:
+		return "YangZhang"
+	}
+	return ""
+}
+
+//VolatilityPoint is a volatility as of a timestamp
+type VolatilityPoint struct {
+	Volatility float64
+	TimeStamp  time.Time
+}
+
+func (vp VolatilityPoint) String() string {
+	return fmt.Sprintf("vol: %.4f on %s", vp.Volatility, vp.TimeStamp)
+}
+
+//bar is a price history bar as floats. prevClose is the close of the bar before
+type bar struct {
+	open, high, low, close, prevClose float64
+}
+
+//bars converts the price history to floats. The first bar of history is only used for its close
+func (s *Stock) bars(needRange bool) ([]bar, error) {
+	history := s.HistoricalPrice()
+	if len(history) < 2 {
+		return nil, ErrNotEnoughHistory
+	}
+
+	bars := make([]bar, 0, len(history)-1)
+	prevClose, _ := history[0].Close().Value.Float64()
+	for _, ph := range history[1:] {
+		var b bar
+		b.close, _ = ph.Close().Value.Float64()
+		b.prevClose = prevClose
+		if needRange {
+			if !ph.HasRange() {
+				return nil, ErrNoRange
+			}
+			b.open, _ = ph.Open().Value.Float64()
+			b.high, _ = ph.High().Value.Float64()
+			b.low, _ = ph.Low().Value.Float64()
+			if b.open <= 0 || b.high <= 0 || b.low <= 0 {
+				return nil, ErrInvalidPrice
+			}
+		}
+		if b.close <= 0 || b.prevClose <= 0 {
+			return nil, ErrInvalidPrice
+		}
+		bars = append(bars, b)
+		prevClose = b.close
+	}
+
+	return bars, nil
+}
+
+//HistoricalVolatility returns the annualized realized volatility over the last window days of price history
+func (s *Stock) HistoricalVolatility(estimator HVEstimator, window int) (float64, error) {
+	bars, err := s.bars(estimator != CloseToClose)
+	if err != nil {
+		return 0, err
+	}
+
+	if window < 2 || window > len(bars) {
+		return 0, ErrNotEnoughHistory
+	}
+
+	return estimate(estimator, bars[len(bars)-window:])
+}
+
+//RollingHistoricalVolatility returns the annualized realized volatility of every window day period of price history,
+//each stamped with the date of its last day
+func (s *Stock) RollingHistoricalVolatility(estimator HVEstimator, window int) ([]VolatilityPoint, error) {
+	bars, err := s.bars(estimator != CloseToClose)
+	if err != nil {
+		return nil, err
+	}
+
+	if window < 2 || window > len(bars) {
+		return nil, ErrNotEnoughHistory
+	}
+
+	history := s.HistoricalPrice()
+	points := make([]VolatilityPoint, 0, len(bars)-window+1)
+	for end := window; end <= len(bars); end++ {
+		vol, err := estimate(estimator, bars[end-window:end])
+		if err != nil {
+			return nil, err
+		}
+		// bars[i] is history[i+1]
+		points = append(points, VolatilityPoint{Volatility: vol, TimeStamp: history[end].TimeStamp()})
+	}
+
+	return points, nil
+}
+
+func estimate(estimator HVEstimator, bars []bar) (float64, error) {
+	n := float64(len(bars))
+	var variance float64
+
+	switch estimator {
+	case CloseToClose:
+		returns := make([]float64, len(bars))
+		for i, b := range bars {
+			returns[i] = math.Log(b.close / b.prevClose)
+		}
+		variance = sampleVariance(returns)
+
+	case Parkinson:
+		var sum float64
+		for _, b := range bars {
+			hl := math.Log(b.high / b.low)
+			sum += hl * hl
+		}
+		variance = sum / (4 * math.Ln2 * n)
+
+	case GarmanKlass:
+		var sum float64
+		for _, b := range bars {
+			hl := math.Log(b.high / b.low)
+			co := math.Log(b.close / b.open)
+			sum += 0.5*hl*hl - (2*math.Ln2-1)*co*co
+		}
+		variance = sum / n
+
+	case YangZhang:
+		overnight := make([]float64, len(bars))
+		openClose := make([]float64, len(bars))
+		var rs float64
+		for i, b := range bars {
+			overnight[i] = math.Log(b.open / b.prevClose)
+			openClose[i] = math.Log(b.close / b.open)
+			rs += math.Log(b.high/b.close)*math.Log(b.high/b.open) + math.Log(b.low/b.close)*math.Log(b.low/b.open)
+		}
+		k := 0.34 / (1.34 + (n+1)/(n-1))
+		variance = sampleVariance(overnight) + k*sampleVariance(openClose) + (1-k)*rs/n
+
+	default:
+		return 0, fmt.Errorf("unsupported estimator %d", estimator)
+	}
+
+	return math.Sqrt(math.Max(variance, 0) * TradingDaysPerYear), nil
+}
+
+func sampleVariance(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	var sum float64
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+	return sum / float64(len(values)-1)
+}
+
+//ImpliedVolatilityPercentile returns the fraction (0 to 1) of the previous lookback observations of implied
+//volatility that were below the current implied volatility. A lookback of 0 uses all of the history
+func (s *Stock) ImpliedVolatilityPercentile(lookback int) (float64, error) {
+	history := s.HistoricalImpliedVol()
+	if len(history) < 2 {
+		return 0, ErrNotEnoughHistory
+	}
+
+	previous := history[:len(history)-1]
+	if lookback > 0 {
+		if lookback > len(previous) {
+			return 0, ErrNotEnoughHistory
+		}
+		previous = previous[len(previous)-lookback:]
+	}
+
+	current := history[len(history)-1].impliedVolatility
+	var below int
+	for _, iv := range previous {
+		if iv.impliedVolatility < current {
+			below++
+		}
+	}
+
+	return float64(below) / float64(len(previous)), nil
+}
+
+//ImpliedVolatilityRank returns where the current implied volatility sits (0 to 1) between the low and high of the
+//last lookback observations. A lookback of 0 uses all of the history, like CurrentImpliedVolatilityRank
+func (s *Stock) ImpliedVolatilityRank(lookback int) (float64, error) {
+	history := s.HistoricalImpliedVol()
+	if len(history) < 2 {
+		return 0, ErrNotEnoughHistory
+	}
+
+	if lookback > 0 {
+		if lookback > len(history) {
+			return 0, ErrNotEnoughHistory
+		}
+		history = history[len(history)-lookback:]
+	}
+
+	low, high := history[0].impliedVolatility, history[0].impliedVolatility
+	for _, iv := range history {
+		if iv.impliedVolatility < low {
+			low = iv.impliedVolatility
+		}
+		if iv.impliedVolatility > high {
+			high = iv.impliedVolatility
+		}
+	}
+
+	if high == low {
+		return 0, nil
+	}
+
+	return float64((history[len(history)-1].impliedVolatility - low) / (high - low)), nil
+}
+
+//ImpliedToHistoricalRatio returns the current implied volatility divided by the realized volatility over window.
+//Above 1 the options are pricing more movement than the stock has shown. Implied volatility history must be
+//annualized decimals, the same units as HistoricalVolatility
+func (s *Stock) ImpliedToHistoricalRatio(estimator HVEstimator, window int) (float64, error) {
+	if len(s.HistoricalImpliedVol()) == 0 {
+		return 0, ErrNotEnoughHistory
+	}
+
+	hv, err := s.HistoricalVolatility(estimator, window)
+	if err != nil {
+		return 0, err
+	}
+	if hv == 0 {
+		return 0, errors.New("realized volatility is 0")
+	}
+
+	return float64(s.CurrentImpliedVolatility()) / hv, nil
+}
+
+//VolatilityRiskPremium returns the current implied volatility less the realized volatility over window
+func (s *Stock) VolatilityRiskPremium(estimator HVEstimator, window int) (float64, error) {
+	if len(s.HistoricalImpliedVol()) == 0 {
+		return 0, ErrNotEnoughHistory
+	}
+
+	hv, err := s.HistoricalVolatility(estimator, window)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(s.CurrentImpliedVolatility()) - hv, nil
+}
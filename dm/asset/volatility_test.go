@@ -0,0 +1,140 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package asset
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/lib/financial"
+)
+
+// alternatingStock closes up and down by the same log return every day, with a fixed intraday range
+func alternatingStock(days int, move float64) *Stock {
+	s := NewStock("XYZ")
+	start := time.Date(2016, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	history := make([]PriceHistoryType, 0, days)
+	c := 100.0
+	for i := 0; i < days; i++ {
+		if i > 0 {
+			if i%2 == 1 {
+				c *= math.Exp(move)
+			} else {
+				c *= math.Exp(-move)
+			}
+		}
+		history = append(history, NewPriceHistoryBar(financial.NewMoney(c), financial.NewMoney(c*1.01), financial.NewMoney(c*0.99), financial.NewMoney(c), 1000, start.AddDate(0, 0, i)))
+	}
+	s.SetHistoricalPrice(&history)
+
+	return s
+}
+
+func TestHistoricalVolatility(t *testing.T) {
+	s := alternatingStock(21, 0.01)
+
+	// returns alternate +/-1%, so the sample standard deviation is 0.01 * sqrt(n/(n-1))
+	hv, err := s.HistoricalVolatility(CloseToClose, 20)
+	if err != nil {
+		t.Fatalf("Error computing close to close: %v", err)
+	}
+	want := 0.01 * math.Sqrt(20.0/19.0) * math.Sqrt(TradingDaysPerYear)
+	if math.Abs(hv-want) > 1e-9 {
+		t.Errorf("close to close got %.6f, want %.6f", hv, want)
+	}
+
+	pk, err := s.HistoricalVolatility(Parkinson, 20)
+	if err != nil {
+		t.Fatalf("Error computing parkinson: %v", err)
+	}
+	hl := math.Log(1.01 / 0.99)
+	want = math.Sqrt(hl * hl / (4 * math.Ln2) * TradingDaysPerYear)
+	if math.Abs(pk-want) > 1e-9 {
+		t.Errorf("parkinson got %.6f, want %.6f", pk, want)
+	}
+
+	for _, est := range []HVEstimator{GarmanKlass, YangZhang} {
+		if v, err := s.HistoricalVolatility(est, 20); err != nil || v <= 0 {
+			t.Errorf("%s got %.6f, %v", est, v, err)
+		}
+	}
+
+	if _, err := s.HistoricalVolatility(CloseToClose, 21); err != ErrNotEnoughHistory {
+		t.Errorf("a window longer than the history should fail, got %v", err)
+	}
+
+	points, err := s.RollingHistoricalVolatility(CloseToClose, 10)
+	if err != nil {
+		t.Fatalf("Error computing rolling volatility: %v", err)
+	}
+	if len(points) != 11 || !points[10].TimeStamp.Equal(s.HistoricalPrice()[20].TimeStamp()) {
+		t.Errorf("got %d points ending %v", len(points), points[len(points)-1].TimeStamp)
+	}
+}
+
+func TestRangeEstimatorNeedsBars(t *testing.T) {
+	s := NewStock("XYZ")
+	history := []PriceHistoryType{
+		NewPriceHistoryPoint(financial.NewMoney(100), time.Now().AddDate(0, 0, -2)),
+		NewPriceHistoryPoint(financial.NewMoney(101), time.Now().AddDate(0, 0, -1)),
+		NewPriceHistoryPoint(financial.NewMoney(100), time.Now()),
+	}
+	s.SetHistoricalPrice(&history)
+
+	if _, err := s.HistoricalVolatility(Parkinson, 2); err != ErrNoRange {
+		t.Errorf("expected ErrNoRange, got %v", err)
+	}
+	if _, err := s.HistoricalVolatility(CloseToClose, 2); err != nil {
+		t.Errorf("close to close only needs closes, got %v", err)
+	}
+}
+
+func TestImpliedVolatilityPercentile(t *testing.T) {
+	s := alternatingStock(21, 0.01)
+
+	ivs := ImpliedVolatilityTypeSlice{}
+	for i, iv := range []float32{0.30, 0.20, 0.25, 0.15, 0.35, 0.22} {
+		ivs = append(ivs, NewImpliedVolInstance(iv, time.Now().AddDate(0, 0, i)))
+	}
+	s.SetHistoricalImpliedVol(&ivs)
+
+	// 0.22 is above 0.20 and 0.15 of the previous 5
+	if p, err := s.ImpliedVolatilityPercentile(0); err != nil || math.Abs(p-0.4) > 1e-9 {
+		t.Errorf("percentile got %.4f, %v, want 0.4", p, err)
+	}
+	// of 0.15 and 0.35
+	if p, err := s.ImpliedVolatilityPercentile(2); err != nil || math.Abs(p-0.5) > 1e-9 {
+		t.Errorf("percentile over 2 got %.4f, %v, want 0.5", p, err)
+	}
+	if _, err := s.ImpliedVolatilityPercentile(6); err != ErrNotEnoughHistory {
+		t.Errorf("expected ErrNotEnoughHistory, got %v", err)
+	}
+
+	// 0.22 between 0.15 and 0.35
+	if r, err := s.ImpliedVolatilityRank(0); err != nil || math.Abs(r-0.35) > 1e-6 {
+		t.Errorf("rank got %.4f, %v, want 0.35", r, err)
+	}
+
+	hv, _ := s.HistoricalVolatility(CloseToClose, 20)
+	if ratio, err := s.ImpliedToHistoricalRatio(CloseToClose, 20); err != nil || math.Abs(ratio-0.22/hv) > 1e-6 {
+		t.Errorf("ratio got %.4f, %v, want %.4f", ratio, err, 0.22/hv)
+	}
+}
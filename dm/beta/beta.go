@@ -0,0 +1,308 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package beta computes and caches the beta of symbols to a benchmark, so beta weighting a portfolio only costs
+//a map lookup per position once the betas are known
+package beta
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/lib/mjlog"
+)
+
+var (
+	logInfo  = log.New(mjlog.CreateInfoFile(), "INFO  [beta]: ", log.LstdFlags|log.Lshortfile)
+	logDebug = log.New(mjlog.CreateDebugFile(), "DEBUG [beta]: ", log.LstdFlags|log.Lshortfile)
+	logError = log.New(mjlog.CreateErrorFile(), "ERROR [beta]: ", log.LstdFlags|log.Lshortfile)
+)
+
+//DefaultLookback is the number of daily returns beta is computed over, about 3 months
+const DefaultLookback = 60
+
+//DefaultRetryAfter is how long a failed history load is cached before it is tried again
+const DefaultRetryAfter = time.Minute
+
+//common benchmarks, an ETF and the index it tracks
+const (
+	SPY = "SPY"
+	SPX = "$SPX.X"
+)
+
+//errors returned by the beta service
+var (
+	ErrNoHistory = errors.New("no price history and no history source to load it from")
+	ErrNoPrice   = errors.New("no price to beta weight with")
+)
+
+//HistorySource loads price history into a stock. generic.Broker satisfies it
+type HistorySource interface {
+	RetrievePriceHistory(stockSymbol string, stock *asset.Stock) error
+}
+
+//PriceSource selects the price used to convert beta weighted deltas between a symbol and its benchmark
+type PriceSource int
+
+//enumeration values for PriceSource
+const (
+	PriceMid  PriceSource = iota // mid of the bid and ask, the last trade when one side isn't quoted
+	PriceMark                    // the position's mark, the mid for a quote that isn't a position
+	PriceLast                    // last trade, the mid when there hasn't been a trade
+)
+
+func (ps PriceSource) String() string {
+	switch ps {
+	case PriceMid:
+		return "PriceMid"
+	case PriceMark:
+		return "PriceMark"
+	case PriceLast:
+		return "PriceLast"
+	}
+	return ""
+}
+
+//Price returns the price of stock from source. Indexes aren't quoted with a bid and ask, so the mid falls back
+//to the last trade, and either falls back to the last close in the price history
+func Price(stock *asset.Stock, source PriceSource) float64 {
+	if stock == nil {
+		return 0
+	}
+
+	b, _ := stock.BidPrice().Value.Float64()
+	a, _ := stock.AskPrice().Value.Float64()
+	l, _ := stock.LastTradePrice().Value.Float64()
+
+	mid := 0.0
+	if b > 0 && a > 0 {
+		mid = (b + a) / 2
+	}
+
+	var price float64
+	switch source {
+	case PriceLast:
+		price = l
+		if price <= 0 {
+			price = mid
+		}
+	default:
+		price = mid
+		if price <= 0 {
+			price = l
+		}
+	}
+
+	if history := stock.HistoricalPrice(); price <= 0 && len(history) > 0 {
+		price, _ = history[len(history)-1].Close().Value.Float64()
+	}
+	return price
+}
+
+type key struct {
+	symbol    string
+	benchmark string
+	lookback  int
+}
+
+type entry struct {
+	beta    float64
+	err     error
+	retry   bool      // the history source failed, the failure may be transient
+	expires time.Time // zero for entries that stay cached until invalidated
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+//Service caches betas by symbol, benchmark and lookback. Price history that isn't already on a stock is loaded
+//once from the history source. Betas are computed from daily closes, so the cache only needs to be cleared once
+//a day, or a symbol invalidated when its history is reloaded. Failures of the history source may be transient
+//and are only cached for the retry period
+type Service struct {
+	mutex       sync.RWMutex
+	source      HistorySource
+	lookback    int
+	retryAfter  time.Duration
+	priceSource PriceSource
+	betas       map[key]entry
+	histories   map[string]*asset.Stock
+}
+
+//NewService returns a service loading missing history from source, which may be nil
+func NewService(source HistorySource, lookback int, priceSource PriceSource) *Service {
+	if lookback < 2 {
+		lookback = DefaultLookback
+	}
+	return &Service{
+		source:      source,
+		lookback:    lookback,
+		retryAfter:  DefaultRetryAfter,
+		priceSource: priceSource,
+		betas:       make(map[key]entry),
+		histories:   make(map[string]*asset.Stock),
+	}
+}
+
+func (s *Service) Lookback() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.lookback
+}
+
+//SetLookback changes the lookback of later lookups. Betas for other lookbacks stay cached
+func (s *Service) SetLookback(lookback int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if lookback >= 2 {
+		s.lookback = lookback
+	}
+}
+
+func (s *Service) RetryAfter() time.Duration {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.retryAfter
+}
+
+//SetRetryAfter changes how long later failures of the history source are cached
+func (s *Service) SetRetryAfter(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.retryAfter = d
+}
+
+func (s *Service) PriceSource() PriceSource {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.priceSource
+}
+
+func (s *Service) SetPriceSource(ps PriceSource) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.priceSource = ps
+}
+
+//Beta returns the beta of stock to benchmark over the service's lookback. A symbol is its own benchmark with a
+//beta of 1. Failures are cached as well, so a symbol without history isn't reloaded on every tick, but a
+//failure to load history is retried once the retry period has passed
+func (s *Service) Beta(stock *asset.Stock, benchmark *asset.Stock) (float64, error) {
+	if stock.Symbol() == benchmark.Symbol() {
+		return 1, nil
+	}
+
+	s.mutex.RLock()
+	k := key{symbol: stock.Symbol(), benchmark: benchmark.Symbol(), lookback: s.lookback}
+	e, ok := s.betas[k]
+	s.mutex.RUnlock()
+	if ok && !e.expired(time.Now()) {
+		return e.beta, e.err
+	}
+
+	e = s.compute(stock, benchmark, k.lookback)
+
+	s.mutex.Lock()
+	if e.retry {
+		e.expires = time.Now().Add(s.retryAfter)
+	}
+	s.betas[k] = e
+	s.mutex.Unlock()
+
+	if e.err != nil {
+		logError.Printf("Beta of %s to %s: %s\n", k.symbol, k.benchmark, e.err)
+	} else {
+		logDebug.Printf("Beta of %s to %s over %d days: %.4f\n", k.symbol, k.benchmark, k.lookback, e.beta)
+	}
+	return e.beta, e.err
+}
+
+func (s *Service) compute(stock *asset.Stock, benchmark *asset.Stock, lookback int) entry {
+	sh, retry, err := s.history(stock)
+	if err != nil {
+		return entry{err: err, retry: retry}
+	}
+	bh, retry, err := s.history(benchmark)
+	if err != nil {
+		return entry{err: err, retry: retry}
+	}
+
+	b, err := sh.BetaTo(bh, lookback)
+	return entry{beta: b, err: err}
+}
+
+//history returns stock if it has price history, otherwise the history loaded earlier or now from the source.
+//retry is true when the source failed to load it
+func (s *Service) history(stock *asset.Stock) (h *asset.Stock, retry bool, err error) {
+	if len(stock.HistoricalPrice()) > 0 {
+		return stock, false, nil
+	}
+
+	s.mutex.RLock()
+	loaded, ok := s.histories[stock.Symbol()]
+	s.mutex.RUnlock()
+	if ok {
+		return loaded, false, nil
+	}
+
+	if s.source == nil {
+		return nil, false, ErrNoHistory
+	}
+
+	loaded = asset.NewStock(stock.Symbol())
+	if err := s.source.RetrievePriceHistory(stock.Symbol(), loaded); err != nil {
+		return nil, true, err
+	}
+
+	s.mutex.Lock()
+	s.histories[stock.Symbol()] = loaded
+	s.mutex.Unlock()
+	return loaded, false, nil
+}
+
+//Price returns the price of stock from the service's price source
+func (s *Service) Price(stock *asset.Stock) float64 {
+	return Price(stock, s.PriceSource())
+}
+
+//Invalidate drops the cached betas and loaded history of symbol, as a symbol or a benchmark
+func (s *Service) Invalidate(symbol string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.histories, symbol)
+	for k := range s.betas {
+		if k.symbol == symbol || k.benchmark == symbol {
+			delete(s.betas, k)
+		}
+	}
+}
+
+//Clear drops every cached beta and loaded history, typically once a day after the close
+func (s *Service) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.betas = make(map[key]entry)
+	s.histories = make(map[string]*asset.Stock)
+	logInfo.Printf("Cleared beta cache\n")
+}
@@ -0,0 +1,144 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package beta
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/lib/financial"
+)
+
+var start = time.Date(2016, 1, 4, 16, 0, 0, 0, time.UTC)
+
+// history returns closes starting at 100 whose log returns are scale times a fixed path
+func history(scale float64, n int) []asset.PriceHistoryType {
+	c := 100.0
+	h := []asset.PriceHistoryType{asset.NewPriceHistoryPoint(financial.NewMoney(c), start)}
+	for i := 1; i <= n; i++ {
+		c *= math.Exp(scale * 0.01 * math.Sin(float64(i)*0.9))
+		h = append(h, asset.NewPriceHistoryPoint(financial.NewMoney(c), start.AddDate(0, 0, i)))
+	}
+	return h
+}
+
+type countingSource struct {
+	loads map[string]int
+	scale map[string]float64
+	err   error
+}
+
+func (cs *countingSource) RetrievePriceHistory(symbol string, stock *asset.Stock) error {
+	cs.loads[symbol]++
+	if cs.err != nil {
+		return cs.err
+	}
+	h := history(cs.scale[symbol], 80)
+	stock.SetHistoricalPrice(&h)
+	return nil
+}
+
+func TestServiceCaches(t *testing.T) {
+	source := &countingSource{loads: map[string]int{}, scale: map[string]float64{"AAA": 2, SPX: 1}}
+	s := NewService(source, DefaultLookback, PriceMid)
+
+	for i := 0; i < 3; i++ {
+		b, err := s.Beta(asset.NewStock("AAA"), asset.NewStock(SPX))
+		if err != nil {
+			t.Fatalf("Error computing beta: %v", err)
+		}
+		if math.Abs(b-2) > 1e-9 {
+			t.Errorf("twice the returns got beta %.6f, want 2", b)
+		}
+	}
+	if source.loads["AAA"] != 1 || source.loads[SPX] != 1 {
+		t.Errorf("history should load once, got %v", source.loads)
+	}
+
+	if b, _ := s.Beta(asset.NewStock(SPX), asset.NewStock(SPX)); b != 1 {
+		t.Errorf("the benchmark's own beta got %.4f", b)
+	}
+
+	s.Invalidate("AAA")
+	s.Beta(asset.NewStock("AAA"), asset.NewStock(SPX))
+	if source.loads["AAA"] != 2 || source.loads[SPX] != 1 {
+		t.Errorf("only the invalidated symbol reloads, got %v", source.loads)
+	}
+
+	if _, err := NewService(nil, 0, PriceMid).Beta(asset.NewStock("AAA"), asset.NewStock(SPX)); err != ErrNoHistory {
+		t.Errorf("without history or a source got %v", err)
+	}
+}
+
+func TestServiceRetriesSourceFailures(t *testing.T) {
+	source := &countingSource{loads: map[string]int{}, scale: map[string]float64{"AAA": 2, SPX: 1}, err: errors.New("timeout")}
+	s := NewService(source, DefaultLookback, PriceMid)
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.Beta(asset.NewStock("AAA"), asset.NewStock(SPX)); err != source.err {
+			t.Fatalf("failed load got %v, want %v", err, source.err)
+		}
+	}
+	if source.loads["AAA"] != 1 {
+		t.Errorf("a failure is cached for the retry period, got %d loads", source.loads["AAA"])
+	}
+
+	s.SetRetryAfter(0)
+	s.Invalidate("AAA")
+	s.Beta(asset.NewStock("AAA"), asset.NewStock(SPX))
+	source.err = nil
+	b, err := s.Beta(asset.NewStock("AAA"), asset.NewStock(SPX))
+	if err != nil || math.Abs(b-2) > 1e-9 {
+		t.Errorf("after the source recovers got %.6f, %v", b, err)
+	}
+	if source.loads["AAA"] != 3 {
+		t.Errorf("an expired failure is retried, got %d loads", source.loads["AAA"])
+	}
+
+	noHistory := NewService(nil, 0, PriceMid)
+	noHistory.SetRetryAfter(0)
+	noHistory.Beta(asset.NewStock("AAA"), asset.NewStock(SPX))
+	if e := noHistory.betas[key{symbol: "AAA", benchmark: SPX, lookback: DefaultLookback}]; e.err != ErrNoHistory || !e.expires.IsZero() {
+		t.Errorf("no history is definitive and stays cached, got %+v", e)
+	}
+}
+
+func TestPrice(t *testing.T) {
+	s := asset.NewStock("XYZ")
+	s.SetLastTradePrice(financial.NewMoney(10.10))
+	if p := Price(s, PriceMid); p != 10.10 {
+		t.Errorf("an index without a bid and ask falls back to the last, got %.2f", p)
+	}
+
+	s.SetBidPrice(financial.NewMoney(10))
+	s.SetAskPrice(financial.NewMoney(10.20))
+	if p := Price(s, PriceMark); math.Abs(p-10.10) > 1e-9 {
+		t.Errorf("mid got %.4f", p)
+	}
+
+	idx := asset.NewStock(SPX)
+	h := history(1, 3)
+	idx.SetHistoricalPrice(&h)
+	if c, _ := h[3].Close().Value.Float64(); Price(idx, PriceLast) != c {
+		t.Errorf("an unquoted symbol falls back to the last close")
+	}
+}
@@ -0,0 +1,210 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package commission models the commissions and fees charged on a trade, and records the charges actually applied by the broker
+package commission
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/marklaczynski/acidbath/dm/order"
+	"github.com/marklaczynski/acidbath/lib/mjlog"
+	"github.com/marklaczynski/acidbath/lib/orderconst"
+)
+
+var (
+	logInfo  = log.New(mjlog.CreateInfoFile(), "INFO  [commission]: ", log.LstdFlags|log.Lshortfile)
+	logDebug = log.New(mjlog.CreateDebugFile(), "DEBUG [commission]: ", log.LstdFlags|log.Lshortfile)
+	logError = log.New(mjlog.CreateErrorFile(), "ERROR [commission]: ", log.LstdFlags|log.Lshortfile)
+)
+
+//Trade describes an execution (or a planned one) that fees are calculated on
+type Trade struct {
+	Symbol      string
+	Instruction orderconst.OrderInstruction
+	Quantity    float64 // contracts
+	Price       float64 // per share premium
+	Multiplier  float64 // shares per contract, 100 if 0
+}
+
+//Notional returns the premium value of the trade
+func (t Trade) Notional() float64 {
+	multiplier := t.Multiplier
+	if multiplier == 0 {
+		multiplier = 100
+	}
+	return t.Price * t.Quantity * multiplier
+}
+
+//Breakdown is the estimated cost of a trade split by kind of charge. All values are positive amounts paid
+type Breakdown struct {
+	Commission    float64 // broker commission
+	ExchangeFee   float64 // exchange and clearing fees
+	RegulatoryFee float64 // ORF, SEC and FINRA TAF fees
+}
+
+//Total returns the sum of every charge
+func (b Breakdown) Total() float64 {
+	return b.Commission + b.ExchangeFee + b.RegulatoryFee
+}
+
+//Add returns the sum of two breakdowns
+func (b Breakdown) Add(other Breakdown) Breakdown {
+	return Breakdown{
+		Commission:    b.Commission + other.Commission,
+		ExchangeFee:   b.ExchangeFee + other.ExchangeFee,
+		RegulatoryFee: b.RegulatoryFee + other.RegulatoryFee,
+	}
+}
+
+//Sub returns the difference of two breakdowns
+func (b Breakdown) Sub(other Breakdown) Breakdown {
+	return Breakdown{
+		Commission:    round(b.Commission - other.Commission),
+		ExchangeFee:   round(b.ExchangeFee - other.ExchangeFee),
+		RegulatoryFee: round(b.RegulatoryFee - other.RegulatoryFee),
+	}
+}
+
+func (b Breakdown) String() string {
+	return fmt.Sprintf("commission: %.2f exchange: %.2f regulatory: %.2f total: %.2f", b.Commission, b.ExchangeFee, b.RegulatoryFee, b.Total())
+}
+
+//Model calculates the fees for a trade. Implementations must be safe for concurrent use
+type Model interface {
+	Estimate(t Trade) Breakdown
+}
+
+//Schedule is a Model built from a broker's published fee schedule. Zero values mean the charge does not apply
+type Schedule struct {
+	PerOrder              float64 // flat commission per order
+	PerContract           float64 // commission per contract
+	MinCommission         float64 // minimum commission per order
+	MaxCommission         float64 // maximum commission per order
+	ExchangePerContract   float64 // exchange/clearing fee per contract
+	RegulatoryPerContract float64 // options regulatory fee per contract
+	SECRate               float64 // SEC fee as a fraction of notional, on sales only
+	TAFPerContract        float64 // FINRA trading activity fee per contract, on sales only
+	MaxTAF                float64 // maximum TAF per trade
+}
+
+//DefaultSchedule returns the TD Ameritrade option schedule the framework was built against
+func DefaultSchedule() *Schedule {
+	return &Schedule{
+		PerOrder:              6.95,
+		PerContract:           0.75,
+		RegulatoryPerContract: 0.02,
+		SECRate:               0.0000231,
+		TAFPerContract:        0.002,
+		MaxTAF:                5.95,
+	}
+}
+
+//Estimate returns the fees charged on t by the schedule
+func (s *Schedule) Estimate(t Trade) Breakdown {
+	if t.Quantity <= 0 {
+		return Breakdown{}
+	}
+
+	var b Breakdown
+
+	b.Commission = s.PerOrder + s.PerContract*t.Quantity
+	if s.MinCommission > 0 {
+		b.Commission = math.Max(b.Commission, s.MinCommission)
+	}
+	if s.MaxCommission > 0 {
+		b.Commission = math.Min(b.Commission, s.MaxCommission)
+	}
+
+	b.ExchangeFee = s.ExchangePerContract * t.Quantity
+
+	b.RegulatoryFee = s.RegulatoryPerContract * t.Quantity
+	if t.Instruction == orderconst.Sell {
+		taf := s.TAFPerContract * t.Quantity
+		if s.MaxTAF > 0 {
+			taf = math.Min(taf, s.MaxTAF)
+		}
+		b.RegulatoryFee += taf + s.SECRate*t.Notional()
+	}
+
+	b.Commission = round(b.Commission)
+	b.ExchangeFee = round(b.ExchangeFee)
+	b.RegulatoryFee = round(b.RegulatoryFee)
+
+	return b
+}
+
+//Zero is a Model that charges nothing
+type Zero struct{}
+
+//Estimate always returns an empty Breakdown
+func (Zero) Estimate(t Trade) Breakdown {
+	return Breakdown{}
+}
+
+//Preview is the expected cost of an order before it is sent
+type Preview struct {
+	Trade Trade
+	Fees  Breakdown
+	Gross float64 // premium paid (negative) or received (positive)
+	Net   float64 // Gross less fees
+}
+
+func (p Preview) String() string {
+	return fmt.Sprintf("%s %.0f %s @ %.2f gross: %.2f fees: %.2f net: %.2f", p.Trade.Instruction, p.Trade.Quantity, p.Trade.Symbol, p.Trade.Price, p.Gross, p.Fees.Total(), p.Net)
+}
+
+//PreviewOrder estimates the cash impact of o using model. price is the expected execution price,
+//which should be the limit price for limit orders and the far side of the market for market orders
+func PreviewOrder(o *order.Order, model Model, price float64, multiplier float64) Preview {
+	t := Trade{
+		Symbol:      o.Symbol(),
+		Instruction: Instruction(o.Action()),
+		Quantity:    float64(o.Quantity()),
+		Price:       price,
+		Multiplier:  multiplier,
+	}
+
+	p := Preview{Trade: t, Fees: model.Estimate(t)}
+
+	p.Gross = t.Notional()
+	if t.Instruction == orderconst.Buy {
+		p.Gross = -p.Gross
+	}
+	p.Net = p.Gross - p.Fees.Total()
+
+	logDebug.Printf("preview %s\n", p)
+	return p
+}
+
+//Instruction returns the side of the market an order action trades on
+func Instruction(action orderconst.OrderAction) orderconst.OrderInstruction {
+	switch action {
+	case orderconst.BuyToOpen, orderconst.BuyToClose:
+		return orderconst.Buy
+	case orderconst.SellToOpen, orderconst.SellToClose:
+		return orderconst.Sell
+	}
+	return orderconst.InvalidOrderInstruction
+}
+
+func round(v float64) float64 {
+	return math.Floor(v*100+0.5) / 100
+}
@@ -0,0 +1,58 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package commission
+
+import (
+	"testing"
+
+	"github.com/marklaczynski/acidbath/lib/orderconst"
+)
+
+func TestScheduleEstimate(t *testing.T) {
+	s := &Schedule{
+		PerOrder:              5,
+		PerContract:           1,
+		MinCommission:         7,
+		MaxCommission:         20,
+		ExchangePerContract:   0.1,
+		RegulatoryPerContract: 0.02,
+		SECRate:               0.0001,
+		TAFPerContract:        0.01,
+		MaxTAF:                0.05,
+	}
+
+	tests := []struct {
+		name  string
+		trade Trade
+		want  Breakdown
+	}{
+		{"minimum", Trade{Instruction: orderconst.Buy, Quantity: 1, Price: 1}, Breakdown{Commission: 7, ExchangeFee: 0.1, RegulatoryFee: 0.02}},
+		{"per contract", Trade{Instruction: orderconst.Buy, Quantity: 10, Price: 1}, Breakdown{Commission: 15, ExchangeFee: 1, RegulatoryFee: 0.2}},
+		{"maximum", Trade{Instruction: orderconst.Buy, Quantity: 50, Price: 1}, Breakdown{Commission: 20, ExchangeFee: 5, RegulatoryFee: 1}},
+		// sells add TAF (capped at 0.05) and SEC fee of 0.0001 * 1000 notional
+		{"sell fees", Trade{Instruction: orderconst.Sell, Quantity: 10, Price: 1}, Breakdown{Commission: 15, ExchangeFee: 1, RegulatoryFee: 0.35}},
+		{"no quantity", Trade{Instruction: orderconst.Sell, Quantity: 0, Price: 1}, Breakdown{}},
+	}
+
+	for _, test := range tests {
+		if got := s.Estimate(test.trade); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
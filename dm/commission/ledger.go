@@ -0,0 +1,163 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package commission
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/ordermessage"
+)
+
+//Record holds the charges the broker applied to one execution, alongside what the model expected
+type Record struct {
+	OrderID     string
+	ExecutionID string
+	Time        time.Time
+	Trade       Trade
+	Charges     map[string]float64 // broker's charge type -> amount
+	Actual      float64
+	Estimated   Breakdown
+}
+
+//Difference returns actual charges less the estimate. Positive means the broker charged more than the model
+func (r Record) Difference() float64 {
+	return r.Actual - r.Estimated.Total()
+}
+
+//Ledger records the actual charges from fills. It is safe for concurrent use
+type Ledger struct {
+	sync.RWMutex
+	model   Model
+	records []Record
+	filled  map[string]Trade // executions so far on each order id, so per order charges are estimated once
+}
+
+//NewLedger returns an empty ledger that estimates fees with model
+func NewLedger(model Model) *Ledger {
+	if model == nil {
+		model = Zero{}
+	}
+	return &Ledger{model: model, filled: make(map[string]Trade)}
+}
+
+//Model returns the model used to estimate fees
+func (l *Ledger) Model() Model {
+	return l.model
+}
+
+//RecordExecution records the charges on an execution message. Messages that are not executions are ignored.
+//Partial fills of an order are estimated as their share of the whole order, so the per order commission,
+//minimums and caps are only charged once per order id. A multiplier of 0 is estimated as 100 shares per contract,
+//like Trade. Returns the record that was added
+func (l *Ledger) RecordExecution(m *ordermessage.Message, multiplier float64) (Record, bool) {
+	if !m.IsExecution() {
+		return Record{}, false
+	}
+
+	price, _ := m.FillPrice().Value.Float64()
+	r := Record{
+		OrderID:     m.OrderID(),
+		ExecutionID: m.ExecutionID(),
+		Time:        m.ExecutionTime(),
+		Trade: Trade{
+			Symbol:      m.Symbol(),
+			Instruction: m.Instruction(),
+			Quantity:    m.FillQuantity(),
+			Price:       price,
+			Multiplier:  multiplier,
+		},
+		Charges: make(map[string]float64),
+	}
+
+	for _, c := range m.Charges() {
+		amount, _ := c.Amount().Value.Float64()
+		r.Charges[c.Type()] += math.Abs(amount)
+		r.Actual += math.Abs(amount)
+	}
+
+	l.Lock()
+	r.Estimated = l.estimate(r.OrderID, r.Trade)
+	l.records = append(l.records, r)
+	l.Unlock()
+
+	logDebug.Printf("recorded %s %s actual %.2f estimated %.2f\n", r.OrderID, r.Trade.Symbol, r.Actual, r.Estimated.Total())
+	return r, true
+}
+
+//estimate returns the fees for t as the increase in the estimate for everything filled on orderID.
+//The caller must hold the lock
+func (l *Ledger) estimate(orderID string, t Trade) Breakdown {
+	prev, ok := l.filled[orderID]
+	if orderID == "" || !ok {
+		if orderID != "" {
+			l.filled[orderID] = t
+		}
+		return l.model.Estimate(t)
+	}
+
+	// the order so far is priced at the average of its fills, so notional based fees add up
+	total := prev
+	total.Quantity += t.Quantity
+	if total.Quantity > 0 {
+		total.Price = (prev.Price*prev.Quantity + t.Price*t.Quantity) / total.Quantity
+	}
+	l.filled[orderID] = total
+
+	return l.model.Estimate(total).Sub(l.model.Estimate(prev))
+}
+
+//Records returns a copy of every record in the ledger
+func (l *Ledger) Records() []Record {
+	l.RLock()
+	defer l.RUnlock()
+
+	records := make([]Record, len(l.records))
+	copy(records, l.records)
+	return records
+}
+
+//TotalFees returns the actual fees paid on symbol, or on every symbol if symbol is ""
+func (l *Ledger) TotalFees(symbol string) float64 {
+	l.RLock()
+	defer l.RUnlock()
+
+	var total float64
+	for _, r := range l.records {
+		if symbol == "" || r.Trade.Symbol == symbol {
+			total += r.Actual
+		}
+	}
+	return total
+}
+
+//Reconcile returns the records whose actual charges differ from the model by more than tolerance
+func (l *Ledger) Reconcile(tolerance float64) []Record {
+	l.RLock()
+	defer l.RUnlock()
+
+	var mismatched []Record
+	for _, r := range l.records {
+		if math.Abs(r.Difference()) > tolerance {
+			mismatched = append(mismatched, r)
+		}
+	}
+	return mismatched
+}
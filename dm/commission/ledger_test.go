@@ -0,0 +1,86 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package commission
+
+import (
+	"math"
+	"testing"
+
+	"github.com/marklaczynski/acidbath/dm/ordermessage"
+	"github.com/marklaczynski/acidbath/lib/financial"
+	"github.com/marklaczynski/acidbath/lib/orderconst"
+)
+
+func execution(orderid string, event orderconst.OrderEvent, quantity float64, price float64, commission float64) *ordermessage.Message {
+	m := ordermessage.New(orderid, event)
+	m.SetSymbol("XYZ_011519C105")
+	m.SetInstruction(orderconst.Buy)
+	m.SetFillQuantity(quantity)
+	m.SetFillPrice(financial.NewMoney(price))
+	m.AddCharge(ordermessage.NewCharge("Commission", financial.NewMoney(commission)))
+	return m
+}
+
+func TestLedgerPartialFills(t *testing.T) {
+	l := NewLedger(&Schedule{PerOrder: 5, PerContract: 1})
+
+	// the broker charges the per order commission on the first fill only
+	fills := []struct {
+		m    *ordermessage.Message
+		want float64
+	}{
+		{execution("1", orderconst.OrderPartialFill, 3, 1.00, 8), 8},
+		{execution("1", orderconst.OrderPartialFill, 1, 1.10, 1), 1},
+		{execution("1", orderconst.OrderFill, 1, 1.20, 1), 1},
+		{execution("2", orderconst.OrderFill, 2, 1.00, 7), 7},
+	}
+
+	for i, f := range fills {
+		r, ok := l.RecordExecution(f.m, 100)
+		if !ok {
+			t.Fatalf("fill %d not recorded", i)
+		}
+		if got := r.Estimated.Total(); math.Abs(got-f.want) > 1e-9 {
+			t.Errorf("fill %d estimated %.2f, want %.2f", i, got, f.want)
+		}
+	}
+
+	if mismatched := l.Reconcile(0.01); len(mismatched) != 0 {
+		t.Errorf("got %d mismatched records, want none", len(mismatched))
+	}
+	if got := l.TotalFees(""); math.Abs(got-17) > 1e-9 {
+		t.Errorf("total fees %.2f, want 17", got)
+	}
+
+	if _, ok := l.RecordExecution(ordermessage.New("3", orderconst.OrderCancel), 100); ok {
+		t.Errorf("recorded a message that is not an execution")
+	}
+}
+
+func TestLedgerReconcile(t *testing.T) {
+	l := NewLedger(&Schedule{PerOrder: 5, PerContract: 1})
+
+	l.RecordExecution(execution("1", orderconst.OrderFill, 2, 1.00, 7), 100)
+	l.RecordExecution(execution("2", orderconst.OrderFill, 2, 1.00, 9.50), 100)
+
+	mismatched := l.Reconcile(0.01)
+	if len(mismatched) != 1 || mismatched[0].OrderID != "2" || math.Abs(mismatched[0].Difference()-2.50) > 1e-9 {
+		t.Errorf("got %+v, want order 2 overcharged by 2.50", mismatched)
+	}
+}
@@ -0,0 +1,235 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package correlation computes the correlation matrix of the underlyings in a watchlist or portfolio, so highly
+//correlated short premium positions aren't stacked on top of each other
+package correlation
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/dm/watchlists"
+	"github.com/marklaczynski/acidbath/lib/mjlog"
+)
+
+var (
+	logInfo  = log.New(mjlog.CreateInfoFile(), "INFO  [correlation]: ", log.LstdFlags|log.Lshortfile)
+	logDebug = log.New(mjlog.CreateDebugFile(), "DEBUG [correlation]: ", log.LstdFlags|log.Lshortfile)
+	logError = log.New(mjlog.CreateErrorFile(), "ERROR [correlation]: ", log.LstdFlags|log.Lshortfile)
+)
+
+//DefaultWindow is the number of daily returns correlated, about 2 months and inside the 3 months of history
+//the broker returns
+const DefaultWindow = 40
+
+//HistorySource loads price history into a stock. generic.Broker satisfies it
+type HistorySource interface {
+	RetrievePriceHistory(stockSymbol string, stock *asset.Stock) error
+}
+
+//Pair is the correlation between two symbols
+type Pair struct {
+	Symbol1     string
+	Symbol2     string
+	Correlation float64
+}
+
+func (p Pair) String() string {
+	return fmt.Sprintf("%s/%s: %.2f", p.Symbol1, p.Symbol2, p.Correlation)
+}
+
+//Matrix holds the correlation of every pair of symbols. Pairs without enough common history are NaN
+type Matrix struct {
+	symbols []string
+	index   map[string]int
+	values  [][]float64
+}
+
+//Compute returns the correlation matrix of stocks over the last window daily returns. Stocks must have
+//price history loaded
+func Compute(stocks []*asset.Stock, window int) *Matrix {
+	m := &Matrix{
+		symbols: make([]string, len(stocks)),
+		index:   make(map[string]int, len(stocks)),
+		values:  make([][]float64, len(stocks)),
+	}
+
+	for i, s := range stocks {
+		m.symbols[i] = s.Symbol()
+		m.index[s.Symbol()] = i
+		m.values[i] = make([]float64, len(stocks))
+		m.values[i][i] = 1
+	}
+
+	for i := range stocks {
+		for j := i + 1; j < len(stocks); j++ {
+			c, err := stocks[i].Correlation(stocks[j], window)
+			if err != nil {
+				logDebug.Printf("Unable to correlate %s and %s: %s\n", stocks[i].Symbol(), stocks[j].Symbol(), err)
+				c = math.NaN()
+			}
+			m.values[i][j] = c
+			m.values[j][i] = c
+		}
+	}
+
+	return m
+}
+
+//Symbols returns the symbols of the matrix, in row order
+func (m *Matrix) Symbols() []string {
+	return m.symbols
+}
+
+//Correlation returns the correlation between two symbols. ok is false if either symbol isn't in the matrix
+//or they don't have enough common history
+func (m *Matrix) Correlation(symbol1 string, symbol2 string) (c float64, ok bool) {
+	i, ok1 := m.index[symbol1]
+	j, ok2 := m.index[symbol2]
+	if !ok1 || !ok2 || math.IsNaN(m.values[i][j]) {
+		return 0, false
+	}
+	return m.values[i][j], true
+}
+
+//Pairs returns every pair of different symbols whose correlation is at least threshold, most correlated first
+func (m *Matrix) Pairs(threshold float64) []Pair {
+	var pairs []Pair
+	for i := range m.symbols {
+		for j := i + 1; j < len(m.symbols); j++ {
+			if c := m.values[i][j]; !math.IsNaN(c) && c >= threshold {
+				pairs = append(pairs, Pair{Symbol1: m.symbols[i], Symbol2: m.symbols[j], Correlation: c})
+			}
+		}
+	}
+
+	sort.Slice(pairs, func(a, b int) bool { return pairs[a].Correlation > pairs[b].Correlation })
+	return pairs
+}
+
+//Correlated returns the other symbols whose correlation with symbol is at least threshold, most correlated first
+func (m *Matrix) Correlated(symbol string, threshold float64) []Pair {
+	var pairs []Pair
+	for _, p := range m.Pairs(threshold) {
+		switch symbol {
+		case p.Symbol1:
+			pairs = append(pairs, p)
+		case p.Symbol2:
+			pairs = append(pairs, Pair{Symbol1: symbol, Symbol2: p.Symbol1, Correlation: p.Correlation})
+		}
+	}
+	return pairs
+}
+
+func (m *Matrix) String() string {
+	str := fmt.Sprintf("%8s", "")
+	for _, s := range m.symbols {
+		str += fmt.Sprintf("%8s", s)
+	}
+	for i, s := range m.symbols {
+		str += fmt.Sprintf("\n%8s", s)
+		for j := range m.symbols {
+			str += fmt.Sprintf("%8.2f", m.values[i][j])
+		}
+	}
+	return str
+}
+
+//Service builds correlation matrices, loading price history for stocks that don't have any
+type Service struct {
+	source HistorySource
+	window int
+}
+
+//NewService returns a Service that loads history from source and correlates over window returns,
+//DefaultWindow if window is 0
+func NewService(source HistorySource, window int) *Service {
+	if window == 0 {
+		window = DefaultWindow
+	}
+	return &Service{source: source, window: window}
+}
+
+//Window returns the number of daily returns correlated
+func (svc *Service) Window() int {
+	return svc.window
+}
+
+//ForStocks returns the correlation matrix of stocks. Duplicate symbols are only used once
+func (svc *Service) ForStocks(stocks []*asset.Stock) *Matrix {
+	seen := make(map[string]bool, len(stocks))
+	unique := make([]*asset.Stock, 0, len(stocks))
+	for _, s := range stocks {
+		if s == nil || seen[s.Symbol()] {
+			continue
+		}
+		seen[s.Symbol()] = true
+
+		if len(s.HistoricalPrice()) == 0 {
+			if err := svc.source.RetrievePriceHistory(s.Symbol(), s); err != nil {
+				logError.Printf("Unable to retrieve price history for %s: %s\n", s.Symbol(), err)
+			}
+		}
+		unique = append(unique, s)
+	}
+
+	logInfo.Printf("Correlating %d symbols over %d days\n", len(unique), svc.window)
+	return Compute(unique, svc.window)
+}
+
+//ForWatchlist returns the correlation matrix of the symbols in wl
+func (svc *Service) ForWatchlist(wl *watchlists.WatchlistType) *Matrix {
+	var stocks []*asset.Stock
+	for _, ws := range wl.WatchedSymbols() {
+		stocks = append(stocks, ws.Stock())
+	}
+	return svc.ForStocks(stocks)
+}
+
+//ForPortfolio returns the correlation matrix of the underlyings of the stock and option positions in p
+func (svc *Service) ForPortfolio(p *portfolio.Portfolio) *Matrix {
+	return svc.ForStocks(underlyings(p, false))
+}
+
+//ShortPremiumPairs returns the pairs of underlyings in p that both carry short options and whose correlation is
+//at least threshold. These positions are effectively one bet
+func (svc *Service) ShortPremiumPairs(p *portfolio.Portfolio, threshold float64) []Pair {
+	return svc.ForStocks(underlyings(p, true)).Pairs(threshold)
+}
+
+//underlyings returns the underlying stock of each stock and option position, only short options if shortOnly
+func underlyings(p *portfolio.Portfolio, shortOnly bool) []*asset.Stock {
+	var stocks []*asset.Stock
+	if !shortOnly {
+		for _, pos := range p.Position(asset.EquityType) {
+			stocks = append(stocks, pos.UnderlyingStock())
+		}
+	}
+	for _, pos := range p.Position(asset.OptionType) {
+		if shortOnly && !pos.IsShort() {
+			continue
+		}
+		stocks = append(stocks, pos.UnderlyingStock())
+	}
+	return stocks
+}
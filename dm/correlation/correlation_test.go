@@ -0,0 +1,115 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package correlation
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/lib/financial"
+)
+
+var start = time.Date(2016, 1, 4, 16, 0, 0, 0, time.UTC)
+
+// stockFromReturns builds a stock whose closes follow returns, skipping the days in missing
+func stockFromReturns(symbol string, returns []float64, missing map[int]bool) *asset.Stock {
+	s := asset.NewStock(symbol)
+	c := 100.0
+	history := []asset.PriceHistoryType{asset.NewPriceHistoryPoint(financial.Money{Value: new(big.Rat).SetFloat64(c)}, start)}
+	for i, r := range returns {
+		c *= math.Exp(r)
+		if missing[i+1] {
+			continue
+		}
+		history = append(history, asset.NewPriceHistoryPoint(financial.Money{Value: new(big.Rat).SetFloat64(c)}, start.AddDate(0, 0, i+1)))
+	}
+	s.SetHistoricalPrice(&history)
+	return s
+}
+
+func testReturns(n int, phase float64) []float64 {
+	r := make([]float64, n)
+	for i := range r {
+		r[i] = 0.01 * math.Sin(float64(i)*0.7+phase)
+	}
+	return r
+}
+
+func TestStockCorrelation(t *testing.T) {
+	returns := testReturns(30, 0)
+	a := stockFromReturns("AAA", returns, nil)
+
+	// the same path with a missing bar still lines up on dates
+	b := stockFromReturns("BBB", returns, map[int]bool{10: true})
+	c, err := a.Correlation(b, 20)
+	if err != nil {
+		t.Fatalf("Error correlating: %v", err)
+	}
+	if math.Abs(c-1) > 1e-9 {
+		t.Errorf("identical paths got %.6f, want 1", c)
+	}
+
+	inverse := make([]float64, len(returns))
+	for i, r := range returns {
+		inverse[i] = -r
+	}
+	if c, _ := a.Correlation(stockFromReturns("CCC", inverse, nil), 20); math.Abs(c+1) > 1e-9 {
+		t.Errorf("inverse paths got %.6f, want -1", c)
+	}
+
+	if _, err := a.Correlation(b, 30); err != asset.ErrNotEnoughHistory {
+		t.Errorf("b is missing a day so 30 returns can't line up, got %v", err)
+	}
+
+	points, err := a.RollingCorrelation(b, 10)
+	if err != nil || len(points) != 20 {
+		t.Errorf("got %d rolling points, %v, want 20", len(points), err)
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	stocks := []*asset.Stock{
+		stockFromReturns("AAA", testReturns(30, 0), nil),
+		stockFromReturns("BBB", testReturns(30, 0.1), nil),
+		stockFromReturns("CCC", testReturns(30, 2), nil),
+		stockFromReturns("DDD", testReturns(3, 0), nil),
+	}
+
+	m := Compute(stocks, 20)
+
+	if c, ok := m.Correlation("BBB", "AAA"); !ok || c < 0.9 {
+		t.Errorf("AAA/BBB got %.4f %v, want > 0.9", c, ok)
+	}
+	if _, ok := m.Correlation("AAA", "DDD"); ok {
+		t.Errorf("DDD doesn't have enough history to correlate")
+	}
+
+	pairs := m.Pairs(0.9)
+	if len(pairs) != 1 || pairs[0].Symbol1 != "AAA" || pairs[0].Symbol2 != "BBB" {
+		t.Errorf("got pairs %v, want AAA/BBB", pairs)
+	}
+
+	correlated := m.Correlated("BBB", 0.9)
+	if len(correlated) != 1 || correlated[0].Symbol2 != "AAA" {
+		t.Errorf("got %v correlated with BBB, want AAA", correlated)
+	}
+}
@@ -0,0 +1,239 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package exposure reports a portfolio's greeks grouped by underlying and by expiration, with the greeks of every
+//option computed from a pricing model rather than taken from the broker
+package exposure
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/dm/pricing"
+	"github.com/marklaczynski/acidbath/lib/mjlog"
+)
+
+var (
+	logInfo  = log.New(mjlog.CreateInfoFile(), "INFO  [exposure]: ", log.LstdFlags|log.Lshortfile)
+	logDebug = log.New(mjlog.CreateDebugFile(), "DEBUG [exposure]: ", log.LstdFlags|log.Lshortfile)
+	logError = log.New(mjlog.CreateErrorFile(), "ERROR [exposure]: ", log.LstdFlags|log.Lshortfile)
+)
+
+//StockBucket is the expiration bucket stock positions are reported in
+const StockBucket = "stock"
+
+//Config holds the market inputs and the expiration buckets
+type Config struct {
+	Rate    float64            // risk free rate
+	Model   pricing.Model      // Black-Scholes-Merton if nil
+	Spots   map[string]float64 // underlying prices by symbol, the stock's last trade if missing
+	Buckets []int              // upper bounds, in days to expiration, of the expiration buckets. The last is open ended
+	Now     time.Time          // time.Now() if zero
+}
+
+//DefaultConfig returns buckets for the front week, the front month, 2 months, 3 months and beyond
+func DefaultConfig(rate float64) Config {
+	return Config{Rate: rate, Buckets: []int{7, 30, 60, 90}}
+}
+
+//Exposure is the greeks of a group of positions, in shares and dollars.
+//Delta and Gamma are in shares, DeltaDollars is the dollar change for a 1 dollar move per share (delta * spot) and
+//GammaDollars is the change in DeltaDollars for a 1% move. Theta is per day, Vega per vol point and Rho per 1% of
+//rate, all in dollars. Vanna and Charm are in shares of delta per vol point and per day, Volga in dollars of vega
+//per vol point
+type Exposure struct {
+	Name         string
+	Positions    int
+	Delta        float64
+	DeltaDollars float64
+	Gamma        float64
+	GammaDollars float64
+	Theta        float64
+	Vega         float64
+	Rho          float64
+	Vanna        float64
+	Charm        float64
+	Volga        float64
+}
+
+func (e *Exposure) add(other Exposure) {
+	e.Positions += other.Positions
+	e.Delta += other.Delta
+	e.DeltaDollars += other.DeltaDollars
+	e.Gamma += other.Gamma
+	e.GammaDollars += other.GammaDollars
+	e.Theta += other.Theta
+	e.Vega += other.Vega
+	e.Rho += other.Rho
+	e.Vanna += other.Vanna
+	e.Charm += other.Charm
+	e.Volga += other.Volga
+}
+
+func (e Exposure) String() string {
+	return fmt.Sprintf("%-8s pos: %d delta: %.1f ($%.0f) gamma: %.2f ($%.0f) theta: %.2f vega: %.2f rho: %.2f vanna: %.2f charm: %.2f volga: %.2f",
+		e.Name, e.Positions, e.Delta, e.DeltaDollars, e.Gamma, e.GammaDollars, e.Theta, e.Vega, e.Rho, e.Vanna, e.Charm, e.Volga)
+}
+
+//Report is a portfolio's exposure grouped by underlying, sorted by symbol, and by expiration bucket, in bucket order
+type Report struct {
+	Total        Exposure
+	ByUnderlying []Exposure
+	ByExpiration []Exposure
+	Skipped      []string // positions that couldn't be priced
+}
+
+func (r *Report) String() string {
+	str := r.Total.String() + "\n"
+	for _, e := range r.ByUnderlying {
+		str += e.String() + "\n"
+	}
+	for _, e := range r.ByExpiration {
+		str += e.String() + "\n"
+	}
+	return str
+}
+
+//Underlying returns the exposure to symbol
+func (r *Report) Underlying(symbol string) (Exposure, bool) {
+	for _, e := range r.ByUnderlying {
+		if e.Name == symbol {
+			return e, true
+		}
+	}
+	return Exposure{}, false
+}
+
+//Build computes the exposure of every stock and option position of p
+func Build(p *portfolio.Portfolio, cfg Config) (*Report, error) {
+	if cfg.Now.IsZero() {
+		cfg.Now = time.Now()
+	}
+
+	r := &Report{Total: Exposure{Name: "total"}}
+	underlyings := make(map[string]*Exposure)
+	buckets := make(map[string]*Exposure)
+
+	positions := append(append([]*portfolio.PositionType{}, p.Position(asset.EquityType)...), p.Position(asset.OptionType)...)
+	for _, pos := range positions {
+		e, underlying, bucket, err := positionExposure(pos, cfg)
+		if err != nil {
+			logError.Printf("Skipping %s: %s\n", pos.Symbol(), err)
+			r.Skipped = append(r.Skipped, pos.Symbol())
+			continue
+		}
+
+		if underlyings[underlying] == nil {
+			underlyings[underlying] = &Exposure{Name: underlying}
+		}
+		if buckets[bucket] == nil {
+			buckets[bucket] = &Exposure{Name: bucket}
+		}
+		underlyings[underlying].add(e)
+		buckets[bucket].add(e)
+		r.Total.add(e)
+	}
+
+	for _, e := range underlyings {
+		r.ByUnderlying = append(r.ByUnderlying, *e)
+	}
+	sort.Slice(r.ByUnderlying, func(i, j int) bool { return r.ByUnderlying[i].Name < r.ByUnderlying[j].Name })
+
+	for _, label := range bucketLabels(cfg.Buckets) {
+		if e, ok := buckets[label]; ok {
+			r.ByExpiration = append(r.ByExpiration, *e)
+		}
+	}
+
+	logInfo.Printf("%s\n", r.Total)
+	return r, nil
+}
+
+//bucketLabels returns the label of every bucket in order, stock first
+func bucketLabels(bounds []int) []string {
+	labels := []string{StockBucket}
+	lower := 0
+	for _, upper := range bounds {
+		labels = append(labels, fmt.Sprintf("%d-%dd", lower, upper))
+		lower = upper + 1
+	}
+	return append(labels, fmt.Sprintf("%dd+", lower))
+}
+
+//bucket returns the label of the bucket an option expiring in days falls in
+func bucket(bounds []int, days int) string {
+	labels := bucketLabels(bounds)
+	for i, upper := range bounds {
+		if days <= upper {
+			return labels[i+1]
+		}
+	}
+	return labels[len(labels)-1]
+}
+
+func positionExposure(pos *portfolio.PositionType, cfg Config) (e Exposure, underlying string, bucketLabel string, err error) {
+	var fallback float64
+	if pos.AssetType() == asset.EquityType {
+		fallback = pos.Mark()
+	}
+
+	p, err := pricing.NewPosition(pos, cfg.Spots, fallback, pricing.Market{Rate: cfg.Rate, Model: cfg.Model}, pricing.MidPrice, cfg.Now)
+	if err != nil {
+		return e, p.Underlying, "", err
+	}
+	underlying, spot := p.Underlying, p.Spot
+
+	e.Positions = 1
+	size := p.Quantity * p.Multiplier
+
+	if p.Option == nil {
+		e.Delta = size
+		e.DeltaDollars = size * spot
+		return e, underlying, StockBucket, nil
+	}
+
+	o, market := p.Option, p.Market
+	in := pricing.NewInputs(o, market, cfg.Now)
+	g, err := pricing.Price(o, market, cfg.Now)
+	if err != nil {
+		return e, underlying, "", err
+	}
+	so, err := pricing.SecondOrderGreeks(cfg.Model, in)
+	if err != nil {
+		return e, underlying, "", err
+	}
+
+	e.Delta = g.Delta * size
+	e.DeltaDollars = e.Delta * spot
+	e.Gamma = g.Gamma * size
+	e.GammaDollars = e.Gamma * spot * spot / 100
+	e.Theta = g.Theta * size
+	e.Vega = g.Vega * size
+	e.Rho = g.Rho * size
+	e.Vanna = so.Vanna * size
+	e.Charm = so.Charm * size
+	e.Volga = so.Volga * size
+
+	days := int(o.ExpirationDate().Sub(cfg.Now).Hours() / 24)
+	logDebug.Printf("%s %d days %+v %+v\n", pos.Symbol(), days, g, so)
+	return e, underlying, bucket(cfg.Buckets, days), nil
+}
@@ -0,0 +1,107 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package exposure
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/dm/pricing"
+)
+
+var now = time.Date(2016, 6, 1, 10, 0, 0, 0, time.UTC)
+
+func optionPosition(t *testing.T, underlying string, strike float64, days int, typ option.TypeOfOption, quantity float64) *portfolio.PositionType {
+	o, err := option.NewOption(underlying, strike, now.AddDate(0, 0, days), typ, 100)
+	if err != nil {
+		t.Fatalf("Error creating option: %v", err)
+	}
+	o.SetImpliedVolatility(0.30)
+
+	pos := portfolio.NewPosition()
+	pos.SetSymbol(underlying + typ.String())
+	pos.SetUnderlyingSymbol(underlying)
+	pos.SetAssetType(asset.OptionType)
+	pos.SetQuantity(math.Abs(quantity))
+	if quantity < 0 {
+		pos.SetPositionType(portfolio.ShortPosition)
+	}
+	pos.SetUnderlyingStock(asset.NewStock(underlying))
+	pos.SetUnderlyingOption(o)
+	return pos
+}
+
+func TestBuild(t *testing.T) {
+	p := portfolio.NewPortfolio()
+
+	stock := portfolio.NewPosition()
+	stock.SetSymbol("AAA")
+	stock.SetAssetType(asset.EquityType)
+	stock.SetQuantity(100)
+	p.AddPosition(asset.EquityType, stock)
+
+	p.AddPosition(asset.OptionType, optionPosition(t, "AAA", 95, 20, option.PUT, -2))
+	p.AddPosition(asset.OptionType, optionPosition(t, "BBB", 50, 45, option.CALL, -1))
+	p.AddPosition(asset.OptionType, optionPosition(t, "CCC", 50, 45, option.CALL, 1))
+
+	cfg := DefaultConfig(0.01)
+	cfg.Spots = map[string]float64{"AAA": 100, "BBB": 50}
+	cfg.Now = now
+
+	r, err := Build(p, cfg)
+	if err != nil {
+		t.Fatalf("Error building report: %v", err)
+	}
+	if len(r.Skipped) != 1 || r.Skipped[0] != "CCCCALL" {
+		t.Errorf("CCC has no price and should be skipped, got %v", r.Skipped)
+	}
+
+	aaa, ok := r.Underlying("AAA")
+	if !ok || aaa.Positions != 2 {
+		t.Fatalf("got %+v", aaa)
+	}
+
+	in := pricing.Inputs{Type: option.PUT, Spot: 100, Strike: 95, Rate: 0.01, Volatility: 0.30, Time: pricing.TimeToExpiry(p.Position(asset.OptionType)[0].UnderlyingOption().ExpirationDate(), now)}
+	g, _ := pricing.BlackScholes(in)
+	if want := 100 - 200*g.Delta; math.Abs(aaa.Delta-want) > 1e-6 {
+		t.Errorf("AAA delta got %.4f, want %.4f", aaa.Delta, want)
+	}
+	if aaa.Theta <= 0 || aaa.Gamma >= 0 || aaa.Vega >= 0 {
+		t.Errorf("short puts collect theta and are short gamma and vega, got %s", aaa)
+	}
+	if math.Abs(aaa.DeltaDollars-aaa.Delta*100) > 1e-6 {
+		t.Errorf("delta dollars got %.2f, want %.2f", aaa.DeltaDollars, aaa.Delta*100)
+	}
+
+	if len(r.ByExpiration) != 3 || r.ByExpiration[0].Name != StockBucket || r.ByExpiration[1].Name != "8-30d" || r.ByExpiration[2].Name != "31-60d" {
+		t.Errorf("got buckets %v", r.ByExpiration)
+	}
+
+	var theta float64
+	for _, e := range r.ByUnderlying {
+		theta += e.Theta
+	}
+	if math.Abs(theta-r.Total.Theta) > 1e-9 || r.Total.Positions != 3 {
+		t.Errorf("underlyings should add up to the total, got %.4f vs %s", theta, r.Total)
+	}
+}
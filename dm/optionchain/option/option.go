@@ -23,7 +23,6 @@ package option
 import (
 	"errors"
 	"fmt"
-	"math/big"
 	"time"
 
 	"github.com/marklaczynski/acidbath/lib/date"
@@ -39,22 +38,23 @@ type Option struct {
 	strike           float64   // Comp Key
 	expirationDate   time.Time // Comp Key
 	multiplier       float64
-	last             financial.Money
-	bid              financial.Money
-	ask              financial.Money
+	last             financial.Price // fixed point, the stream updates these on every tick
+	bid              financial.Price
+	ask              financial.Price
 	delta            float64
 	gamma            float64
 	theta            float64
 	vega             float64
+	rho              float64
+	volume           int64
+	openInterest     int32
+	bidSize          int32
+	askSize          int32
 	underlying       string // SPX, SPY, etc. Comp Key
 	daysToExpiration int64
 
-	//rho            float64
-	//volume         int64
-	//impliedVolatility float64 // volatility in TD
-	//bidSize          int32
-	//askSize          int32
-	//openInterest      int32
+	impliedVolatility float64 // annualized decimal (ie 0.25), from the broker or pricing.ImpliedVolatility
+
 	//contract          string //unused
 	//high              financial.Money //unused
 	//low               financial.Money //unused
@@ -78,13 +78,38 @@ type Option struct {
 	// my own utility
 	theoPrice          float64
 	isOTM              bool
-	optionTickerSymbol string       // this probably maps to something else
+	optionTickerSymbol string       // broker symbol, see the symbology package for its parts
 	optionType         TypeOfOption // Comp Key
 
 	//error stuff
 	err error
 }
 
+//OptionField is a set of Option fields, used to merge a partial update such as a streamed option into an option
+type OptionField uint32
+
+//enumeration values for OptionField, which can be or'ed together
+const (
+	SymbolField   OptionField = 1 << iota // the ticker symbol
+	ContractField                         // the underlying, strike, expiration and type
+	BidField
+	AskField
+	LastField
+	VolumeField
+	OpenInterestField
+	ImpliedVolatilityField
+	BidSizeField
+	AskSizeField
+	DeltaField
+	GammaField
+	ThetaField
+	VegaField
+	RhoField
+	MultiplierField
+
+	AllOptionFields = MultiplierField<<1 - 1
+)
+
 //TypeOfOption is an enum type of option (ie CALL/PUT)
 type TypeOfOption int
 
@@ -105,9 +130,6 @@ func (t TypeOfOption) String() string {
 
 func NewNilOption() *Option {
 	o := &Option{}
-	o.bid.Value = big.NewRat(0, 1)
-	o.ask.Value = big.NewRat(0, 1)
-	o.last.Value = big.NewRat(0, 1)
 	//o.high.Value = big.NewRat(0, 1)
 	//o.low.Value = big.NewRat(0, 1)
 	//o.closePrice.Value = big.NewRat(0, 1)
@@ -131,9 +153,6 @@ func NewOption(ul string, strike float64, expDate time.Time, optType TypeOfOptio
 		return nil, err
 	}
 
-	o.bid.Value = big.NewRat(0, 1)
-	o.ask.Value = big.NewRat(0, 1)
-	o.last.Value = big.NewRat(0, 1)
 	//o.high.Value = big.NewRat(0, 1)
 	//o.low.Value = big.NewRat(0, 1)
 	//o.closePrice.Value = big.NewRat(0, 1)
@@ -159,36 +178,55 @@ func (o *Option) Error() error {
 
 //Bid returns the bid price
 func (o *Option) Bid() financial.Money {
-	return o.bid
+	return o.bid.Money()
 }
 
 //SetBid sets the bid price
 func (o *Option) SetBid(bid financial.Money) error {
-	if bid.Value.Cmp(big.NewRat(0, 1)) < 0 {
+	return o.SetBidPrice(financial.PriceFromMoney(bid))
+}
+
+//BidPrice returns the bid price without allocating
+func (o *Option) BidPrice() financial.Price {
+	return o.bid
+}
+
+//SetBidPrice sets the bid price without allocating
+func (o *Option) SetBidPrice(bid financial.Price) error {
+	if bid < 0 {
 		o.err = errors.New("Bid cannot be less than 0")
 		return o.err
 	}
-	o.bid.Value.Set(bid.Value)
+	o.bid = bid
 	return nil
 }
 
 //Ask returns the ask price
 func (o *Option) Ask() financial.Money {
-	return o.ask
+	return o.ask.Money()
 }
 
 //SetAsk sets the ask price
 func (o *Option) SetAsk(ask financial.Money) error {
-	if ask.Value.Cmp(big.NewRat(0, 1)) < 0 {
+	return o.SetAskPrice(financial.PriceFromMoney(ask))
+}
+
+//AskPrice returns the ask price without allocating
+func (o *Option) AskPrice() financial.Price {
+	return o.ask
+}
+
+//SetAskPrice sets the ask price without allocating
+func (o *Option) SetAskPrice(ask financial.Price) error {
+	if ask < 0 {
 		o.err = errors.New("Ask cannot be less than 0")
 		return o.err
 	}
 
-	o.ask.Value.Set(ask.Value)
+	o.ask = ask
 	return nil
 }
 
-/*
 //BidSize retuns the bid size
 func (o *Option) BidSize() int32 {
 	return o.bidSize
@@ -196,17 +234,15 @@ func (o *Option) BidSize() int32 {
 
 //SetBidSize sets the bid size
 func (o *Option) SetBidSize(bidSize int32) error {
-	if bidSize <= 0 {
-		o.err = errors.New("BidSize cannot be <= 0")
+	if bidSize < 0 {
+		o.err = errors.New("BidSize cannot be less than 0")
 		return o.err
 	}
 
 	o.bidSize = bidSize
 	return nil
 }
-*/
 
-/*
 //AskSize retuns the ask size
 func (o *Option) AskSize() int32 {
 	return o.askSize
@@ -214,33 +250,41 @@ func (o *Option) AskSize() int32 {
 
 //SetAskSize sets the ask size
 func (o *Option) SetAskSize(askSize int32) error {
-	if askSize <= 0 {
-		o.err = errors.New("AskSize cannot be <= 0")
+	if askSize < 0 {
+		o.err = errors.New("AskSize cannot be less than 0")
 		return o.err
 	}
 
 	o.askSize = askSize
 	return nil
 }
-*/
 
 //Last returns the last trade price
 func (o *Option) Last() financial.Money {
-	return o.last
+	return o.last.Money()
 }
 
 //SetLast sets the last trade price
 func (o *Option) SetLast(last financial.Money) error {
-	if last.Value.Cmp(big.NewRat(0, 1)) < 0 {
+	return o.SetLastPrice(financial.PriceFromMoney(last))
+}
+
+//LastPrice returns the last trade price without allocating
+func (o *Option) LastPrice() financial.Price {
+	return o.last
+}
+
+//SetLastPrice sets the last trade price without allocating
+func (o *Option) SetLastPrice(last financial.Price) error {
+	if last < 0 {
 		o.err = errors.New("Last price cannot be less than 0")
 		return o.err
 	}
 
-	o.last.Value.Set(last.Value)
+	o.last = last
 	return nil
 }
 
-/*
 //Volume returns the volume
 func (o *Option) Volume() int64 {
 	return o.volume
@@ -256,9 +300,7 @@ func (o *Option) SetVolume(volume int64) error {
 	o.volume = volume
 	return nil
 }
-*/
 
-/*
 //OpenInterest returns the open interest
 func (o *Option) OpenInterest() int32 {
 	return o.openInterest
@@ -274,7 +316,6 @@ func (o *Option) SetOpenInterest(openInterest int32) error {
 	o.openInterest = openInterest
 	return nil
 }
-*/
 
 //Delta returns the delta
 func (o *Option) Delta() float64 {
@@ -316,7 +357,6 @@ func (o *Option) SetTheta(theta float64) {
 	o.theta = theta
 }
 
-/*
 //Rho returns the rho
 func (o *Option) Rho() float64 {
 	return o.rho
@@ -326,7 +366,6 @@ func (o *Option) Rho() float64 {
 func (o *Option) SetRho(rho float64) {
 	o.rho = rho
 }
-*/
 
 //Multiplier retuns the multiplier
 func (o *Option) Multiplier() float64 {
@@ -374,13 +413,12 @@ func (o *Option) SetDescription(description string) {
 	o.description = description
 }
 
-/*
-//ImpliedVolatility returns the implied volatility
+//ImpliedVolatility returns the implied volatility as an annualized decimal (ie 0.25 for 25%)
 func (o *Option) ImpliedVolatility() float64 {
 	return o.impliedVolatility
 }
 
-//SetImpliedVolatility sets the implied volatility
+//SetImpliedVolatility sets the implied volatility as an annualized decimal (ie 0.25 for 25%)
 func (o *Option) SetImpliedVolatility(impliedVolatility float64) error {
 	if impliedVolatility < 0 {
 		o.err = errors.New("Implied Volatility cannot be less than 0")
@@ -390,7 +428,6 @@ func (o *Option) SetImpliedVolatility(impliedVolatility float64) error {
 	o.impliedVolatility = impliedVolatility
 	return nil
 }
-*/
 
 //Symbol retuns the symbol
 func (o *Option) Symbol() string {
@@ -493,6 +530,62 @@ func (o *Option) SetOptionType(optionType TypeOfOption) {
 	o.optionType = optionType
 }
 
+//Merge copies the fields of update that are in fields onto o, leaving the rest of o as it is
+func (o *Option) Merge(update *Option, fields OptionField) {
+	if fields&SymbolField != 0 {
+		o.optionTickerSymbol = update.optionTickerSymbol
+	}
+	if fields&ContractField != 0 {
+		o.symbol = update.symbol
+		o.underlying = update.underlying
+		o.strike = update.strike
+		o.expirationDate = update.expirationDate
+		o.optionType = update.optionType
+	}
+	if fields&MultiplierField != 0 {
+		o.multiplier = update.multiplier
+	}
+	if fields&BidField != 0 {
+		o.bid = update.bid
+	}
+	if fields&AskField != 0 {
+		o.ask = update.ask
+	}
+	if fields&LastField != 0 {
+		o.last = update.last
+	}
+	if fields&VolumeField != 0 {
+		o.volume = update.volume
+	}
+	if fields&OpenInterestField != 0 {
+		o.openInterest = update.openInterest
+	}
+	if fields&ImpliedVolatilityField != 0 {
+		o.impliedVolatility = update.impliedVolatility
+	}
+	if fields&BidSizeField != 0 {
+		o.bidSize = update.bidSize
+	}
+	if fields&AskSizeField != 0 {
+		o.askSize = update.askSize
+	}
+	if fields&DeltaField != 0 {
+		o.delta = update.delta
+	}
+	if fields&GammaField != 0 {
+		o.gamma = update.gamma
+	}
+	if fields&ThetaField != 0 {
+		o.theta = update.theta
+	}
+	if fields&VegaField != 0 {
+		o.vega = update.vega
+	}
+	if fields&RhoField != 0 {
+		o.rho = update.rho
+	}
+}
+
 //Copy returns a new copy/clone of the option
 func (o *Option) Copy() *Option {
 	dst := &Option{}
@@ -21,6 +21,9 @@ package optionchain
 import (
 	"testing"
 	"time"
+
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/lib/date"
 )
 
 func TestNewOptionChain(t *testing.T) {
@@ -31,7 +34,7 @@ func TestNewOptionChain(t *testing.T) {
 }
 
 func todayDate(t *testing.T) time.Time {
-	d, err := date.NewDate(time.Now())
+	d, err := date.New(time.Now())
 	if err != nil {
 		t.Errorf("Error setting up test")
 		t.FailNow()
@@ -67,12 +70,12 @@ func TestAddOptions(t *testing.T) {
 	ul := "XYZ"
 	oc := NewOptionChain(ul)
 
-	var strike float32
+	var strike float64
 	strike = 1.0
 	exp := todayDate(t)
-	oType := asset.CALL
+	oType := option.CALL
 
-	o, err := asset.NewOption(ul, strike, exp, oType)
+	o, err := option.NewOption(ul, strike, exp, oType, 100)
 	if err != nil {
 		t.Errorf("Error setting up test")
 		t.FailNow()
@@ -0,0 +1,184 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package optionchain
+
+import (
+	"math"
+
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+)
+
+//Moneyness selects in or out of the money options
+type Moneyness int
+
+//enumeration values for Moneyness
+const (
+	AnyMoneyness Moneyness = iota
+	OTM
+	ITM
+)
+
+func (m Moneyness) String() string {
+	switch m {
+	case AnyMoneyness:
+		return "AnyMoneyness"
+	case OTM:
+		return "OTM"
+	case ITM:
+		return "ITM"
+	}
+	return ""
+}
+
+//Filter selects options from a chain. Zero values don't filter.
+//Deltas are absolute, so 0.16 selects both 16 delta calls and -16 delta puts. Moneyness and strike distance are
+//measured from Underlying, and when it isn't set moneyness falls back to the option's OTM flag
+type Filter struct {
+	Types             []option.TypeOfOption // calls and puts when empty
+	MinDTE            int64                 // inclusive
+	MaxDTE            int64                 // inclusive, no maximum when 0
+	MinDelta          float64
+	MaxDelta          float64 // no maximum when 0
+	Moneyness         Moneyness
+	Underlying        float64 // underlying price
+	MaxStrikeDistance float64 // distance of the strike from Underlying as a fraction of it, ie 0.10 for 10%
+	MaxSpread         float64 // ask - bid, in dollars
+	MaxSpreadPercent  float64 // ask - bid as a fraction of the mid, ie 0.10 for 10%
+	MinOpenInterest   int32
+	MinVolume         int64
+}
+
+//Match returns true if o passes every filter. dte is the days to expiration of o's expiration
+func (f Filter) Match(o *option.Option, dte int64) bool {
+	if o == nil {
+		return false
+	}
+
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			found = found || t == o.OptionType()
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if dte < f.MinDTE || (f.MaxDTE > 0 && dte > f.MaxDTE) {
+		return false
+	}
+
+	delta := math.Abs(o.Delta())
+	if delta < f.MinDelta || (f.MaxDelta > 0 && delta > f.MaxDelta) {
+		return false
+	}
+
+	if f.Moneyness != AnyMoneyness && isOTM(o, f.Underlying) != (f.Moneyness == OTM) {
+		return false
+	}
+
+	if f.MaxStrikeDistance > 0 && f.Underlying > 0 && math.Abs(o.Strike()-f.Underlying)/f.Underlying > f.MaxStrikeDistance {
+		return false
+	}
+
+	if f.MaxSpread > 0 || f.MaxSpreadPercent > 0 {
+		bid, _ := o.Bid().Value.Float64()
+		ask, _ := o.Ask().Value.Float64()
+		if ask <= 0 || ask < bid {
+			return false
+		}
+		spread := ask - bid
+		if f.MaxSpread > 0 && spread > f.MaxSpread+1e-9 {
+			return false
+		}
+		if f.MaxSpreadPercent > 0 && spread/((ask+bid)/2) > f.MaxSpreadPercent {
+			return false
+		}
+	}
+
+	return o.OpenInterest() >= f.MinOpenInterest && o.Volume() >= f.MinVolume
+}
+
+//isOTM returns true if o is out of the money with the underlying at price, or the option's flag without a price
+func isOTM(o *option.Option, price float64) bool {
+	if price <= 0 {
+		return o.IsOTM()
+	}
+	if o.OptionType() == option.CALL {
+		return o.Strike() > price
+	}
+	return o.Strike() < price
+}
+
+//Select returns the options that match f, ordered by expiration, then strike, calls before puts
+func (oc *OptionChain) Select(f Filter) []*option.Option {
+	var options []*option.Option
+	for _, od := range oc.SortedExpirations() {
+		for _, strike := range od.SortedStrikes() {
+			for _, t := range []option.TypeOfOption{option.CALL, option.PUT} {
+				if o := strike.Option(t); f.Match(o, od.DaysToExpiration()) {
+					options = append(options, o)
+				}
+			}
+		}
+	}
+
+	logDebug.Printf("Selected %d options from %s with %+v\n", len(options), oc.Underlying(), f)
+	return options
+}
+
+//NearestDelta returns the option of optType whose absolute delta is nearest delta, nil if there is none
+func NearestDelta(options []*option.Option, optType option.TypeOfOption, delta float64) *option.Option {
+	return nearest(options, optType, func(o *option.Option) float64 {
+		return math.Abs(math.Abs(o.Delta()) - math.Abs(delta))
+	})
+}
+
+//NearestStrike returns the option of optType struck nearest price, nil if there is none
+func NearestStrike(options []*option.Option, optType option.TypeOfOption, price float64) *option.Option {
+	return nearest(options, optType, func(o *option.Option) float64 {
+		return math.Abs(o.Strike() - price)
+	})
+}
+
+//nearest returns the option of optType with the smallest distance, the first one on a tie
+func nearest(options []*option.Option, optType option.TypeOfOption, distance func(*option.Option) float64) *option.Option {
+	var best *option.Option
+	bestDistance := math.Inf(1)
+	for _, o := range options {
+		if o.OptionType() != optType {
+			continue
+		}
+		if d := distance(o); d < bestDistance {
+			best, bestDistance = o, d
+		}
+	}
+	return best
+}
+
+//NearestDelta returns the option of optType matching f whose absolute delta is nearest delta, like the 16 delta put
+//in 30 to 45 days
+func (oc *OptionChain) NearestDelta(f Filter, optType option.TypeOfOption, delta float64) *option.Option {
+	return NearestDelta(oc.Select(f), optType, delta)
+}
+
+//NearestStrike returns the option of optType matching f struck nearest price
+func (oc *OptionChain) NearestStrike(f Filter, optType option.TypeOfOption, price float64) *option.Option {
+	return NearestStrike(oc.Select(f), optType, price)
+}
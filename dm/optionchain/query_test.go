@@ -0,0 +1,167 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package optionchain
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/lib/financial"
+)
+
+// testOption returns an XYZ option expiring in dte days with open interest of 100 and volume of 10
+func testOption(t *testing.T, dte int64, strike float64, optType option.TypeOfOption, delta float64, bid float64, ask float64) *option.Option {
+	o, err := option.NewOption("XYZ", strike, time.Now().AddDate(0, 0, int(dte)), optType, 100)
+	if err != nil {
+		t.Fatalf("Error setting up test: %s", err)
+	}
+	o.SetOptionTickerSymbol(fmt.Sprintf("XYZ_%d%s%.0f", dte, optType, strike))
+	o.SetDaysToExpiration(dte)
+	o.SetDelta(delta)
+	o.SetBid(financial.NewMoney(bid))
+	o.SetAsk(financial.NewMoney(ask))
+	o.SetOpenInterest(100)
+	o.SetVolume(10)
+	return o
+}
+
+func TestFilterMatch(t *testing.T) {
+	call := testOption(t, 30, 105, option.CALL, 0.25, 1.00, 1.10)
+	put := testOption(t, 30, 95, option.PUT, -0.16, 1.00, 1.10)
+	flaggedOTM := testOption(t, 30, 105, option.CALL, 0.25, 1.00, 1.10)
+	flaggedOTM.SetIsOTM(true)
+	noAsk := testOption(t, 30, 105, option.CALL, 0.25, 0, 0)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		o      *option.Option
+		dte    int64
+		want   bool
+	}{
+		{"no filter", Filter{}, call, 30, true},
+		{"nil option", Filter{}, nil, 30, false},
+		{"type", Filter{Types: []option.TypeOfOption{option.PUT}}, call, 30, false},
+
+		{"below min dte", Filter{MinDTE: 30, MaxDTE: 45}, call, 29, false},
+		{"at min dte", Filter{MinDTE: 30, MaxDTE: 45}, call, 30, true},
+		{"at max dte", Filter{MinDTE: 30, MaxDTE: 45}, call, 45, true},
+		{"above max dte", Filter{MinDTE: 30, MaxDTE: 45}, call, 46, false},
+		{"no max dte", Filter{MinDTE: 30}, call, 400, true},
+
+		{"put delta in range", Filter{MinDelta: 0.10, MaxDelta: 0.20}, put, 30, true},
+		{"call delta above max", Filter{MinDelta: 0.10, MaxDelta: 0.20}, call, 30, false},
+		{"delta at min", Filter{MinDelta: 0.16, MaxDelta: 0.20}, put, 30, true},
+		{"delta below min", Filter{MinDelta: 0.20}, put, 30, false},
+		{"no max delta", Filter{MinDelta: 0.20}, call, 30, true},
+
+		{"otm call", Filter{Moneyness: OTM, Underlying: 100}, call, 30, true},
+		{"otm call is not itm", Filter{Moneyness: ITM, Underlying: 100}, call, 30, false},
+		{"itm call", Filter{Moneyness: ITM, Underlying: 110}, call, 30, true},
+		{"otm put", Filter{Moneyness: OTM, Underlying: 100}, put, 30, true},
+		{"itm put", Filter{Moneyness: ITM, Underlying: 90}, put, 30, true},
+		{"otm flag without underlying", Filter{Moneyness: OTM}, flaggedOTM, 30, true},
+		{"itm by flag without underlying", Filter{Moneyness: OTM}, call, 30, false},
+
+		{"strike within distance", Filter{Underlying: 100, MaxStrikeDistance: 0.05}, call, 30, true},
+		{"strike beyond distance", Filter{Underlying: 100, MaxStrikeDistance: 0.04}, call, 30, false},
+		{"distance needs underlying", Filter{MaxStrikeDistance: 0.04}, call, 30, true},
+
+		{"spread at limit", Filter{MaxSpread: 0.10}, call, 30, true},
+		{"spread over limit", Filter{MaxSpread: 0.05}, call, 30, false},
+		{"spread needs an ask", Filter{MaxSpread: 1}, noAsk, 30, false},
+		// 0.10 wide on a 1.05 mid is 9.5%
+		{"spread percent at limit", Filter{MaxSpreadPercent: 0.10}, call, 30, true},
+		{"spread percent over limit", Filter{MaxSpreadPercent: 0.09}, call, 30, false},
+		{"both spread limits", Filter{MaxSpread: 0.10, MaxSpreadPercent: 0.09}, call, 30, false},
+
+		{"open interest", Filter{MinOpenInterest: 100, MinVolume: 10}, call, 30, true},
+		{"open interest too low", Filter{MinOpenInterest: 101}, call, 30, false},
+		{"volume too low", Filter{MinVolume: 11}, call, 30, false},
+	}
+
+	for _, test := range tests {
+		if got := test.filter.Match(test.o, test.dte); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	oc := NewOptionChain("XYZ")
+	for _, o := range []*option.Option{
+		testOption(t, 45, 95, option.PUT, -0.20, 1.50, 1.60),
+		testOption(t, 30, 95, option.PUT, -0.16, 1.00, 1.10),
+		testOption(t, 30, 90, option.PUT, -0.08, 0.50, 0.55),
+		testOption(t, 30, 105, option.CALL, 0.25, 1.00, 1.10),
+		testOption(t, 7, 95, option.PUT, -0.10, 0.20, 0.25),
+	} {
+		if err := oc.AddOption(o); err != nil {
+			t.Fatalf("Error setting up test: %s", err)
+		}
+	}
+
+	got := oc.Select(Filter{Types: []option.TypeOfOption{option.PUT}, MinDTE: 30, MaxDTE: 45})
+	want := []string{"XYZ_30PUT90", "XYZ_30PUT95", "XYZ_45PUT95"}
+	if len(got) != len(want) {
+		t.Fatalf("selected %v, want %v", got, want)
+	}
+	for i, o := range got {
+		if o.OptionTickerSymbol() != want[i] {
+			t.Errorf("selected %d got %s, want %s", i, o.OptionTickerSymbol(), want[i])
+		}
+	}
+
+	if o := oc.NearestDelta(Filter{MinDTE: 30, MaxDTE: 45}, option.PUT, 0.16); o == nil || o.OptionTickerSymbol() != "XYZ_30PUT95" {
+		t.Errorf("16 delta put got %v", o)
+	}
+	if o := oc.NearestStrike(Filter{MaxDTE: 30}, option.CALL, 100); o == nil || o.OptionTickerSymbol() != "XYZ_30CALL105" {
+		t.Errorf("call nearest 100 got %v", o)
+	}
+	if o := oc.NearestDelta(Filter{MinDTE: 60}, option.PUT, 0.16); o != nil {
+		t.Errorf("nothing matches, got %v", o)
+	}
+}
+
+func TestNearest(t *testing.T) {
+	low := testOption(t, 30, 95, option.PUT, -0.25, 1.00, 1.10)
+	high := testOption(t, 30, 105, option.PUT, -0.75, 5.00, 5.20)
+	call := testOption(t, 30, 100, option.CALL, 0.50, 2.00, 2.10)
+	options := []*option.Option{low, high, call}
+
+	// equal distances go to the first option
+	if o := NearestDelta(options, option.PUT, 0.50); o != low {
+		t.Errorf("delta tie got %v, want %v", o, low)
+	}
+	if o := NearestDelta([]*option.Option{high, low}, option.PUT, -0.50); o != high {
+		t.Errorf("delta tie got %v, want %v", o, high)
+	}
+	if o := NearestStrike(options, option.PUT, 100); o != low {
+		t.Errorf("strike tie got %v, want %v", o, low)
+	}
+
+	if o := NearestStrike(options, option.CALL, 95); o != call {
+		t.Errorf("only call got %v", o)
+	}
+	if o := NearestDelta(options[:2], option.CALL, 0.50); o != nil {
+		t.Errorf("no calls got %v", o)
+	}
+}
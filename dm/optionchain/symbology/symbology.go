@@ -0,0 +1,280 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package symbology parses and formats option symbols.
+//TD symbols are the root, an underscore, the expiration as MMDDYY, C or P and the strike as a plain decimal,
+//like "SPY_061518P100" or "SPY_061518P100.5". OCC symbols are 21 characters: the root padded to 6 with spaces,
+//the expiration as YYMMDD, C or P and the strike times 1000 padded to 8 digits, like "SPY   180615P00100000".
+//Roots adjusted for a corporate action end in a digit, like "SPY1"
+package symbology
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/lib/date"
+)
+
+//errors returned by the parsers
+var (
+	ErrInvalidSymbol     = errors.New("not an option symbol")
+	ErrInvalidExpiration = errors.New("option symbol has an invalid expiration")
+	ErrInvalidType       = errors.New("option symbol has no C or P")
+	ErrInvalidStrike     = errors.New("option symbol has an invalid strike")
+)
+
+//Format is the layout of an option symbol
+type Format int
+
+//enumeration values for Format
+const (
+	TD Format = iota
+	OCC
+)
+
+func (f Format) String() string {
+	switch f {
+	case TD:
+		return "TD"
+	case OCC:
+		return "OCC"
+	}
+	return ""
+}
+
+const (
+	occLength     = 21
+	occRootLength = 6
+	tdDateFormat  = "010206"
+	occDateFormat = "060102"
+)
+
+//Symbol is the parts of an option symbol
+type Symbol struct {
+	Root       string    // an adjusted root keeps its digit
+	Expiration time.Time // midnight New York, as option.Option stores it
+	Type       option.TypeOfOption
+	Strike     float64
+}
+
+func (s Symbol) String() string {
+	return s.TD()
+}
+
+//Adjusted returns true if the root has been adjusted for a corporate action, so the deliverable isn't 100 shares
+//of the underlying
+func (s Symbol) Adjusted() bool {
+	return len(s.Root) > 1 && s.Root[len(s.Root)-1] >= '0' && s.Root[len(s.Root)-1] <= '9'
+}
+
+//Multiplier returns the standard multiplier of 100, or 0 for an adjusted root, whose deliverable can't be known
+//from the symbol
+func (s Symbol) Multiplier() float64 {
+	if s.Adjusted() {
+		return 0
+	}
+	return 100
+}
+
+//Underlying returns the root without the digit of an adjusted root
+func (s Symbol) Underlying() string {
+	if s.Adjusted() {
+		return strings.TrimRight(s.Root, "0123456789")
+	}
+	return s.Root
+}
+
+//TD returns the symbol in TD format
+func (s Symbol) TD() string {
+	return fmt.Sprintf("%s_%s%s%s", s.Root, s.expiration(tdDateFormat), typeLetter(s.Type), strconv.FormatFloat(s.Strike, 'f', -1, 64))
+}
+
+//OCC returns the symbol in OCC format
+func (s Symbol) OCC() string {
+	return fmt.Sprintf("%-*s%s%s%08d", occRootLength, s.Root, s.expiration(occDateFormat), typeLetter(s.Type), int64(math.Floor(s.Strike*1000+0.5)))
+}
+
+//Format returns the symbol in f
+func (s Symbol) Format(f Format) string {
+	if f == OCC {
+		return s.OCC()
+	}
+	return s.TD()
+}
+
+//expiration formats the expiration date in New York, whatever the local time zone is
+func (s Symbol) expiration(layout string) string {
+	if loc, err := date.Location(); err == nil {
+		return s.Expiration.In(loc).Format(layout)
+	}
+	return s.Expiration.Format(layout)
+}
+
+func typeLetter(t option.TypeOfOption) string {
+	if t == option.PUT {
+		return "P"
+	}
+	return "C"
+}
+
+func parseType(letter byte) (option.TypeOfOption, error) {
+	switch letter {
+	case 'C', 'c':
+		return option.CALL, nil
+	case 'P', 'p':
+		return option.PUT, nil
+	}
+	return option.CALL, ErrInvalidType
+}
+
+//parseExpiration returns the expiration at midnight New York, the same as option.Option stores it
+func parseExpiration(layout string, value string) (time.Time, error) {
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, ErrInvalidExpiration
+	}
+	return date.New(t)
+}
+
+//ParseTD parses a TD option symbol
+func ParseTD(symbol string) (Symbol, error) {
+	idx := strings.LastIndex(symbol, "_")
+	if idx < 1 || len(symbol)-idx-1 < len(tdDateFormat)+2 {
+		return Symbol{}, ErrInvalidSymbol
+	}
+
+	s := Symbol{Root: symbol[:idx]}
+	rest := symbol[idx+1:]
+
+	var err error
+	if s.Expiration, err = parseExpiration(tdDateFormat, rest[:len(tdDateFormat)]); err != nil {
+		return Symbol{}, err
+	}
+	if s.Type, err = parseType(rest[len(tdDateFormat)]); err != nil {
+		return Symbol{}, err
+	}
+
+	s.Strike, err = strconv.ParseFloat(rest[len(tdDateFormat)+1:], 64)
+	if err != nil || s.Strike <= 0 {
+		return Symbol{}, ErrInvalidStrike
+	}
+	return s, nil
+}
+
+//ParseOCC parses an OCC option symbol. The root may be padded to 6 characters or not
+func ParseOCC(symbol string) (Symbol, error) {
+	const tail = len(occDateFormat) + 1 + 8
+	if len(symbol) <= tail || len(symbol) > occLength {
+		return Symbol{}, ErrInvalidSymbol
+	}
+
+	split := len(symbol) - tail
+	s := Symbol{Root: strings.TrimRight(symbol[:split], " ")}
+	if s.Root == "" || strings.Contains(s.Root, " ") {
+		return Symbol{}, ErrInvalidSymbol
+	}
+	rest := symbol[split:]
+
+	var err error
+	if s.Expiration, err = parseExpiration(occDateFormat, rest[:len(occDateFormat)]); err != nil {
+		return Symbol{}, err
+	}
+	if s.Type, err = parseType(rest[len(occDateFormat)]); err != nil {
+		return Symbol{}, err
+	}
+
+	thousandths, err := strconv.ParseUint(rest[len(occDateFormat)+1:], 10, 64)
+	if err != nil || thousandths == 0 {
+		return Symbol{}, ErrInvalidStrike
+	}
+	s.Strike = float64(thousandths) / 1000
+	return s, nil
+}
+
+//Parse parses a symbol in either format
+func Parse(symbol string) (Symbol, Format, error) {
+	if strings.Contains(symbol, "_") {
+		s, err := ParseTD(symbol)
+		return s, TD, err
+	}
+	s, err := ParseOCC(symbol)
+	return s, OCC, err
+}
+
+//TDToOCC converts a TD symbol to OCC
+func TDToOCC(symbol string) (string, error) {
+	s, err := ParseTD(symbol)
+	if err != nil {
+		return "", err
+	}
+	return s.OCC(), nil
+}
+
+//OCCToTD converts an OCC symbol to TD
+func OCCToTD(symbol string) (string, error) {
+	s, err := ParseOCC(symbol)
+	if err != nil {
+		return "", err
+	}
+	return s.TD(), nil
+}
+
+//Fill sets the underlying, type, strike and expiration of o from its ticker symbol, which can be in either format.
+//The multiplier is set to 100 when it isn't set already and the root isn't adjusted
+func Fill(o *option.Option) error {
+	s, _, err := Parse(o.OptionTickerSymbol())
+	if err != nil {
+		return err
+	}
+	return s.Fill(o)
+}
+
+//Fill sets the underlying, type, strike and expiration of o from s, so a symbol parsed once can fill every
+//option quoted with it. The multiplier is set to 100 when it isn't set already and the root isn't adjusted
+func (s Symbol) Fill(o *option.Option) error {
+	o.SetUnderlying(s.Underlying())
+	o.SetOptionType(s.Type)
+	if o.Multiplier() == 0 {
+		o.SetMultiplier(s.Multiplier())
+	}
+	if err := o.SetStrike(s.Strike); err != nil {
+		return err
+	}
+	return o.SetExpirationDate(s.Expiration)
+}
+
+//NewOption returns an option built from a symbol alone, in either format, with symbol as its ticker.
+//The multiplier of an adjusted root is left at 0 for the caller to set from the option's deliverable
+func NewOption(symbol string) (*option.Option, error) {
+	s, _, err := Parse(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := option.NewOption(s.Underlying(), s.Strike, s.Expiration, s.Type, s.Multiplier())
+	if err != nil {
+		return nil, err
+	}
+	o.SetOptionTickerSymbol(symbol)
+	return o, nil
+}
@@ -0,0 +1,103 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package symbology
+
+import (
+	"testing"
+
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		td         string
+		occ        string
+		underlying string
+		typ        option.TypeOfOption
+		strike     float64
+		adjusted   bool
+	}{
+		{"SPY_061518P100", "SPY   180615P00100000", "SPY", option.PUT, 100, false},
+		{"F_011917C12.5", "F     170119C00012500", "F", option.CALL, 12.5, false},
+		{"BRKB_122016C137.25", "BRKB  161220C00137250", "BRKB", option.CALL, 137.25, false},
+		{"XYZ1_031716P7.5", "XYZ1  160317P00007500", "XYZ", option.PUT, 7.5, true},
+	}
+
+	for _, v := range tests {
+		s, err := ParseTD(v.td)
+		if err != nil {
+			t.Errorf("Error parsing %s: %v", v.td, err)
+			continue
+		}
+		if s.Underlying() != v.underlying || s.Type != v.typ || s.Strike != v.strike || s.Adjusted() != v.adjusted {
+			t.Errorf("%s parsed to %+v", v.td, s)
+		}
+
+		if occ, _ := TDToOCC(v.td); occ != v.occ {
+			t.Errorf("%s to OCC got %q, want %q", v.td, occ, v.occ)
+		}
+		if td, _ := OCCToTD(v.occ); td != v.td {
+			t.Errorf("%s to TD got %q, want %q", v.occ, td, v.td)
+		}
+	}
+
+	// unpadded OCC roots parse too
+	if s, f, err := Parse("SPY180615P00100000"); err != nil || f != OCC || s.TD() != "SPY_061518P100" {
+		t.Errorf("unpadded OCC got %v %s %v", s, f, err)
+	}
+
+	for _, bad := range []string{"SPY", "SPY_061518X100", "SPY_133118P100", "SPY_061518P", "SPY   180615P0010000X", "      180615P00100000"} {
+		if _, _, err := Parse(bad); err == nil {
+			t.Errorf("%q should not parse", bad)
+		}
+	}
+}
+
+func TestNewOption(t *testing.T) {
+	o, err := NewOption("SPY_061518P100.5")
+	if err != nil {
+		t.Fatalf("Error building option: %v", err)
+	}
+	if o.Underlying() != "SPY" || o.Strike() != 100.5 || o.OptionType() != option.PUT || o.OptionTickerSymbol() != "SPY_061518P100.5" {
+		t.Errorf("got %s %s %.2f %s", o.Underlying(), o.OptionType(), o.Strike(), o.OptionTickerSymbol())
+	}
+	if y, m, d := o.ExpirationDate().Date(); y != 2018 || m != 6 || d != 15 {
+		t.Errorf("expiration got %s", o.ExpirationDate())
+	}
+
+	streamed := option.NewNilOption()
+	streamed.SetOptionTickerSymbol("QQQ   160617C00105000")
+	if err := Fill(streamed); err != nil || streamed.Underlying() != "QQQ" || streamed.Strike() != 105 || streamed.Multiplier() != 100 {
+		t.Errorf("filled got %s %.2f x%.0f %v", streamed.Underlying(), streamed.Strike(), streamed.Multiplier(), err)
+	}
+
+	// an adjusted root's deliverable isn't known from the symbol
+	adjusted, err := NewOption("SPY1_061518C100")
+	if err != nil {
+		t.Fatalf("Error building adjusted option: %v", err)
+	}
+	if adjusted.Underlying() != "SPY" || adjusted.Multiplier() != 0 {
+		t.Errorf("adjusted got %s x%.0f", adjusted.Underlying(), adjusted.Multiplier())
+	}
+	streamed = option.NewNilOption()
+	streamed.SetOptionTickerSymbol("SPY1  180615C00100000")
+	if err := Fill(streamed); err != nil || streamed.Multiplier() != 0 {
+		t.Errorf("filled adjusted got x%.0f %v", streamed.Multiplier(), err)
+	}
+}
@@ -19,7 +19,10 @@
 package order
 
 import (
+	"fmt"
 	"math/big"
+	"sync/atomic"
+	"time"
 
 	"github.com/marklaczynski/acidbath/lib/financial"
 	"github.com/marklaczynski/acidbath/lib/orderconst"
@@ -45,10 +48,20 @@ type Order struct {
 
 }
 
-//New returns a pointer to a new Order. Default routing is "Auto"
+// clientOrderSeq makes client order ids unique within the process, the timestamp makes them unique across restarts
+var clientOrderSeq uint64
+
+//NewClientOrderID returns a new unique client order id. It is only known locally, it isn't sent to the broker
+func NewClientOrderID() string {
+	return fmt.Sprintf("AB%d-%d", time.Now().UnixNano(), atomic.AddUint64(&clientOrderSeq, 1))
+}
+
+//New returns a pointer to a new Order with a unique client order id. Default routing is "Auto"
 func New() *Order {
 	o := &Order{}
 
+	o.clientOrderID = NewClientOrderID()
+
 	o.routing = orderconst.Auto
 	o.activatePrice.Value = big.NewRat(0, 1)
 	o.price.Value = big.NewRat(0, 1)
@@ -62,13 +75,13 @@ func (o *Order) Copy() *Order {
 		clientOrderID:       o.clientOrderID,
 		orderID:             o.orderID,
 		action:              o.action,
-		activatePrice:       o.activatePrice,
+		activatePrice:       o.activatePrice.Copy(),
 		expire:              o.expire,
 		exDay:               o.exDay,
 		exMonth:             o.exMonth,
 		exYear:              o.exYear,
 		orderType:           o.orderType,
-		price:               o.price,
+		price:               o.price.Copy(),
 		quantity:            o.quantity,
 		routing:             o.routing,
 		specialInstructions: o.specialInstructions,
@@ -18,18 +18,73 @@
 
 package ordermessage
 
-import "github.com/marklaczynski/acidbath/lib/orderconst"
+import (
+	"math/big"
+	"time"
 
+	"github.com/marklaczynski/acidbath/lib/financial"
+	"github.com/marklaczynski/acidbath/lib/orderconst"
+)
+
+//Message represents an order event received from the brokerage, along with the execution details that came with it
 type Message struct {
-	orderID    string
-	orderEvent orderconst.OrderEvent
+	orderID     string
+	orderEvent  orderconst.OrderEvent
+	symbol      string
+	underlying  string
+	instruction orderconst.OrderInstruction
+
+	originalQuantity  float64
+	fillQuantity      float64 // quantity filled by this execution only
+	leavesQuantity    float64 // quantity still working after this event
+	cancelledQuantity float64
+	limitPrice        financial.Money
+	fillPrice         financial.Money // price of this execution only
+	executionID       string
+
+	enteredTime   time.Time
+	executionTime time.Time
+	activityTime  time.Time
+
+	rejectCode   int
+	rejectReason string // for OrderError events this holds the error text sent by the broker
+
+	charges []Charge
+}
+
+//Charge is a single fee or commission the brokerage applied to an order
+type Charge struct {
+	chargeType string
+	amount     financial.Money
+}
+
+//NewCharge returns a Charge of chargeType for amount
+func NewCharge(chargeType string, amount financial.Money) Charge {
+	c := Charge{chargeType: chargeType}
+	c.amount.Value = new(big.Rat).Set(amount.Value)
+	return c
+}
+
+//Type returns the brokerage's description of the charge (ie Commission, SEC Fee, etc)
+func (c Charge) Type() string {
+	return c.chargeType
+}
+
+//Amount returns the amount charged
+func (c Charge) Amount() financial.Money {
+	return c.amount
 }
 
 func New(orderid string, orderevent orderconst.OrderEvent) *Message {
-	return &Message{
+	m := &Message{
 		orderID:    orderid,
 		orderEvent: orderevent,
 	}
+
+	m.limitPrice.Value = big.NewRat(0, 1)
+	m.fillPrice.Value = big.NewRat(0, 1)
+
+	return m
 }
 
 //OrderEvent returns the last event to occur for this order
@@ -52,9 +107,196 @@ func (m *Message) OrderID() string {
 	return m.orderID
 }
 
+//Symbol returns the symbol of the security being traded
+func (m *Message) Symbol() string {
+	return m.symbol
+}
+
+//SetSymbol sets the symbol of the security being traded
+func (m *Message) SetSymbol(symbol string) {
+	m.symbol = symbol
+}
+
+//Underlying returns the underlying symbol of the security being traded
+func (m *Message) Underlying() string {
+	return m.underlying
+}
+
+//SetUnderlying sets the underlying symbol of the security being traded
+func (m *Message) SetUnderlying(underlying string) {
+	m.underlying = underlying
+}
+
+//Instruction returns the side of the order (buy/sell)
+func (m *Message) Instruction() orderconst.OrderInstruction {
+	return m.instruction
+}
+
+//SetInstruction sets the side of the order (buy/sell)
+func (m *Message) SetInstruction(instruction orderconst.OrderInstruction) {
+	m.instruction = instruction
+}
+
+//OriginalQuantity returns the quantity the order was entered with
+func (m *Message) OriginalQuantity() float64 {
+	return m.originalQuantity
+}
+
+//SetOriginalQuantity sets the quantity the order was entered with
+func (m *Message) SetOriginalQuantity(quantity float64) {
+	m.originalQuantity = quantity
+}
+
+//FillQuantity returns the quantity filled by this execution
+func (m *Message) FillQuantity() float64 {
+	return m.fillQuantity
+}
+
+//SetFillQuantity sets the quantity filled by this execution
+func (m *Message) SetFillQuantity(quantity float64) {
+	m.fillQuantity = quantity
+}
+
+//LeavesQuantity returns the quantity still working after this event
+func (m *Message) LeavesQuantity() float64 {
+	return m.leavesQuantity
+}
+
+//SetLeavesQuantity sets the quantity still working after this event
+func (m *Message) SetLeavesQuantity(quantity float64) {
+	m.leavesQuantity = quantity
+}
+
+//CancelledQuantity returns the quantity that has been cancelled
+func (m *Message) CancelledQuantity() float64 {
+	return m.cancelledQuantity
+}
+
+//SetCancelledQuantity sets the quantity that has been cancelled
+func (m *Message) SetCancelledQuantity(quantity float64) {
+	m.cancelledQuantity = quantity
+}
+
+//LimitPrice returns the limit price of the order
+func (m *Message) LimitPrice() financial.Money {
+	return m.limitPrice
+}
+
+//SetLimitPrice sets the limit price of the order
+func (m *Message) SetLimitPrice(price financial.Money) {
+	m.limitPrice.Value.Set(price.Value)
+}
+
+//FillPrice returns the price of this execution
+func (m *Message) FillPrice() financial.Money {
+	return m.fillPrice
+}
+
+//SetFillPrice sets the price of this execution
+func (m *Message) SetFillPrice(price financial.Money) {
+	m.fillPrice.Value.Set(price.Value)
+}
+
+//ExecutionID returns the brokerage's id of this execution
+func (m *Message) ExecutionID() string {
+	return m.executionID
+}
+
+//SetExecutionID sets the brokerage's id of this execution
+func (m *Message) SetExecutionID(id string) {
+	m.executionID = id
+}
+
+//EnteredTime returns the time the order was entered
+func (m *Message) EnteredTime() time.Time {
+	return m.enteredTime
+}
+
+//SetEnteredTime sets the time the order was entered
+func (m *Message) SetEnteredTime(t time.Time) {
+	m.enteredTime = t
+}
+
+//ExecutionTime returns the time of this execution. Zero if the event is not an execution
+func (m *Message) ExecutionTime() time.Time {
+	return m.executionTime
+}
+
+//SetExecutionTime sets the time of this execution
+func (m *Message) SetExecutionTime(t time.Time) {
+	m.executionTime = t
+}
+
+//ActivityTime returns the time the brokerage recorded this event
+func (m *Message) ActivityTime() time.Time {
+	return m.activityTime
+}
+
+//SetActivityTime sets the time the brokerage recorded this event
+func (m *Message) SetActivityTime(t time.Time) {
+	m.activityTime = t
+}
+
+//RejectCode returns the brokerage's reject code
+func (m *Message) RejectCode() int {
+	return m.rejectCode
+}
+
+//SetRejectCode sets the brokerage's reject code
+func (m *Message) SetRejectCode(code int) {
+	m.rejectCode = code
+}
+
+//RejectReason returns the reason the order was rejected, or the error text for OrderError events
+func (m *Message) RejectReason() string {
+	return m.rejectReason
+}
+
+//SetRejectReason sets the reason the order was rejected
+func (m *Message) SetRejectReason(reason string) {
+	m.rejectReason = reason
+}
+
+//Charges returns the commissions and fees applied to the order
+func (m *Message) Charges() []Charge {
+	return m.charges
+}
+
+//AddCharge adds a commission or fee applied to the order
+func (m *Message) AddCharge(c Charge) {
+	m.charges = append(m.charges, c)
+}
+
+//TotalCharges returns the sum of all commissions and fees applied to the order
+func (m *Message) TotalCharges() financial.Money {
+	total := financial.Money{Value: big.NewRat(0, 1)}
+	for _, c := range m.charges {
+		total.Value.Add(total.Value, c.amount.Value)
+	}
+	return total
+}
+
+//IsExecution returns true if the event filled some quantity of the order
+func (m *Message) IsExecution() bool {
+	switch m.orderEvent {
+	case orderconst.OrderFill, orderconst.OrderPartialFill, orderconst.OrderManualExecution:
+		return true
+	}
+	return false
+}
+
+//Copy returns a deep copy of the message
 func (m *Message) Copy() *Message {
-	return &Message{
-		orderID:    m.orderID,
-		orderEvent: m.orderEvent,
+	dst := &Message{}
+	*dst = *m
+
+	dst.limitPrice.Value = new(big.Rat).Set(m.limitPrice.Value)
+	dst.fillPrice.Value = new(big.Rat).Set(m.fillPrice.Value)
+
+	dst.charges = nil
+	for _, c := range m.charges {
+		dst.charges = append(dst.charges, NewCharge(c.chargeType, c.amount))
 	}
+
+	return dst
 }
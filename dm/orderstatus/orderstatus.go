@@ -86,7 +86,7 @@ func (o *OrderStatus) Copy() *OrderStatus {
 		//exMonth:             o.exMonth,
 		//exYear:              o.exYear,
 		orderType: o.orderType,
-		price:     o.price,
+		price:     o.price.Copy(),
 		quantity:  o.quantity,
 		routing:   o.routing,
 		//specialInstructions: o.specialInstructions,
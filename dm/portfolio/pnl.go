@@ -0,0 +1,306 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/ordermessage"
+	"github.com/marklaczynski/acidbath/lib/financial"
+	"github.com/marklaczynski/acidbath/lib/orderconst"
+)
+
+//MarkSource selects which price a position is marked at
+type MarkSource int
+
+//enumeration values for MarkSource
+const (
+	MarkMid  MarkSource = iota // mid of the bid and ask, the last trade when one side isn't quoted
+	MarkLast                   // last trade, the mid when there hasn't been a trade
+)
+
+func (ms MarkSource) String() string {
+	switch ms {
+	case MarkMid:
+		return "MarkMid"
+	case MarkLast:
+		return "MarkLast"
+	}
+	return ""
+}
+
+//MarkPrice returns the mark for a quote from source, 0 if nothing is quoted. It takes fixed-point prices so
+//streamed ticks can be marked without allocating
+func MarkPrice(source MarkSource, bid financial.Price, ask financial.Price, last financial.Price) float64 {
+	var mid financial.Price
+	if bid > 0 && ask > 0 {
+		mid = financial.MidPrice(bid, ask)
+	}
+
+	switch source {
+	case MarkLast:
+		if last > 0 {
+			return last.Float64()
+		}
+		return mid.Float64()
+	default:
+		if mid > 0 {
+			return mid.Float64()
+		}
+		return last.Float64()
+	}
+}
+
+//PnL is profit and loss in dollars
+type PnL struct {
+	Unrealized float64 // open quantity marked against the average price
+	Realized   float64 // closed quantity against the average price, net of the charges on the fills
+	Day        float64 // change in value since the previous close, including today's fills and charges
+}
+
+//Add adds other to pnl
+func (pnl *PnL) Add(other PnL) {
+	pnl.Unrealized += other.Unrealized
+	pnl.Realized += other.Realized
+	pnl.Day += other.Day
+}
+
+func (pnl PnL) String() string {
+	return fmt.Sprintf("unrealized: %.2f realized: %.2f day: %.2f", pnl.Unrealized, pnl.Realized, pnl.Day)
+}
+
+//Multiplier returns the number of shares one unit of the position controls
+func (p *PositionType) Multiplier() float64 {
+	if p.assetType == asset.OptionType {
+		if p.underlyingOption != nil && p.underlyingOption.Multiplier() != 0 {
+			return p.underlyingOption.Multiplier()
+		}
+		return 100
+	}
+	return 1
+}
+
+//Mark returns the price the position was last marked at, or its close price if it hasn't been marked
+func (p *PositionType) Mark() float64 {
+	if p.mark > 0 {
+		return p.mark
+	}
+	c, _ := p.closePrice.Value.Float64()
+	return c
+}
+
+//SetMark sets the price the position is marked at
+func (p *PositionType) SetMark(mark float64) {
+	p.mark = mark
+}
+
+//RealizedPnL returns the P&L of the quantity closed by fills this session, net of charges
+func (p *PositionType) RealizedPnL() float64 {
+	return p.realizedPnL
+}
+
+//overnightQuantity returns the signed quantity held at the previous close
+func (p *PositionType) overnightQuantity() float64 {
+	if p.filledToday {
+		return p.overnight
+	}
+	return p.SignedQuantity()
+}
+
+//PnL returns the position's P&L at its mark. Prices are per share, as the broker reports the average and close price
+func (p *PositionType) PnL() PnL {
+	mult := p.Multiplier()
+	avg, _ := p.averagePrice.Value.Float64()
+	closePrice, _ := p.closePrice.Value.Float64()
+	mark := p.Mark()
+
+	return PnL{
+		Unrealized: p.SignedQuantity() * (mark - avg) * mult,
+		Realized:   p.realizedPnL,
+		Day:        p.SignedQuantity()*mark*mult + p.dayCashFlow - p.overnightQuantity()*closePrice*mult,
+	}
+}
+
+//ApplyFill updates the quantity, average price and realized P&L of the position for an execution.
+//Charges on the fill are taken out of realized P&L
+func (p *PositionType) ApplyFill(m *ordermessage.Message) {
+	if !p.filledToday {
+		p.overnight = p.SignedQuantity()
+		p.filledToday = true
+	}
+
+	fill := m.FillQuantity()
+	if m.Instruction() == orderconst.Sell {
+		fill = -fill
+	}
+	price, _ := m.FillPrice().Value.Float64()
+	charges, _ := m.TotalCharges().Value.Float64()
+	mult := p.Multiplier()
+
+	p.dayCashFlow -= fill*price*mult + charges
+	p.realizedPnL -= charges
+
+	current := p.SignedQuantity()
+	avg, _ := p.averagePrice.Value.Float64()
+
+	switch {
+	case current == 0 || (current > 0) == (fill > 0):
+		// opening or adding, the average price moves toward the fill
+		avg = (math.Abs(current)*avg + math.Abs(fill)*price) / (math.Abs(current) + math.Abs(fill))
+	default:
+		// closing, and opening the other way with whatever is left
+		closed := math.Min(math.Abs(fill), math.Abs(current))
+		if current > 0 {
+			p.realizedPnL += closed * (price - avg) * mult
+		} else {
+			p.realizedPnL += closed * (avg - price) * mult
+		}
+		if math.Abs(fill) > math.Abs(current) {
+			avg = price
+		}
+	}
+
+	p.setSignedQuantity(current + fill)
+	if p.SignedQuantity() == 0 {
+		avg = 0
+	}
+	p.averagePrice.Value = new(big.Rat).SetFloat64(avg)
+}
+
+func (p *PositionType) setSignedQuantity(q float64) {
+	p.quantity = math.Abs(q)
+	p.positionType = LongPosition
+	if q < 0 {
+		p.positionType = ShortPosition
+	}
+}
+
+//Copy returns a copy of the position that shares its underlying stock and option
+func (p *PositionType) Copy() *PositionType {
+	dst := *p
+	dst.closePrice.Value = new(big.Rat).Set(p.closePrice.Value)
+	dst.averagePrice.Value = new(big.Rat).Set(p.averagePrice.Value)
+	dst.currentValue.Value = new(big.Rat).Set(p.currentValue.Value)
+	return &dst
+}
+
+//FindPosition returns the stock or option position for symbol
+func (p *Portfolio) FindPosition(symbol string) (*PositionType, bool) {
+	for _, st := range []asset.AssetType{asset.OptionType, asset.EquityType} {
+		for _, pos := range p.Position(st) {
+			if pos.Symbol() == symbol {
+				return pos, true
+			}
+		}
+	}
+	return nil, false
+}
+
+//UpdateMark marks the position for symbol at mark. It returns false if the portfolio doesn't hold symbol
+func (p *Portfolio) UpdateMark(symbol string, mark float64) bool {
+	pos, ok := p.FindPosition(symbol)
+	if !ok || mark <= 0 {
+		return false
+	}
+	pos.SetMark(mark)
+	return true
+}
+
+//ApplyFill applies an execution to the position for its symbol, opening a new position if there isn't one
+func (p *Portfolio) ApplyFill(m *ordermessage.Message) *PositionType {
+	pos, ok := p.FindPosition(m.Symbol())
+	if !ok {
+		pos = NewPosition()
+		pos.SetSymbol(m.Symbol())
+		pos.SetUnderlyingSymbol(m.Underlying())
+
+		if m.Underlying() != "" && m.Underlying() != m.Symbol() {
+			pos.SetAssetType(asset.OptionType)
+			pos.SetUnderlyingStock(asset.NewStock(m.Underlying()))
+			pos.SetUnderlyingOption(option.NewNilOption())
+		} else {
+			pos.SetAssetType(asset.EquityType)
+			pos.SetUnderlyingStock(asset.NewStock(m.Symbol()))
+			pos.SetUnderlyingOption(option.NewNilOption())
+		}
+		p.AddPosition(pos.AssetType(), pos)
+	}
+
+	pos.ApplyFill(m)
+	return pos
+}
+
+//PnL returns the P&L of the stock and option positions
+func (p *Portfolio) PnL() PnL {
+	var total PnL
+	for _, pnl := range p.PnLByUnderlying() {
+		total.Add(pnl)
+	}
+	return total
+}
+
+//PnLByUnderlying returns the P&L of the stock and option positions rolled up by underlying symbol
+func (p *Portfolio) PnLByUnderlying() map[string]PnL {
+	byUnderlying := make(map[string]PnL)
+	for _, st := range []asset.AssetType{asset.EquityType, asset.OptionType} {
+		for _, pos := range p.Position(st) {
+			underlying := pos.UnderlyingSymbol()
+			if underlying == "" {
+				underlying = pos.Symbol()
+			}
+			pnl := byUnderlying[underlying]
+			pnl.Add(pos.PnL())
+			byUnderlying[underlying] = pnl
+		}
+	}
+	return byUnderlying
+}
+
+//CarrySessionState copies the marks and the P&L tracked this session from prev onto the matching positions in p,
+//which is freshly retrieved from the broker. Positions the broker no longer reports keep their P&L as flat positions
+func (p *Portfolio) CarrySessionState(prev *Portfolio) {
+	if prev == nil {
+		return
+	}
+
+	for _, st := range []asset.AssetType{asset.EquityType, asset.OptionType} {
+		for _, old := range prev.Position(st) {
+			if pos, ok := p.FindPosition(old.Symbol()); ok {
+				pos.mark = old.mark
+				pos.realizedPnL = old.realizedPnL
+				pos.dayCashFlow = old.dayCashFlow
+				pos.overnight = old.overnight
+				pos.filledToday = old.filledToday
+				continue
+			}
+
+			if !old.filledToday {
+				continue
+			}
+			flat := old.Copy()
+			flat.setSignedQuantity(0)
+			flat.averagePrice.Value = new(big.Rat)
+			p.AddPosition(st, flat)
+		}
+	}
+}
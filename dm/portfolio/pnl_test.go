@@ -0,0 +1,136 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package portfolio
+
+import (
+	"math"
+	"testing"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/ordermessage"
+	"github.com/marklaczynski/acidbath/lib/financial"
+	"github.com/marklaczynski/acidbath/lib/orderconst"
+)
+
+func fill(symbol string, underlying string, instruction orderconst.OrderInstruction, quantity float64, price float64, charges float64) *ordermessage.Message {
+	m := ordermessage.New("1", orderconst.OrderFill)
+	m.SetSymbol(symbol)
+	m.SetUnderlying(underlying)
+	m.SetInstruction(instruction)
+	m.SetFillQuantity(quantity)
+	m.SetFillPrice(financial.NewMoney(price))
+	if charges != 0 {
+		m.AddCharge(ordermessage.NewCharge("Commission", financial.NewMoney(charges)))
+	}
+	return m
+}
+
+func near(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestStockPnL(t *testing.T) {
+	p := NewPortfolio()
+	pos := NewPosition()
+	pos.SetSymbol("XYZ")
+	pos.SetAssetType(asset.EquityType)
+	pos.SetPositionType(LongPosition)
+	pos.SetQuantity(100)
+	pos.SetAveragePrice(financial.NewMoney(50))
+	pos.SetClosePrice(financial.NewMoney(52))
+	p.AddPosition(asset.EquityType, pos)
+
+	// unmarked positions sit at the close
+	if pnl := p.PnL(); !near(pnl.Unrealized, 200) || !near(pnl.Day, 0) {
+		t.Errorf("unmarked got %s", pnl)
+	}
+
+	p.UpdateMark("XYZ", 55)
+	if pnl := p.PnL(); !near(pnl.Unrealized, 500) || !near(pnl.Day, 300) || pnl.Realized != 0 {
+		t.Errorf("marked got %s, want unrealized 500 day 300", pnl)
+	}
+
+	p.ApplyFill(fill("XYZ", "", orderconst.Sell, 40, 56, 1))
+	pnl := p.PnL()
+	if pos.Quantity() != 60 || !near(pnl.Realized, 239) || !near(pnl.Unrealized, 300) {
+		t.Errorf("after selling 40 got %.0f shares and %s, want realized 239 unrealized 300", pos.Quantity(), pnl)
+	}
+	// 60 * 55 + 40 * 56 - 1 - 100 * 52
+	if !near(pnl.Day, 339) {
+		t.Errorf("day got %.2f, want 339", pnl.Day)
+	}
+}
+
+func TestOptionFlipAndRollup(t *testing.T) {
+	p := NewPortfolio()
+
+	// a new short put, then bought back and flipped long
+	p.ApplyFill(fill("XYZ_P95", "XYZ", orderconst.Sell, 2, 1.50, 0))
+	pos, ok := p.FindPosition("XYZ_P95")
+	if !ok || !pos.IsShort() || pos.AssetType() != asset.OptionType || pos.Multiplier() != 100 {
+		t.Fatalf("expected a new short option position, got %+v", pos)
+	}
+
+	p.UpdateMark("XYZ_P95", 1.00)
+	if pnl := pos.PnL(); !near(pnl.Unrealized, 100) || !near(pnl.Day, 100) {
+		t.Errorf("short put got %s, want 100 unrealized and day", pnl)
+	}
+
+	p.ApplyFill(fill("XYZ_P95", "XYZ", orderconst.Buy, 3, 1.00, 0))
+	if pos.IsShort() || pos.Quantity() != 1 || !near(pos.RealizedPnL(), 100) {
+		t.Errorf("after flipping got %.0f %s realized %.2f", pos.Quantity(), pos.PositionType(), pos.RealizedPnL())
+	}
+	if avg, _ := pos.AveragePrice().Value.Float64(); !near(avg, 1.00) {
+		t.Errorf("the flipped quantity opens at the fill, got %.4f", avg)
+	}
+
+	p.ApplyFill(fill("XYZ", "", orderconst.Buy, 100, 100, 0))
+	p.UpdateMark("XYZ", 101)
+
+	by := p.PnLByUnderlying()
+	if len(by) != 1 || !near(by["XYZ"].Unrealized, 100) || !near(by["XYZ"].Realized, 100) {
+		t.Errorf("rolled up got %v", by)
+	}
+
+	snapshot := p.Copy()
+	p.UpdateMark("XYZ", 90)
+	if s, _ := snapshot.FindPosition("XYZ"); s.Mark() != 101 {
+		t.Errorf("a copy shouldn't see later marks, got %.2f", s.Mark())
+	}
+}
+
+func TestMarkPrice(t *testing.T) {
+	tests := []struct {
+		source         MarkSource
+		bid, ask, last financial.Price
+		want           float64
+	}{
+		{MarkMid, 12000, 12500, 13000, 1.225},
+		{MarkMid, 0, 12500, 13000, 1.30}, // one sided quotes fall back to the last
+		{MarkMid, 0, 0, 0, 0},
+		{MarkLast, 12000, 12500, 13000, 1.30},
+		{MarkLast, 12000, 12500, 0, 1.225},
+	}
+
+	for _, test := range tests {
+		if got := MarkPrice(test.source, test.bid, test.ask, test.last); !near(got, test.want) {
+			t.Errorf("%s %s x %s last %s got %.4f, want %.4f", test.source, test.bid, test.ask, test.last, got, test.want)
+		}
+	}
+}
@@ -19,14 +19,21 @@
 package portfolio
 
 import (
+	"errors"
 	"math/big"
 
 	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/beta"
 	"github.com/marklaczynski/acidbath/dm/bnp"
 	"github.com/marklaczynski/acidbath/dm/optionchain/option"
 	"github.com/marklaczynski/acidbath/lib/financial"
 )
 
+//errors returned by the position analytics
+var (
+	ErrNoUnderlying = errors.New("option position has no underlying option or stock")
+)
+
 type Portfolio struct {
 	positions        [][]*PositionType
 	portfolioBalance *balance.Balance
@@ -58,7 +65,7 @@ func (p *Portfolio) Copy() *Portfolio {
 	for idx := 0; idx < int(asset.MaxAssetType); idx++ {
 		dst.positions[idx] = make([]*PositionType, 0, 0)
 		for _, v := range p.positions[idx] {
-			dst.positions[idx] = append(dst.positions[idx], v)
+			dst.positions[idx] = append(dst.positions[idx], v.Copy())
 		}
 	}
 
@@ -75,18 +82,22 @@ func (b *Portfolio) Position(st asset.AssetType) []*PositionType {
 	return b.positions[st]
 }
 
-func (p *Portfolio) BetaWeightedDelta(targetBetaStock *asset.Stock) float64 {
-	var tmpPortfolioBetaWeightedDelta float64 = 0.0
-
-	for _, currOptionPosition := range p.Position(asset.OptionType) {
-		tmpPortfolioBetaWeightedDelta = tmpPortfolioBetaWeightedDelta + (currOptionPosition.BetaWeightedDelta(targetBetaStock) * currOptionPosition.Quantity())
-	}
-
-	for _, currStockPosition := range p.Position(asset.EquityType) {
-		tmpPortfolioBetaWeightedDelta = tmpPortfolioBetaWeightedDelta + (currStockPosition.BetaWeightedDelta(targetBetaStock) * currStockPosition.Quantity())
+//BetaWeightedDelta returns the delta of the portfolio in shares of benchmark. Betas are cached by betas, so this
+//is cheap enough to recompute on every tick. Positions that can't be beta weighted are left out of the total and
+//their symbols are returned in skipped, so a caller can tell a partial total from a complete one
+func (p *Portfolio) BetaWeightedDelta(benchmark *asset.Stock, betas *beta.Service) (delta float64, skipped []string) {
+	for _, positions := range p.positions {
+		for _, currPosition := range positions {
+			bwd, err := currPosition.BetaWeightedDelta(benchmark, betas)
+			if err != nil {
+				skipped = append(skipped, currPosition.Symbol())
+				continue
+			}
+			delta = delta + bwd
+		}
 	}
 
-	return tmpPortfolioBetaWeightedDelta
+	return delta, skipped
 }
 
 func (p *Portfolio) Gamma() float64 {
@@ -131,6 +142,12 @@ func (p *Portfolio) Vega() float64 {
 	return tmpPortfolioVega
 }
 
+//position types reported by the broker
+const (
+	LongPosition  = "LONG"
+	ShortPosition = "SHORT"
+)
+
 /*
 if AssetType == Option
 	Stock = retrievesnapshot()
@@ -156,6 +173,13 @@ type PositionType struct {
 	//Supplamental data
 	underlyingStock  *asset.Stock
 	underlyingOption *option.Option
+
+	// P&L tracked during the session
+	mark        float64 // per share, from the stream
+	realizedPnL float64
+	dayCashFlow float64 // cash paid (negative) or received for today's fills, less charges
+	overnight   float64 // signed quantity held at the previous close, once there has been a fill
+	filledToday bool
 }
 
 func NewPosition() *PositionType {
@@ -237,6 +261,19 @@ func (p *PositionType) Quantity() float64 {
 	return p.quantity
 }
 
+//SignedQuantity returns the quantity as a negative number for short positions, and positive for long positions
+func (p *PositionType) SignedQuantity() float64 {
+	if p.IsShort() && p.quantity > 0 {
+		return -p.quantity
+	}
+	return p.quantity
+}
+
+//IsShort returns true if the broker reports the position as short
+func (p *PositionType) IsShort() bool {
+	return p.positionType == ShortPosition || p.quantity < 0
+}
+
 func (p *PositionType) SetQuantity(newQuantity float64) {
 	p.quantity = newQuantity
 }
@@ -290,27 +327,54 @@ func (p *PositionType) SetAccountType(newAccountType int64) {
 	21.9 / (adj) 6.15 = 3.56
 	pos delta / delta adjuster = spy beta weighted delta
 */
-// this is for options only
-// Assume p.UnderlyingStock != nil && p.UnderlyingStock != nil
-func (p *PositionType) BetaWeightedDelta(targetBetaStock *asset.Stock) float64 {
+//BetaWeightedDelta returns the delta of the position in shares of benchmark, signed and including the multiplier.
+//Options, stocks, funds and indexes are weighted by the beta of their underlying. Bonds and cash have no equity
+//delta and return 0
+func (p *PositionType) BetaWeightedDelta(benchmark *asset.Stock, betas *beta.Service) (float64, error) {
+	underlying := p.UnderlyingStock()
 	switch p.assetType {
 	case asset.OptionType:
-		// NOTE: FUTURE : in the future take the mid between bid ask
-		betaTargetLastTradePrice, _ := targetBetaStock.LastTradePrice().Value.Float64()
-		currentOptionPositionStockLastTradePrice, _ := p.UnderlyingStock().LastTradePrice().Value.Float64()
-		tmpTargetDailyCloseChange := targetBetaStock.DailyCloseChange()
+		if p.UnderlyingOption() == nil || underlying == nil {
+			return 0, ErrNoUnderlying
+		}
+	case asset.EquityType, asset.MutualFundType, asset.IndexType:
+		if underlying == nil {
+			underlying = asset.NewStock(p.Symbol())
+		}
+	default:
+		return 0, nil
+	}
+
+	underlyingBeta, err := betas.Beta(underlying, benchmark)
+	if err != nil {
+		return 0, err
+	}
 
-		return p.UnderlyingOption().BetaWeightedDelta(betaTargetLastTradePrice, currentOptionPositionStockLastTradePrice, p.UnderlyingStock().Beta(&tmpTargetDailyCloseChange))
-	case asset.EquityType:
-		// NOTE: FUTURE : in the future take the mid between bid ask
-		const SingleStockDelta = 1
+	benchmarkPrice := betas.Price(benchmark)
+	underlyingPrice := p.underlyingPrice(underlying, betas.PriceSource())
+	if benchmarkPrice <= 0 || underlyingPrice <= 0 {
+		return 0, beta.ErrNoPrice
+	}
 
-		betaTargetLastTradePrice, _ := targetBetaStock.LastTradePrice().Value.Float64()
-		currentStockPositionStockLastTradePrice, _ := p.UnderlyingStock().LastTradePrice().Value.Float64()
+	const SingleStockDelta = 1
+	size := p.SignedQuantity() * p.Multiplier()
+	if p.assetType == asset.OptionType {
+		return p.UnderlyingOption().BetaWeightedDelta(benchmarkPrice, underlyingPrice, underlyingBeta) * size, nil
+	}
+	return (SingleStockDelta / ((benchmarkPrice / underlyingPrice) / underlyingBeta)) * size, nil
+}
 
-		tmpTargetDailyCloseChange := targetBetaStock.DailyCloseChange()
-		return (SingleStockDelta / ((betaTargetLastTradePrice / currentStockPositionStockLastTradePrice) / p.UnderlyingStock().Beta(&tmpTargetDailyCloseChange)))
-	default:
-		panic("Should not reach here")
+//underlyingPrice returns the price of underlying from source. A stock position marked at ps is the position's mark,
+//and a position without a quote falls back to its mark
+func (p *PositionType) underlyingPrice(underlying *asset.Stock, ps beta.PriceSource) float64 {
+	if p.assetType != asset.OptionType && ps == beta.PriceMark && p.Mark() > 0 {
+		return p.Mark()
+	}
+	if price := beta.Price(underlying, ps); price > 0 {
+		return price
+	}
+	if p.assetType != asset.OptionType {
+		return p.Mark()
 	}
+	return 0
 }
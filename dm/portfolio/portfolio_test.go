@@ -0,0 +1,54 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package portfolio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/beta"
+	"github.com/marklaczynski/acidbath/lib/financial"
+)
+
+func TestBetaWeightedDeltaSkipped(t *testing.T) {
+	benchmark := asset.NewStock("SPY")
+	history := []asset.PriceHistoryType{asset.NewPriceHistoryPoint(financial.NewMoney(200), time.Now())}
+	benchmark.SetHistoricalPrice(&history)
+
+	p := NewPortfolio()
+	for _, symbol := range []string{"SPY", "XYZ"} {
+		pos := NewPosition()
+		pos.SetSymbol(symbol)
+		pos.SetAssetType(asset.EquityType)
+		pos.SetPositionType(LongPosition)
+		pos.SetQuantity(10)
+		pos.SetMark(100)
+		p.AddPosition(asset.EquityType, pos)
+	}
+
+	// XYZ has no history to compute a beta from
+	delta, skipped := p.BetaWeightedDelta(benchmark, beta.NewService(nil, beta.DefaultLookback, beta.PriceMark))
+	if !near(delta, 5) {
+		t.Errorf("10 shares at half the benchmark price got %.4f, want 5", delta)
+	}
+	if len(skipped) != 1 || skipped[0] != "XYZ" {
+		t.Errorf("expected XYZ to be skipped, got %v", skipped)
+	}
+}
@@ -0,0 +1,205 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package pricing
+
+import (
+	"errors"
+	"math"
+
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+)
+
+//Model prices an option from its inputs
+type Model interface {
+	Price(in Inputs) (Greeks, error)
+}
+
+//BlackScholesModel is the European Black-Scholes-Merton Model
+type BlackScholesModel struct{}
+
+//Price prices in with BlackScholes
+func (BlackScholesModel) Price(in Inputs) (Greeks, error) {
+	return BlackScholes(in)
+}
+
+//DefaultSteps is the number of steps used by a Binomial tree with no steps set
+const DefaultSteps = 200
+
+//ErrInvalidTree is returned when the rate and volatility give a tree with no valid risk neutral probability
+var ErrInvalidTree = errors.New("binomial tree has no valid risk neutral probability, add steps")
+
+//Binomial is a Cox-Ross-Rubinstein tree Model for American options. Discrete dividends are handled with the
+//escrowed dividend method: the tree is built on the spot less the dividends' present value, and each node adds
+//back the dividends still to be paid so early exercise is tested against the full stock price.
+//Greeks are computed by finite differences
+type Binomial struct {
+	Steps int
+}
+
+//Price prices in on the tree. Delta and gamma are differenced from the tree's nodes two steps in, which avoids
+//the odd/even oscillation of bumping the spot. The other greeks are computed by repricing with bumped inputs
+func (b Binomial) Price(in Inputs) (Greeks, error) {
+	if err := in.validate(); err != nil {
+		return Greeks{}, err
+	}
+
+	price, delta, gamma, err := b.tree(in)
+	if err != nil {
+		return Greeks{}, err
+	}
+
+	g := Greeks{Price: price, Delta: delta, Gamma: gamma}
+	if in.Time <= 0 {
+		return g, nil
+	}
+
+	// volatility moves 1 point, time 1 day and rate 1 basis point
+	if in.Volatility > 0 {
+		vu, vd := in, in
+		vu.Volatility += 0.005
+		vd.Volatility = math.Max(in.Volatility-0.005, 0)
+		pvu, err := b.value(vu)
+		if err != nil {
+			return Greeks{}, err
+		}
+		pvd, err := b.value(vd)
+		if err != nil {
+			return Greeks{}, err
+		}
+		g.Vega = (pvu - pvd) / ((vu.Volatility - vd.Volatility) * 100)
+	}
+
+	day := in.day()
+	if in.Time > day {
+		tomorrow := in
+		tomorrow.Time -= day
+		tomorrow.Dividends = shiftDividends(in.Dividends, day)
+		pt, err := b.value(tomorrow)
+		if err != nil {
+			return Greeks{}, err
+		}
+		g.Theta = pt - price
+	}
+
+	ru, rd := in, in
+	ru.Rate += 0.0001
+	rd.Rate -= 0.0001
+	pru, err := b.value(ru)
+	if err != nil {
+		return Greeks{}, err
+	}
+	prd, err := b.value(rd)
+	if err != nil {
+		return Greeks{}, err
+	}
+	g.Rho = (pru - prd) / 0.0002 / 100
+
+	return g, nil
+}
+
+//value returns the tree price of in
+func (b Binomial) value(in Inputs) (float64, error) {
+	price, _, _, err := b.tree(in)
+	return price, err
+}
+
+//tree returns the tree price of in, with the delta and gamma from the nodes at the second step
+func (b Binomial) tree(in Inputs) (price float64, delta float64, gamma float64, err error) {
+	sign := 1.0
+	if in.Type == option.PUT {
+		sign = -1.0
+	}
+
+	if in.Time <= 0 {
+		price = in.Intrinsic()
+		if price > 0 {
+			delta = sign
+		}
+		return price, delta, 0, nil
+	}
+
+	// a tree needs some volatility to branch, with none an american option is worth at least the european floor
+	if in.Volatility == 0 {
+		g, err := BlackScholes(in)
+		if g.Price < in.Intrinsic() {
+			return in.Intrinsic(), sign, 0, err
+		}
+		return g.Price, g.Delta, 0, err
+	}
+
+	steps := b.Steps
+	if steps < 2 {
+		steps = DefaultSteps
+	}
+
+	dt := in.Time / float64(steps)
+	u := math.Exp(in.Volatility * math.Sqrt(dt))
+	d := 1 / u
+	p := (math.Exp((in.Rate-in.DividendYield)*dt) - d) / (u - d)
+	if p <= 0 || p >= 1 {
+		return 0, 0, 0, ErrInvalidTree
+	}
+	disc := math.Exp(-in.Rate * dt)
+
+	escrowed := in.Spot - in.presentDividends(0)
+	if escrowed <= 0 {
+		return 0, 0, 0, ErrInvalidSpot
+	}
+
+	values := make([]float64, steps+1)
+	for j := 0; j <= steps; j++ {
+		s := escrowed * math.Pow(u, float64(2*j-steps))
+		values[j] = math.Max(sign*(s-in.Strike), 0)
+	}
+
+	var step2 [3]float64
+	var spot2 [3]float64
+	for i := steps - 1; i >= 0; i-- {
+		pending := in.presentDividends(float64(i) * dt)
+		for j := 0; j <= i; j++ {
+			s := escrowed*math.Pow(u, float64(2*j-i)) + pending
+			continuation := disc * (p*values[j+1] + (1-p)*values[j])
+			values[j] = math.Max(continuation, sign*(s-in.Strike))
+			if i == 2 {
+				spot2[j] = s
+			}
+		}
+		if i == 2 {
+			copy(step2[:], values[:3])
+		}
+	}
+
+	deltaUp := (step2[2] - step2[1]) / (spot2[2] - spot2[1])
+	deltaDown := (step2[1] - step2[0]) / (spot2[1] - spot2[0])
+	delta = (step2[2] - step2[0]) / (spot2[2] - spot2[0])
+	gamma = (deltaUp - deltaDown) / ((spot2[2] - spot2[0]) / 2)
+
+	return values[0], delta, gamma, nil
+}
+
+//shiftDividends returns dividends as seen t years later, dropping those that have gone ex
+func shiftDividends(dividends []CashDividend, t float64) []CashDividend {
+	var shifted []CashDividend
+	for _, d := range dividends {
+		if d.Time > t {
+			shifted = append(shifted, CashDividend{Time: d.Time - t, Amount: d.Amount})
+		}
+	}
+	return shifted
+}
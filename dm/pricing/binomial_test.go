@@ -0,0 +1,75 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package pricing
+
+import (
+	"testing"
+
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+)
+
+func TestBinomialMatchesEuropeanCall(t *testing.T) {
+	// without dividends an american call is never exercised early
+	in := Inputs{Type: option.CALL, Spot: 100, Strike: 100, Rate: 0.05, Volatility: 0.2, Time: 1}
+
+	bs, _ := BlackScholes(in)
+	tree, err := Binomial{Steps: 500}.Price(in)
+	if err != nil {
+		t.Fatalf("Error pricing on tree: %v", err)
+	}
+
+	if !closeTo(tree.Price, bs.Price, 0.01) {
+		t.Errorf("tree price %.4f, black-scholes %.4f", tree.Price, bs.Price)
+	}
+	if !closeTo(tree.Delta, bs.Delta, 0.01) || !closeTo(tree.Gamma, bs.Gamma, 0.002) {
+		t.Errorf("tree greeks %+v, black-scholes %+v", tree, bs)
+	}
+	if !closeTo(tree.Vega, bs.Vega, 0.01) || !closeTo(tree.Theta, bs.Theta, 0.002) || !closeTo(tree.Rho, bs.Rho, 0.01) {
+		t.Errorf("tree greeks %+v, black-scholes %+v", tree, bs)
+	}
+}
+
+func TestBinomialEarlyExercise(t *testing.T) {
+	put := Inputs{Type: option.PUT, Spot: 80, Strike: 100, Rate: 0.05, Volatility: 0.2, Time: 1}
+
+	european, _ := BlackScholes(put)
+	american, err := Binomial{}.Price(put)
+	if err != nil {
+		t.Fatalf("Error pricing put: %v", err)
+	}
+
+	// the deep itm american put is worth its intrinsic value, the european one less
+	if american.Price < 20 || european.Price >= 20 {
+		t.Errorf("american put %.4f, european put %.4f, intrinsic 20", american.Price, european.Price)
+	}
+
+	// a large dividend the day before expiry makes exercising a deep itm call worthwhile
+	call := Inputs{Type: option.CALL, Spot: 120, Strike: 100, Rate: 0.01, Volatility: 0.2, Time: 0.1,
+		Dividends: []CashDividend{{Time: 0.099, Amount: 5}}}
+
+	european, _ = BlackScholes(call)
+	american, err = Binomial{}.Price(call)
+	if err != nil {
+		t.Fatalf("Error pricing call: %v", err)
+	}
+
+	if american.Price <= european.Price+1 {
+		t.Errorf("american call %.4f should be well above european call %.4f before the dividend", american.Price, european.Price)
+	}
+}
@@ -0,0 +1,313 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package pricing computes theoretical option values and greeks
+//reference https://en.wikipedia.org/wiki/Black%E2%80%93Scholes_model
+package pricing
+
+import (
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/lib/date"
+	"github.com/marklaczynski/acidbath/lib/mjlog"
+)
+
+var (
+	logInfo  = log.New(mjlog.CreateInfoFile(), "INFO  [pricing]: ", log.LstdFlags|log.Lshortfile)
+	logDebug = log.New(mjlog.CreateDebugFile(), "DEBUG [pricing]: ", log.LstdFlags|log.Lshortfile)
+	logError = log.New(mjlog.CreateErrorFile(), "ERROR [pricing]: ", log.LstdFlags|log.Lshortfile)
+)
+
+const (
+	daysPerYear = 365.0
+	// equity options stop trading at 4pm eastern on expiration day, option expiration dates are midnight
+	expirationCutoff = 16 * time.Hour
+)
+
+//Inputs are the parameters of the option pricing models. Rate and DividendYield are continuously compounded
+//annual rates, Volatility is annualized, and Time is in years of DaysPerYear days
+type Inputs struct {
+	Type          option.TypeOfOption
+	Spot          float64
+	Strike        float64
+	Rate          float64
+	DividendYield float64
+	Volatility    float64
+	Time          float64
+	Dividends     []CashDividend // discrete dividends paid before expiry
+	DaysPerYear   float64        // days in a year of Time, 365 calendar days if 0, the trading days with trading time
+}
+
+//day returns one day of the time basis in years, the step Theta and Charm are measured over
+func (in Inputs) day() float64 {
+	if in.DaysPerYear > 0 {
+		return 1 / in.DaysPerYear
+	}
+	return 1 / daysPerYear
+}
+
+//CashDividend is a discrete dividend going ex Time years from now
+type CashDividend struct {
+	Time   float64
+	Amount float64
+}
+
+//presentDividends returns the present value at time t of the dividends going ex after t and before expiry
+func (in Inputs) presentDividends(t float64) float64 {
+	var pv float64
+	for _, d := range in.Dividends {
+		if d.Time > t && d.Time <= in.Time {
+			pv += d.Amount * math.Exp(-in.Rate*(d.Time-t))
+		}
+	}
+	return pv
+}
+
+//Greeks holds a theoretical price and its sensitivities. They use the same units the broker sends:
+//Theta is per day, Vega is per 1 point (1%) of volatility and Rho is per 1% of rate. The day is a calendar day,
+//or a trading day when the inputs are in trading time (Market.TradingTime)
+type Greeks struct {
+	Price float64
+	Delta float64
+	Gamma float64
+	Theta float64
+	Vega  float64
+	Rho   float64
+}
+
+//Market holds the market inputs needed to price an option.Option
+type Market struct {
+	Spot          float64          // underlying price
+	Rate          float64          // risk free rate, ie 0.02 for 2%
+	DividendYield float64          // continuous dividend yield, ie 0.015 for 1.5%
+	Volatility    float64          // annualized volatility, ie 0.20 for 20%
+	Dividends     []asset.Dividend // discrete dividends, used instead of (or as well as) DividendYield
+	Model         Model            // model used to price, Black-Scholes-Merton if nil
+	Calendar      *date.Calendar   // settles at the market close of expiration day when set
+	TradingTime   bool             // with a Calendar, time is measured in trading minutes so nights and weekends don't decay, and theta is per trading day
+}
+
+//NewMarket returns the market for options on stock, using its last trade price and dividend schedule
+func NewMarket(stock *asset.Stock, rate float64, volatility float64) Market {
+	spot, _ := stock.LastTradePrice().Value.Float64()
+	return Market{
+		Spot:       spot,
+		Rate:       rate,
+		Volatility: volatility,
+		Dividends:  stock.Dividends(),
+	}
+}
+
+func (m Market) model() Model {
+	if m.Model == nil {
+		return BlackScholesModel{}
+	}
+	return m.Model
+}
+
+//errors returned by the pricing functions
+var (
+	ErrInvalidSpot       = errors.New("spot price must be greater than 0")
+	ErrInvalidStrike     = errors.New("strike must be greater than 0")
+	ErrInvalidVolatility = errors.New("volatility cannot be less than 0")
+)
+
+//TimeToExpiry returns the time in years from now until the option stops trading on expiration. It is 0 once expired
+func TimeToExpiry(expiration time.Time, now time.Time) float64 {
+	t := expiration.Add(expirationCutoff).Sub(now).Hours() / 24 / daysPerYear
+	if t < 0 {
+		return 0
+	}
+	return t
+}
+
+//timeToExpiry returns the time to expiry in years from the market's calendar if it has one
+func (m Market) timeToExpiry(expiration time.Time, now time.Time) float64 {
+	switch {
+	case m.Calendar == nil:
+		return TimeToExpiry(expiration, now)
+	case m.TradingTime:
+		return m.Calendar.TradingTimeToExpiry(expiration, now)
+	default:
+		return m.Calendar.CalendarTimeToExpiry(expiration, now)
+	}
+}
+
+//NewInputs builds the model inputs for o in market m at time now. Only dividends going ex before expiry are kept
+func NewInputs(o *option.Option, m Market, now time.Time) Inputs {
+	in := Inputs{
+		Type:          o.OptionType(),
+		Spot:          m.Spot,
+		Strike:        o.Strike(),
+		Rate:          m.Rate,
+		DividendYield: m.DividendYield,
+		Volatility:    m.Volatility,
+		Time:          m.timeToExpiry(o.ExpirationDate(), now),
+	}
+	if m.Calendar != nil && m.TradingTime {
+		in.DaysPerYear = date.TradingDaysPerYear
+	}
+
+	for _, d := range m.Dividends {
+		// the stock trades ex at the open, so a dividend on expiration day is before the cutoff
+		t := d.ExDate().Sub(now).Hours() / 24 / daysPerYear
+		if m.Calendar != nil && m.TradingTime {
+			t = m.Calendar.TradingMinutesBetween(now, d.ExDate()) / date.TradingMinutesPerYear
+		}
+		if t > 0 && t <= in.Time {
+			in.Dividends = append(in.Dividends, CashDividend{Time: t, Amount: d.Amount()})
+		}
+	}
+
+	return in
+}
+
+func (in Inputs) validate() error {
+	if in.Spot <= 0 {
+		return ErrInvalidSpot
+	}
+	if in.Strike <= 0 {
+		return ErrInvalidStrike
+	}
+	if in.Volatility < 0 {
+		return ErrInvalidVolatility
+	}
+	return nil
+}
+
+//Intrinsic returns the value of exercising the option now
+func (in Inputs) Intrinsic() float64 {
+	if in.Type == option.CALL {
+		return math.Max(in.Spot-in.Strike, 0)
+	}
+	return math.Max(in.Strike-in.Spot, 0)
+}
+
+//BlackScholes prices a European option with the Black-Scholes-Merton model. At or after expiry, or with zero
+//volatility, it returns the (discounted) intrinsic value with a delta of 0 or +/-1.
+//Discrete dividends are handled by removing their present value from the spot price
+func BlackScholes(in Inputs) (Greeks, error) {
+	if err := in.validate(); err != nil {
+		return Greeks{}, err
+	}
+
+	if len(in.Dividends) > 0 {
+		in.Spot -= in.presentDividends(0)
+		in.Dividends = nil
+		if in.Spot <= 0 {
+			return Greeks{}, ErrInvalidSpot
+		}
+	}
+
+	sign := 1.0
+	if in.Type == option.PUT {
+		sign = -1.0
+	}
+
+	if in.Time <= 0 {
+		g := Greeks{Price: in.Intrinsic()}
+		if g.Price > 0 {
+			g.Delta = sign
+		}
+		return g, nil
+	}
+
+	dq := math.Exp(-in.DividendYield * in.Time)
+	dr := math.Exp(-in.Rate * in.Time)
+
+	if in.Volatility == 0 {
+		forward := sign * (in.Spot*dq - in.Strike*dr)
+		g := Greeks{Price: math.Max(forward, 0)}
+		if forward > 0 {
+			g.Delta = sign * dq
+			g.Rho = sign * in.Strike * in.Time * dr / 100
+		}
+		return g, nil
+	}
+
+	sqrtT := math.Sqrt(in.Time)
+	d1 := (math.Log(in.Spot/in.Strike) + (in.Rate-in.DividendYield+in.Volatility*in.Volatility/2)*in.Time) / (in.Volatility * sqrtT)
+	d2 := d1 - in.Volatility*sqrtT
+
+	nd1 := NormCDF(sign * d1)
+	nd2 := NormCDF(sign * d2)
+	pdf := normPDF(d1)
+
+	var g Greeks
+	g.Price = sign * (in.Spot*dq*nd1 - in.Strike*dr*nd2)
+	g.Delta = sign * dq * nd1
+	g.Gamma = dq * pdf / (in.Spot * in.Volatility * sqrtT)
+	g.Vega = in.Spot * dq * pdf * sqrtT / 100
+	g.Theta = (-in.Spot*dq*pdf*in.Volatility/(2*sqrtT) -
+		sign*in.Rate*in.Strike*dr*nd2 +
+		sign*in.DividendYield*in.Spot*dq*nd1) * in.day()
+	g.Rho = sign * in.Strike * in.Time * dr * nd2 / 100
+
+	// rounding can leave deep otm options a hair below 0
+	g.Price = math.Max(g.Price, 0)
+
+	return g, nil
+}
+
+//Price computes the value and greeks of o in market m at time now, using the market's model
+func Price(o *option.Option, m Market, now time.Time) (Greeks, error) {
+	return m.model().Price(NewInputs(o, m, now))
+}
+
+//SetTheoPrice prices o and stores the result in its theoretical price, leaving the broker's greeks untouched
+func SetTheoPrice(o *option.Option, m Market, now time.Time) (Greeks, error) {
+	g, err := Price(o, m, now)
+	if err != nil {
+		logError.Printf("Unable to price %s: %s\n", o.OptionTickerSymbol(), err)
+		return g, err
+	}
+
+	return g, o.SetTheoPrice(g.Price)
+}
+
+//Apply prices o and stores the theoretical price and greeks on it. Use it for options the broker
+//has not sent greeks for, or on a copy of an option to see what-if values
+func Apply(o *option.Option, m Market, now time.Time) (Greeks, error) {
+	g, err := SetTheoPrice(o, m, now)
+	if err != nil {
+		return g, err
+	}
+
+	o.SetDelta(g.Delta)
+	o.SetGamma(g.Gamma)
+	o.SetTheta(g.Theta)
+	o.SetVega(g.Vega)
+	o.SetRho(g.Rho)
+
+	logDebug.Printf("%s priced at %.4f with %+v\n", o.OptionTickerSymbol(), g.Price, g)
+	return g, nil
+}
+
+//NormCDF returns the standard normal cumulative distribution function at x
+func NormCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+func normPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
@@ -0,0 +1,129 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package pricing
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/lib/date"
+)
+
+func closeTo(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestBlackScholes(t *testing.T) {
+	call := Inputs{Type: option.CALL, Spot: 100, Strike: 100, Rate: 0.05, Volatility: 0.2, Time: 1}
+	put := call
+	put.Type = option.PUT
+
+	c, err := BlackScholes(call)
+	if err != nil {
+		t.Fatalf("Error pricing call: %v", err)
+	}
+	p, err := BlackScholes(put)
+	if err != nil {
+		t.Fatalf("Error pricing put: %v", err)
+	}
+
+	if !closeTo(c.Price, 10.4506, 0.0001) {
+		t.Errorf("call price got %.4f, want 10.4506", c.Price)
+	}
+	if !closeTo(p.Price, 5.5735, 0.0001) {
+		t.Errorf("put price got %.4f, want 5.5735", p.Price)
+	}
+	if !closeTo(c.Delta, 0.6368, 0.0001) || !closeTo(p.Delta, -0.3632, 0.0001) {
+		t.Errorf("delta got call %.4f put %.4f, want 0.6368 and -0.3632", c.Delta, p.Delta)
+	}
+	if !closeTo(c.Gamma, p.Gamma, 1e-12) || !closeTo(c.Vega, p.Vega, 1e-12) {
+		t.Errorf("call and put gamma/vega should match, got %+v and %+v", c, p)
+	}
+	if !closeTo(c.Vega, 0.3752, 0.0001) {
+		t.Errorf("vega got %.4f, want 0.3752", c.Vega)
+	}
+
+	// put-call parity with no dividends: C - P = S - K*e^-rT
+	if parity := c.Price - p.Price - (100 - 100*math.Exp(-0.05)); !closeTo(parity, 0, 1e-9) {
+		t.Errorf("put-call parity off by %g", parity)
+	}
+}
+
+func TestBlackScholesExpired(t *testing.T) {
+	g, err := BlackScholes(Inputs{Type: option.PUT, Spot: 90, Strike: 100, Volatility: 0.3})
+	if err != nil {
+		t.Fatalf("Error pricing expired put: %v", err)
+	}
+
+	if g.Price != 10 || g.Delta != -1 || g.Gamma != 0 {
+		t.Errorf("expired put got %+v, want intrinsic 10 with delta -1", g)
+	}
+
+	if _, err := BlackScholes(Inputs{Type: option.CALL, Spot: 0, Strike: 100, Time: 1}); err != ErrInvalidSpot {
+		t.Errorf("got %v, want ErrInvalidSpot", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	now := time.Date(2016, 6, 1, 10, 0, 0, 0, time.UTC)
+	o, err := option.NewOption("XYZ", 100, now.AddDate(0, 3, 0), option.CALL, 100)
+	if err != nil {
+		t.Fatalf("Error creating option: %v", err)
+	}
+	o.SetRho(99)
+
+	g, err := Apply(o, Market{Spot: 100, Rate: 0.05, Volatility: 0.2}, now)
+	if err != nil {
+		t.Fatalf("Error applying price: %v", err)
+	}
+	if o.Delta() != g.Delta || o.Gamma() != g.Gamma || o.Theta() != g.Theta || o.Vega() != g.Vega || o.Rho() != g.Rho {
+		t.Errorf("option greeks %.4f %.4f %.4f %.4f %.4f, want %+v", o.Delta(), o.Gamma(), o.Theta(), o.Vega(), o.Rho(), g)
+	}
+	if g.Rho <= 0 {
+		t.Errorf("a call's rho got %.4f, want positive", g.Rho)
+	}
+}
+
+func TestTradingTimeTheta(t *testing.T) {
+	cal, err := date.NewCalendar()
+	if err != nil {
+		t.Fatalf("Error creating calendar: %v", err)
+	}
+	now := time.Date(2016, 6, 1, 14, 0, 0, 0, time.UTC)
+	o, err := option.NewOption("XYZ", 100, now.AddDate(0, 0, 30), option.CALL, 100)
+	if err != nil {
+		t.Fatalf("Error creating option: %v", err)
+	}
+
+	in := NewInputs(o, Market{Spot: 100, Rate: 0.02, Volatility: 0.25, Calendar: cal, TradingTime: true}, now)
+	if in.DaysPerYear != date.TradingDaysPerYear {
+		t.Fatalf("trading time inputs got %.0f days per year, want %d", in.DaysPerYear, date.TradingDaysPerYear)
+	}
+
+	// theta is the decay over one trading day of the trading time basis
+	g, _ := BlackScholes(in)
+	tomorrow := in
+	tomorrow.Time -= 1.0 / date.TradingDaysPerYear
+	gt, _ := BlackScholes(tomorrow)
+	if !closeTo(g.Theta, gt.Price-g.Price, 0.001) {
+		t.Errorf("theta got %.4f, a trading day of decay is %.4f", g.Theta, gt.Price-g.Price)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package pricing
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+)
+
+//PriceSource selects which market price the implied volatility is solved from
+type PriceSource int
+
+//enumeration values for PriceSource
+const (
+	MidPrice PriceSource = iota
+	BidPrice
+	AskPrice
+	LastPrice
+)
+
+func (ps PriceSource) String() string {
+	switch ps {
+	case MidPrice:
+		return "mid"
+	case BidPrice:
+		return "bid"
+	case AskPrice:
+		return "ask"
+	case LastPrice:
+		return "last"
+	}
+	return ""
+}
+
+//errors returned by the implied volatility solver
+var (
+	ErrExpired        = errors.New("option has expired, implied volatility is undefined")
+	ErrNoPrice        = errors.New("no market price to solve implied volatility from")
+	ErrBelowIntrinsic = errors.New("price is below the option's intrinsic value")
+	ErrAboveMaximum   = errors.New("price is above the option's maximum value")
+	ErrNoConvergence  = errors.New("implied volatility did not converge")
+)
+
+const (
+	minVolatility  = 1e-6
+	maxVolatility  = 10.0 // 1000%
+	priceTolerance = 1e-8
+	maxIterations  = 100
+)
+
+//ImpliedVolatility solves for the volatility that makes the Black-Scholes-Merton price of in equal price.
+//in.Volatility is ignored. It uses Newton's method, falling back to bisection whenever a Newton step leaves the
+//bracket or vega is too small to trust
+func ImpliedVolatility(in Inputs, price float64) (float64, error) {
+	return ModelImpliedVolatility(BlackScholesModel{}, in, price)
+}
+
+//ModelImpliedVolatility solves for the volatility that makes model's price of in equal price, so an American
+//option priced on a Binomial tree is solved on the same tree. in.Volatility is ignored
+func ModelImpliedVolatility(model Model, in Inputs, price float64) (float64, error) {
+	_, european := model.(BlackScholesModel)
+
+	in.Volatility = 0
+	if err := in.validate(); err != nil {
+		return 0, err
+	}
+
+	if in.Time <= 0 {
+		return 0, ErrExpired
+	}
+
+	if price <= 0 {
+		return 0, ErrNoPrice
+	}
+
+	// with zero volatility the option is worth its discounted intrinsic value, nothing is worth less
+	// an American option is also worth at least exercising it now, and a put at most its strike
+	floor, _ := BlackScholes(in)
+	if !european {
+		floor.Price = math.Max(floor.Price, in.Intrinsic())
+	}
+	if price < floor.Price-priceTolerance {
+		return 0, ErrBelowIntrinsic
+	}
+
+	ceiling := in.Spot * math.Exp(-in.DividendYield*in.Time)
+	if in.Type == option.PUT {
+		ceiling = in.Strike * math.Exp(-in.Rate*in.Time)
+	}
+	if !european {
+		ceiling = in.Spot
+		if in.Type == option.PUT {
+			ceiling = in.Strike
+		}
+	}
+	if price >= ceiling {
+		return 0, ErrAboveMaximum
+	}
+
+	low, high := minVolatility, maxVolatility
+	in.Volatility = initialGuess(in, price)
+
+	for i := 0; i < maxIterations; i++ {
+		g, err := model.Price(in)
+		if err == ErrInvalidTree {
+			// a tree only breaks down at volatilities too low for the rate, below the root
+			low = in.Volatility
+			in.Volatility = (low + high) / 2
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		diff := g.Price - price
+		if math.Abs(diff) < priceTolerance {
+			return in.Volatility, nil
+		}
+
+		// price increases with volatility, so the root is below us when we are too high
+		if diff > 0 {
+			high = in.Volatility
+		} else {
+			low = in.Volatility
+		}
+
+		next := math.NaN()
+		if vega := g.Vega * 100; vega > 1e-10 {
+			next = in.Volatility - diff/vega
+		}
+
+		if math.IsNaN(next) || next <= low || next >= high {
+			next = (low + high) / 2
+		}
+
+		if high-low < 1e-12 {
+			return next, nil
+		}
+
+		in.Volatility = next
+	}
+
+	logError.Printf("implied volatility did not converge for price %.4f with %+v\n", price, in)
+	return 0, ErrNoConvergence
+}
+
+//initialGuess uses the Brenner-Subrahmanyam approximation, which is close for near the money options
+func initialGuess(in Inputs, price float64) float64 {
+	guess := math.Sqrt(2*math.Pi/in.Time) * price / in.Spot
+	if guess < 0.05 || guess > 3 || math.IsNaN(guess) {
+		return 0.3
+	}
+	return guess
+}
+
+//MarketPrice returns o's price from source. Mid falls back to whichever side is quoted when the other is 0
+func MarketPrice(o *option.Option, source PriceSource) float64 {
+	bid, _ := o.Bid().Value.Float64()
+	ask, _ := o.Ask().Value.Float64()
+
+	switch source {
+	case BidPrice:
+		return bid
+	case AskPrice:
+		return ask
+	case LastPrice:
+		last, _ := o.Last().Value.Float64()
+		return last
+	}
+
+	if bid <= 0 || ask <= 0 {
+		return math.Max(bid, ask)
+	}
+	return (bid + ask) / 2
+}
+
+//SolveImpliedVolatility solves o's implied volatility from its source price in market m at time now, with m's
+//model so the volatility reprices o at its market price. m.Volatility is ignored
+func SolveImpliedVolatility(o *option.Option, m Market, source PriceSource, now time.Time) (float64, error) {
+	return ModelImpliedVolatility(m.model(), NewInputs(o, m, now), MarketPrice(o, source))
+}
+
+//ApplyImpliedVolatility solves o's implied volatility and stores it on the option, replacing the broker's value
+func ApplyImpliedVolatility(o *option.Option, m Market, source PriceSource, now time.Time) (float64, error) {
+	iv, err := SolveImpliedVolatility(o, m, source, now)
+	if err != nil {
+		logDebug.Printf("Unable to solve implied volatility of %s from %s: %s\n", o.OptionTickerSymbol(), source, err)
+		return 0, err
+	}
+
+	return iv, o.SetImpliedVolatility(iv)
+}
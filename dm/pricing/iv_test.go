@@ -0,0 +1,95 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package pricing
+
+import (
+	"testing"
+
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+)
+
+func TestImpliedVolatilityRoundTrip(t *testing.T) {
+	for _, typ := range []option.TypeOfOption{option.CALL, option.PUT} {
+		for _, strike := range []float64{50, 90, 100, 110, 200} {
+			for _, vol := range []float64{0.05, 0.2, 0.8, 2.5} {
+				in := Inputs{Type: typ, Spot: 100, Strike: strike, Rate: 0.02, DividendYield: 0.01, Volatility: vol, Time: 0.25}
+				g, _ := BlackScholes(in)
+				floor, _ := BlackScholes(Inputs{Type: typ, Spot: 100, Strike: strike, Rate: 0.02, DividendYield: 0.01, Time: 0.25})
+
+				// when there is no time value left the volatility can't be recovered from the price
+				if g.Price-floor.Price < 1e-6 {
+					continue
+				}
+
+				iv, err := ImpliedVolatility(in, g.Price)
+				if err != nil {
+					t.Errorf("%s %.0f vol %.2f: %v", typ, strike, vol, err)
+					continue
+				}
+				if !closeTo(iv, vol, 1e-4) {
+					t.Errorf("%s %.0f: got %.6f, want %.6f", typ, strike, iv, vol)
+				}
+			}
+		}
+	}
+}
+
+func TestImpliedVolatilityErrors(t *testing.T) {
+	in := Inputs{Type: option.PUT, Spot: 80, Strike: 100, Time: 0.5}
+
+	if _, err := ImpliedVolatility(in, 15); err != ErrBelowIntrinsic {
+		t.Errorf("got %v, want ErrBelowIntrinsic", err)
+	}
+	if _, err := ImpliedVolatility(in, 100); err != ErrAboveMaximum {
+		t.Errorf("got %v, want ErrAboveMaximum", err)
+	}
+	if _, err := ImpliedVolatility(in, 0); err != ErrNoPrice {
+		t.Errorf("got %v, want ErrNoPrice", err)
+	}
+
+	in.Time = 0
+	if _, err := ImpliedVolatility(in, 21); err != ErrExpired {
+		t.Errorf("got %v, want ErrExpired", err)
+	}
+}
+
+func TestModelImpliedVolatility(t *testing.T) {
+	model := Binomial{}
+	for _, strike := range []float64{90, 100, 120} {
+		in := Inputs{Type: option.PUT, Spot: 100, Strike: strike, Rate: 0.05, Volatility: 0.35, Time: 0.5}
+		g, err := model.Price(in)
+		if err != nil {
+			t.Fatalf("Error pricing on the tree: %v", err)
+		}
+
+		iv, err := ModelImpliedVolatility(model, in, g.Price)
+		if err != nil {
+			t.Errorf("American put %.0f: %v", strike, err)
+			continue
+		}
+		if !closeTo(iv, 0.35, 1e-4) {
+			t.Errorf("American put %.0f: got %.6f, want 0.35", strike, iv)
+		}
+
+		// the early exercise premium would otherwise be read as extra volatility
+		if bsm, _ := ImpliedVolatility(in, g.Price); bsm <= iv {
+			t.Errorf("American put %.0f: Black-Scholes-Merton got %.6f, want more than %.6f", strike, bsm, iv)
+		}
+	}
+}
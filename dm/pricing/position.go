@@ -0,0 +1,104 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+)
+
+//errors returned by NewPosition
+var (
+	ErrNoUnderlyingPrice = errors.New("position has no underlying price")
+	ErrNoOption          = errors.New("position has no option")
+)
+
+//Position is a portfolio position resolved against the market it is priced in
+type Position struct {
+	Symbol     string
+	Underlying string         // the underlying's symbol, the position's own symbol for stock
+	Spot       float64        // the underlying's price
+	Quantity   float64        // signed, negative when short
+	Multiplier float64        // 1 for stock, the option's multiplier or 100 for options
+	Option     *option.Option // nil for stock
+	Market     Market         // the market the option is priced in, with its implied volatility
+}
+
+//PositionUnderlying returns the symbol of pos's underlying, its own symbol for stock
+func PositionUnderlying(pos *portfolio.PositionType) string {
+	if pos.AssetType() == asset.EquityType || pos.UnderlyingSymbol() == "" {
+		return pos.Symbol()
+	}
+	return pos.UnderlyingSymbol()
+}
+
+//NewPosition resolves pos's underlying, spot price and multiplier, and for options the market to price it in.
+//The spot comes from spots by underlying symbol, then the underlying stock's last trade, then fallback.
+//base supplies the rate and model; an option the broker hasn't sent an implied volatility for has it solved
+//from its source price
+func NewPosition(pos *portfolio.PositionType, spots map[string]float64, fallback float64, base Market, source PriceSource, now time.Time) (*Position, error) {
+	p := &Position{
+		Symbol:     pos.Symbol(),
+		Underlying: PositionUnderlying(pos),
+		Quantity:   pos.SignedQuantity(),
+		Multiplier: pos.Multiplier(),
+	}
+
+	spot, ok := spots[p.Underlying]
+	if !ok && pos.UnderlyingStock() != nil {
+		spot, _ = pos.UnderlyingStock().LastTradePrice().Value.Float64()
+	}
+	if spot <= 0 {
+		spot = fallback
+	}
+	if spot <= 0 {
+		return p, ErrNoUnderlyingPrice
+	}
+	p.Spot = spot
+
+	p.Market = base
+	p.Market.Spot = spot
+	if pos.AssetType() != asset.OptionType {
+		return p, nil
+	}
+
+	p.Option = pos.UnderlyingOption()
+	if p.Option == nil || p.Option.Strike() == 0 {
+		return p, ErrNoOption
+	}
+
+	if pos.UnderlyingStock() != nil {
+		p.Market.Dividends = pos.UnderlyingStock().Dividends()
+	}
+	p.Market.Volatility = p.Option.ImpliedVolatility()
+	if p.Market.Volatility <= 0 {
+		iv, err := SolveImpliedVolatility(p.Option, p.Market, source, now)
+		if err != nil {
+			return p, fmt.Errorf("no implied volatility: %s", err)
+		}
+		p.Market.Volatility = iv
+	}
+
+	return p, nil
+}
@@ -0,0 +1,75 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package pricing
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/lib/financial"
+)
+
+func TestNewPosition(t *testing.T) {
+	now := time.Date(2016, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	stock := portfolio.NewPosition()
+	stock.SetSymbol("AAA")
+	stock.SetAssetType(asset.EquityType)
+	stock.SetQuantity(100)
+
+	p, err := NewPosition(stock, nil, 0, Market{}, MidPrice, now)
+	if err != ErrNoUnderlyingPrice {
+		t.Errorf("stock without a price got %v, want %v", err, ErrNoUnderlyingPrice)
+	}
+	p, err = NewPosition(stock, nil, 42, Market{}, MidPrice, now)
+	if err != nil || p.Spot != 42 || p.Underlying != "AAA" || p.Multiplier != 1 || p.Option != nil {
+		t.Errorf("stock with a fallback price got %+v, %v", p, err)
+	}
+
+	o, err := option.NewOption("AAA", 100, now.AddDate(0, 0, 30), option.PUT, 0)
+	if err != nil {
+		t.Fatalf("Error creating option: %v", err)
+	}
+	want, _ := BlackScholes(NewInputs(o, Market{Spot: 100, Rate: 0.01, Volatility: 0.25}, now))
+	o.SetBid(financial.NewMoney(want.Price))
+	o.SetAsk(financial.NewMoney(want.Price))
+
+	put := portfolio.NewPosition()
+	put.SetSymbol("AAA_PUT100")
+	put.SetUnderlyingSymbol("AAA")
+	put.SetAssetType(asset.OptionType)
+	put.SetQuantity(2)
+	put.SetPositionType(portfolio.ShortPosition)
+	put.SetUnderlyingOption(o)
+
+	p, err = NewPosition(put, map[string]float64{"AAA": 100}, 0, Market{Rate: 0.01}, MidPrice, now)
+	if err != nil {
+		t.Fatalf("Error resolving put: %v", err)
+	}
+	if p.Spot != 100 || p.Market.Spot != 100 || p.Quantity != -2 || p.Multiplier != 100 {
+		t.Errorf("put got %+v", p)
+	}
+	if math.Abs(p.Market.Volatility-0.25) > 1e-4 {
+		t.Errorf("solved implied volatility got %.4f, want 0.25", p.Market.Volatility)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package pricing
+
+import (
+	"math"
+)
+
+//SecondOrder holds the cross and second order sensitivities of an option.
+//Vanna is the change in delta per 1 point of volatility, Charm is the change in delta per day passing
+//and Volga is the change in vega per 1 point of volatility
+type SecondOrder struct {
+	Vanna float64
+	Charm float64
+	Volga float64
+}
+
+//SecondOrderGreeks computes vanna, charm and volga of in by repricing with model, Black-Scholes-Merton if nil.
+//Volatility is bumped by 1 point either way and time by one day forward
+func SecondOrderGreeks(model Model, in Inputs) (SecondOrder, error) {
+	if model == nil {
+		model = BlackScholesModel{}
+	}
+	if err := in.validate(); err != nil {
+		return SecondOrder{}, err
+	}
+
+	var so SecondOrder
+	if in.Time <= 0 {
+		return so, nil
+	}
+
+	const bump = 0.01
+	up, down := in, in
+	up.Volatility += bump
+	down.Volatility = math.Max(in.Volatility-bump, 0)
+
+	gu, err := model.Price(up)
+	if err != nil {
+		return so, err
+	}
+	gd, err := model.Price(down)
+	if err != nil {
+		return so, err
+	}
+
+	// per point, Vega is already per point
+	points := (up.Volatility - down.Volatility) * 100
+	so.Vanna = (gu.Delta - gd.Delta) / points
+	so.Volga = (gu.Vega - gd.Vega) / points
+
+	g, err := model.Price(in)
+	if err != nil {
+		return so, err
+	}
+
+	day := in.day()
+	tomorrow := in
+	tomorrow.Time = math.Max(in.Time-day, 0)
+	tomorrow.Dividends = shiftDividends(in.Dividends, day)
+	gt, err := model.Price(tomorrow)
+	if err != nil {
+		return so, err
+	}
+	so.Charm = gt.Delta - g.Delta
+
+	return so, nil
+}
@@ -0,0 +1,60 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package pricing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+)
+
+func TestSecondOrderGreeks(t *testing.T) {
+	in := Inputs{Type: option.CALL, Spot: 100, Strike: 110, Rate: 0.02, DividendYield: 0.01, Volatility: 0.25, Time: 0.5}
+
+	so, err := SecondOrderGreeks(nil, in)
+	if err != nil {
+		t.Fatalf("Error computing second order greeks: %v", err)
+	}
+
+	// closed form Black-Scholes-Merton, scaled to vol points and days
+	sqrtT := math.Sqrt(in.Time)
+	d1 := (math.Log(in.Spot/in.Strike) + (in.Rate-in.DividendYield+in.Volatility*in.Volatility/2)*in.Time) / (in.Volatility * sqrtT)
+	d2 := d1 - in.Volatility*sqrtT
+	dq := math.Exp(-in.DividendYield * in.Time)
+
+	vanna := -dq * normPDF(d1) * d2 / in.Volatility / 100
+	volga := in.Spot * dq * normPDF(d1) * sqrtT * d1 * d2 / in.Volatility / 10000
+	charm := (in.DividendYield*dq*NormCDF(d1) - dq*normPDF(d1)*(2*(in.Rate-in.DividendYield)*in.Time-d2*in.Volatility*sqrtT)/(2*in.Time*in.Volatility*sqrtT)) / daysPerYear
+
+	if !closeTo(so.Vanna, vanna, 1e-5) {
+		t.Errorf("vanna got %.6f, want %.6f", so.Vanna, vanna)
+	}
+	if !closeTo(so.Volga, volga, 1e-5) {
+		t.Errorf("volga got %.6f, want %.6f", so.Volga, volga)
+	}
+	if !closeTo(so.Charm, charm, 2e-5) {
+		t.Errorf("charm got %.6f, want %.6f", so.Charm, charm)
+	}
+
+	// an out of the money call loses delta as time passes
+	if so.Charm >= 0 {
+		t.Errorf("otm call charm should be negative, got %.6f", so.Charm)
+	}
+}
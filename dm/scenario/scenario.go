@@ -0,0 +1,318 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package scenario reprices a portfolio under a grid of underlying moves, implied volatility shifts and days forward
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/beta"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/dm/pricing"
+	"github.com/marklaczynski/acidbath/lib/mjlog"
+)
+
+var (
+	logInfo  = log.New(mjlog.CreateInfoFile(), "INFO  [scenario]: ", log.LstdFlags|log.Lshortfile)
+	logDebug = log.New(mjlog.CreateDebugFile(), "DEBUG [scenario]: ", log.LstdFlags|log.Lshortfile)
+	logError = log.New(mjlog.CreateErrorFile(), "ERROR [scenario]: ", log.LstdFlags|log.Lshortfile)
+)
+
+//MinVolatility is the floor an implied volatility is shifted down to
+const MinVolatility = 0.01
+
+//errors returned when building a scenario grid
+var (
+	ErrEmptyGrid    = errors.New("scenario grid needs at least one move, volatility shift and day")
+	ErrNoBenchmark  = errors.New("beta weighted moves need a benchmark")
+	ErrNoUnderlying = pricing.ErrNoUnderlyingPrice
+)
+
+//MoveType says what the moves of a Grid are applied to
+type MoveType int
+
+//enumeration values for MoveType
+const (
+	PercentMove      MoveType = iota // every underlying moves by the same percent
+	BetaWeightedMove                 // the benchmark moves, each underlying moves by its beta times the benchmark's move
+)
+
+func (mt MoveType) String() string {
+	switch mt {
+	case PercentMove:
+		return "PercentMove"
+	case BetaWeightedMove:
+		return "BetaWeightedMove"
+	}
+	return ""
+}
+
+//Grid is the set of scenarios to run. Every combination of Moves, VolShifts and Days is priced
+type Grid struct {
+	Moves     []float64          // fractional moves of the underlying or benchmark, ie -0.10 for -10%
+	VolShifts []float64          // absolute shifts of implied volatility, ie 0.10 for +10 vol points
+	Days      []int              // calendar days forward
+	MoveType  MoveType           // how Moves are applied
+	Benchmark *asset.Stock       // benchmark for BetaWeightedMove, needs price history
+	Betas     map[string]float64 // betas by underlying symbol, used instead of computing them from history
+	Lookback  int                // daily returns betas are computed over, beta.DefaultLookback if 0
+}
+
+//DefaultGrid returns moves of -10% to +10% in 2.5% steps, volatility shifts of -10 to +10 points in 5 point steps,
+//today and one week forward
+func DefaultGrid() Grid {
+	return Grid{
+		Moves:     []float64{-0.10, -0.075, -0.05, -0.025, 0, 0.025, 0.05, 0.075, 0.10},
+		VolShifts: []float64{-0.10, -0.05, 0, 0.05, 0.10},
+		Days:      []int{0, 7},
+	}
+}
+
+//Config holds the market inputs shared by every position
+type Config struct {
+	Rate   float64            // risk free rate
+	Model  pricing.Model      // Black-Scholes-Merton if nil
+	Spots  map[string]float64 // underlying prices by symbol, used instead of the position's stock last trade price
+	Source pricing.PriceSource
+	Now    time.Time // time.Now() if zero
+}
+
+//PnL is a profit and loss cube indexed by [move][volShift][day] in the order of the Grid
+type PnL [][][]float64
+
+func newPnL(g Grid) PnL {
+	pnl := make(PnL, len(g.Moves))
+	for m := range pnl {
+		pnl[m] = make([][]float64, len(g.VolShifts))
+		for v := range pnl[m] {
+			pnl[m][v] = make([]float64, len(g.Days))
+		}
+	}
+	return pnl
+}
+
+//PositionResult is the scenario P&L of one position
+type PositionResult struct {
+	Symbol     string
+	Underlying string
+	Quantity   float64 // negative when short
+	Beta       float64 // beta used to move the underlying, 1 for PercentMove
+	NoBeta     bool    // the beta weighted underlying had no beta and moved with the benchmark
+	PnL        PnL
+}
+
+//Result is the scenario P&L of a portfolio
+type Result struct {
+	Grid      Grid
+	Positions []PositionResult
+	Skipped   []string // positions that couldn't be priced
+	Total     PnL
+}
+
+//Analyze reprices every stock and option position of p over g. P&L is measured against the model value with
+//today's inputs, so it shows the effect of the scenario and not the model's disagreement with the market
+func Analyze(p *portfolio.Portfolio, g Grid, cfg Config) (*Result, error) {
+	if len(g.Moves) == 0 || len(g.VolShifts) == 0 || len(g.Days) == 0 {
+		return nil, ErrEmptyGrid
+	}
+	if g.MoveType == BetaWeightedMove && g.Benchmark == nil {
+		return nil, ErrNoBenchmark
+	}
+	if cfg.Now.IsZero() {
+		cfg.Now = time.Now()
+	}
+
+	r := &Result{Grid: g, Total: newPnL(g)}
+
+	positions := append(append([]*portfolio.PositionType{}, p.Position(asset.EquityType)...), p.Position(asset.OptionType)...)
+	for _, pos := range positions {
+		pr, err := analyzePosition(pos, g, cfg)
+		if err != nil {
+			logError.Printf("Skipping %s in scenarios: %s\n", pos.Symbol(), err)
+			r.Skipped = append(r.Skipped, pos.Symbol())
+			continue
+		}
+
+		r.Positions = append(r.Positions, pr)
+		r.Total.add(pr.PnL)
+	}
+
+	logInfo.Printf("Ran %d scenarios over %d positions, skipped %d\n", len(g.Moves)*len(g.VolShifts)*len(g.Days), len(r.Positions), len(r.Skipped))
+	return r, nil
+}
+
+//beta returns the beta of symbol's stock to the grid's benchmark, computed over the days both have a close
+func (g Grid) beta(symbol string, stock *asset.Stock) (float64, error) {
+	if g.MoveType != BetaWeightedMove {
+		return 1, nil
+	}
+	if b, ok := g.Betas[symbol]; ok {
+		return b, nil
+	}
+	if stock == nil {
+		return 1, asset.ErrNotEnoughHistory
+	}
+
+	lookback := g.Lookback
+	if lookback == 0 {
+		lookback = beta.DefaultLookback
+	}
+	b, err := stock.BetaTo(g.Benchmark, lookback)
+	if err != nil {
+		return 1, err
+	}
+	return b, nil
+}
+
+func analyzePosition(pos *portfolio.PositionType, g Grid, cfg Config) (PositionResult, error) {
+	underlying := pricing.PositionUnderlying(pos)
+	pr := PositionResult{
+		Symbol:     pos.Symbol(),
+		Underlying: underlying,
+		Quantity:   pos.SignedQuantity(),
+		PnL:        newPnL(g),
+	}
+
+	var err error
+	pr.Beta, err = g.beta(underlying, pos.UnderlyingStock())
+	if err != nil {
+		logError.Printf("No beta for %s, moving it with the benchmark: %s\n", underlying, err)
+		pr.NoBeta = true
+	}
+
+	p, err := pricing.NewPosition(pos, cfg.Spots, 0, pricing.Market{Rate: cfg.Rate, Model: cfg.Model}, cfg.Source, cfg.Now)
+	if err != nil {
+		return pr, err
+	}
+	spot := p.Spot
+
+	if p.Option == nil {
+		for m, move := range g.Moves {
+			change := pr.Quantity * spot * move * pr.Beta
+			for v := range g.VolShifts {
+				for d := range g.Days {
+					pr.PnL[m][v][d] = change
+				}
+			}
+		}
+		return pr, nil
+	}
+
+	o, market := p.Option, p.Market
+	base, err := pricing.Price(o, market, cfg.Now)
+	if err != nil {
+		return pr, err
+	}
+	size := pr.Quantity * p.Multiplier
+
+	for m, move := range g.Moves {
+		for v, shift := range g.VolShifts {
+			for d, days := range g.Days {
+				shocked := market
+				shocked.Spot = spot * (1 + move*pr.Beta)
+				shocked.Volatility = math.Max(market.Volatility+shift, MinVolatility)
+				if shocked.Spot <= 0 {
+					shocked.Spot = 0.01
+				}
+
+				// past expiration the model returns the intrinsic value
+				price, err := pricing.Price(o, shocked, cfg.Now.AddDate(0, 0, days))
+				if err != nil {
+					return pr, err
+				}
+				pr.PnL[m][v][d] = (price.Price - base.Price) * size
+			}
+		}
+	}
+
+	logDebug.Printf("%s beta %.2f base %.4f iv %.4f\n", pos.Symbol(), pr.Beta, base.Price, market.Volatility)
+	return pr, nil
+}
+
+func (pnl PnL) add(other PnL) {
+	for m := range pnl {
+		for v := range pnl[m] {
+			for d := range pnl[m][v] {
+				pnl[m][v][d] += other[m][v][d]
+			}
+		}
+	}
+}
+
+//At returns the total P&L of the scenario with move, volShift and days. ok is false if it isn't on the grid
+func (r *Result) At(move float64, volShift float64, days int) (pnl float64, ok bool) {
+	m, v, d := index(r.Grid.Moves, move), index(r.Grid.VolShifts, volShift), -1
+	for i, day := range r.Grid.Days {
+		if day == days {
+			d = i
+		}
+	}
+	if m < 0 || v < 0 || d < 0 {
+		return 0, false
+	}
+	return r.Total[m][v][d], true
+}
+
+//Worst returns the total P&L of the worst scenario on the grid, and its move, volatility shift and days
+func (r *Result) Worst() (pnl float64, move float64, volShift float64, days int) {
+	pnl = math.Inf(1)
+	for m := range r.Total {
+		for v := range r.Total[m] {
+			for d := range r.Total[m][v] {
+				if r.Total[m][v][d] < pnl {
+					pnl, move, volShift, days = r.Total[m][v][d], r.Grid.Moves[m], r.Grid.VolShifts[v], r.Grid.Days[d]
+				}
+			}
+		}
+	}
+	return pnl, move, volShift, days
+}
+
+func index(values []float64, value float64) int {
+	for i, v := range values {
+		if math.Abs(v-value) < 1e-9 {
+			return i
+		}
+	}
+	return -1
+}
+
+//String prints the total P&L as a move by volatility shift table for each day
+func (r *Result) String() string {
+	str := ""
+	for d, days := range r.Grid.Days {
+		str += fmt.Sprintf("%s +%d days\n%10s", r.Grid.MoveType, days, "move\\vol")
+		for _, shift := range r.Grid.VolShifts {
+			str += fmt.Sprintf("%12.1f", shift*100)
+		}
+		for m, move := range r.Grid.Moves {
+			str += fmt.Sprintf("\n%9.1f%%", move*100)
+			for v := range r.Grid.VolShifts {
+				str += fmt.Sprintf("%12.2f", r.Total[m][v][d])
+			}
+		}
+		str += "\n"
+	}
+	return str
+}
@@ -0,0 +1,168 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package scenario
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/lib/financial"
+)
+
+func testPortfolio(t *testing.T, now time.Time) *portfolio.Portfolio {
+	return testPortfolioOf(t, now, asset.NewStock("XYZ"))
+}
+
+//testPortfolioOf returns 100 shares and a short put of underlying
+func testPortfolioOf(t *testing.T, now time.Time, underlying *asset.Stock) *portfolio.Portfolio {
+	p := portfolio.NewPortfolio()
+
+	stock := portfolio.NewPosition()
+	stock.SetSymbol("XYZ")
+	stock.SetAssetType(asset.EquityType)
+	stock.SetPositionType(portfolio.LongPosition)
+	stock.SetQuantity(100)
+	stock.SetUnderlyingStock(underlying)
+	p.AddPosition(asset.EquityType, stock)
+
+	o, err := option.NewOption("XYZ", 95, now.AddDate(0, 0, 30), option.PUT, 100)
+	if err != nil {
+		t.Fatalf("Error creating option: %v", err)
+	}
+	o.SetImpliedVolatility(0.20)
+
+	put := portfolio.NewPosition()
+	put.SetSymbol("XYZ_P95")
+	put.SetUnderlyingSymbol("XYZ")
+	put.SetAssetType(asset.OptionType)
+	put.SetPositionType(portfolio.ShortPosition)
+	put.SetQuantity(1)
+	put.SetUnderlyingStock(underlying)
+	put.SetUnderlyingOption(o)
+	p.AddPosition(asset.OptionType, put)
+
+	return p
+}
+
+func TestAnalyze(t *testing.T) {
+	now := time.Date(2016, 6, 1, 10, 0, 0, 0, time.UTC)
+	cfg := Config{Rate: 0.01, Spots: map[string]float64{"XYZ": 100}, Now: now}
+
+	r, err := Analyze(testPortfolio(t, now), DefaultGrid(), cfg)
+	if err != nil {
+		t.Fatalf("Error analyzing: %v", err)
+	}
+	if len(r.Positions) != 2 || len(r.Skipped) != 0 {
+		t.Fatalf("got %d positions and %v skipped", len(r.Positions), r.Skipped)
+	}
+
+	if pnl, ok := r.At(0, 0, 0); !ok || math.Abs(pnl) > 1e-9 {
+		t.Errorf("an unchanged market should have no P&L, got %.4f", pnl)
+	}
+
+	// the short put earns theta when nothing moves
+	if pnl, _ := r.At(0, 0, 7); pnl <= 0 {
+		t.Errorf("a week of decay should profit the short put, got %.4f", pnl)
+	}
+
+	stockPnL := r.Positions[0].PnL[0][2][0]
+	if math.Abs(stockPnL+1000) > 1e-9 {
+		t.Errorf("100 shares down 10%% got %.2f, want -1000", stockPnL)
+	}
+
+	crash, _ := r.At(-0.10, 0.10, 0)
+	calm, _ := r.At(-0.10, 0, 0)
+	if crash >= calm || crash >= -1000 {
+		t.Errorf("the short put should lose more when vol rises into the drop, got %.2f vs %.2f", crash, calm)
+	}
+
+	if worst, move, shift, _ := r.Worst(); worst != crash || move != -0.10 || shift != 0.10 {
+		t.Errorf("worst scenario got %.2f at %.2f/%.2f, want %.2f at -10%%/+10 vol", worst, move, shift, crash)
+	}
+}
+
+func TestBetaWeighted(t *testing.T) {
+	now := time.Date(2016, 6, 1, 10, 0, 0, 0, time.UTC)
+	cfg := Config{Rate: 0.01, Spots: map[string]float64{"XYZ": 100}, Now: now}
+
+	g := Grid{Moves: []float64{-0.10}, VolShifts: []float64{0}, Days: []int{0}, MoveType: BetaWeightedMove}
+	if _, err := Analyze(testPortfolio(t, now), g, cfg); err != ErrNoBenchmark {
+		t.Errorf("expected ErrNoBenchmark, got %v", err)
+	}
+
+	g.Benchmark = asset.NewStock("SPY")
+	g.Betas = map[string]float64{"XYZ": 2}
+	r, err := Analyze(testPortfolio(t, now), g, cfg)
+	if err != nil {
+		t.Fatalf("Error analyzing: %v", err)
+	}
+
+	if pnl := r.Positions[0].PnL[0][0][0]; math.Abs(pnl+2000) > 1e-9 {
+		t.Errorf("a beta 2 stock should fall 20%% when the benchmark falls 10%%, got %.2f", pnl)
+	}
+}
+
+//closes returns n+1 daily closes from start whose log returns are scale times a fixed path
+func closes(start time.Time, scale float64, n int) []asset.PriceHistoryType {
+	c := 100.0
+	h := []asset.PriceHistoryType{asset.NewPriceHistoryPoint(financial.NewMoney(c), start)}
+	for i := 1; i <= n; i++ {
+		c *= math.Exp(scale * 0.01 * math.Sin(float64(i)*0.9))
+		h = append(h, asset.NewPriceHistoryPoint(financial.NewMoney(c), start.AddDate(0, 0, i)))
+	}
+	return h
+}
+
+func TestComputedBeta(t *testing.T) {
+	now := time.Date(2016, 6, 1, 10, 0, 0, 0, time.UTC)
+	cfg := Config{Rate: 0.01, Spots: map[string]float64{"XYZ": 100}, Now: now}
+	start := now.AddDate(0, 0, -30)
+
+	// the stock has a day of history before the benchmark, only the shared days are used
+	benchmark := asset.NewStock("SPY")
+	benchmarkHistory := closes(start, 1, 20)[1:]
+	benchmark.SetHistoricalPrice(&benchmarkHistory)
+	stock := asset.NewStock("XYZ")
+	stockHistory := closes(start, 2, 20)
+	stock.SetHistoricalPrice(&stockHistory)
+
+	g := Grid{Moves: []float64{-0.10}, VolShifts: []float64{0}, Days: []int{0}, MoveType: BetaWeightedMove, Benchmark: benchmark, Lookback: 10}
+	r, err := Analyze(testPortfolioOf(t, now, stock), g, cfg)
+	if err != nil {
+		t.Fatalf("Error analyzing: %v", err)
+	}
+	if pr := r.Positions[0]; pr.NoBeta || math.Abs(pr.Beta-2) > 1e-6 {
+		t.Errorf("expected a computed beta of 2, got %.6f (no beta %v)", pr.Beta, pr.NoBeta)
+	}
+
+	// without history the position still moves with the benchmark, flagged as having no beta
+	r, err = Analyze(testPortfolio(t, now), g, cfg)
+	if err != nil {
+		t.Fatalf("Error analyzing: %v", err)
+	}
+	for _, pr := range r.Positions {
+		if !pr.NoBeta || pr.Beta != 1 {
+			t.Errorf("%s should be flagged as having no beta, got %.2f (no beta %v)", pr.Symbol, pr.Beta, pr.NoBeta)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package strategy groups the flat option and stock positions of a portfolio into the trades they form:
+//verticals, iron condors, strangles, straddles, calendars, diagonals, covered calls and collars
+package strategy
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/marklaczynski/acidbath/dm/analytics"
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/lib/mjlog"
+)
+
+var (
+	logInfo  = log.New(mjlog.CreateInfoFile(), "INFO  [strategy]: ", log.LstdFlags|log.Lshortfile)
+	logDebug = log.New(mjlog.CreateDebugFile(), "DEBUG [strategy]: ", log.LstdFlags|log.Lshortfile)
+	logError = log.New(mjlog.CreateErrorFile(), "ERROR [strategy]: ", log.LstdFlags|log.Lshortfile)
+)
+
+//Kind is the structure a group of legs forms
+type Kind int
+
+//enumeration values for Kind
+const (
+	Single Kind = iota // a leg that isn't part of a structure
+	Vertical
+	IronCondor
+	Strangle
+	Straddle
+	Calendar
+	Diagonal
+	CoveredCall
+	Collar
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Single:
+		return "Single"
+	case Vertical:
+		return "Vertical"
+	case IronCondor:
+		return "IronCondor"
+	case Strangle:
+		return "Strangle"
+	case Straddle:
+		return "Straddle"
+	case Calendar:
+		return "Calendar"
+	case Diagonal:
+		return "Diagonal"
+	case CoveredCall:
+		return "CoveredCall"
+	case Collar:
+		return "Collar"
+	}
+	return ""
+}
+
+//Leg is a position, or the part of one, that belongs to a group
+type Leg struct {
+	Position *portfolio.PositionType
+	Quantity float64 // contracts or shares, negative when short
+}
+
+//share returns the fraction of the position in the leg
+func (l Leg) share() float64 {
+	if q := l.Position.SignedQuantity(); q != 0 {
+		return l.Quantity / q
+	}
+	return 0
+}
+
+//Group is a set of legs on one underlying that trade as a single structure.
+//Greeks are in shares for Delta and Gamma and dollars for Theta (per day) and Vega (per vol point), from the
+//broker's option greeks. CostBasis is what was paid to open the group, negative for a credit. MaxLoss is the worst
+//P&L at expiration as a negative number, -Inf when unlimited
+type Group struct {
+	Kind       Kind
+	Underlying string
+	Quantity   float64 // units of the structure, contracts per option leg
+	Legs       []Leg
+	Delta      float64
+	Gamma      float64
+	Theta      float64
+	Vega       float64
+	CostBasis  float64
+	MaxLoss    float64
+	PnL        portfolio.PnL
+}
+
+func (g *Group) String() string {
+	return fmt.Sprintf("%s %s x%.0f delta: %.1f gamma: %.2f theta: %.2f vega: %.2f cost: %.2f max loss: %.2f %s",
+		g.Underlying, g.Kind, g.Quantity, g.Delta, g.Gamma, g.Theta, g.Vega, g.CostBasis, g.MaxLoss, g.PnL)
+}
+
+//leg is a position and the quantity of it not yet grouped
+type leg struct {
+	pos       *portfolio.PositionType
+	remaining float64
+}
+
+func (l *leg) option() *option.Option {
+	return l.pos.UnderlyingOption()
+}
+
+func (l *leg) sign() float64 {
+	if l.remaining < 0 {
+		return -1
+	}
+	return 1
+}
+
+type grouper struct {
+	underlying string
+	options    []*leg
+	stocks     []*leg
+	groups     []*Group
+}
+
+//Find groups the option and stock positions of p by underlying into the structures they form, sorted by
+//underlying. Legs are matched greedily starting with the structures with the most legs. A position is split
+//between groups when the quantities of its legs differ, and whatever doesn't form a structure is a Single
+func Find(p *portfolio.Portfolio) []*Group {
+	grouperBySymbol := make(map[string]*grouper)
+	get := func(underlying string) *grouper {
+		if grouperBySymbol[underlying] == nil {
+			grouperBySymbol[underlying] = &grouper{underlying: underlying}
+		}
+		return grouperBySymbol[underlying]
+	}
+
+	for _, pos := range p.Position(asset.EquityType) {
+		if pos.SignedQuantity() != 0 {
+			gr := get(pos.Symbol())
+			gr.stocks = append(gr.stocks, &leg{pos: pos, remaining: pos.SignedQuantity()})
+		}
+	}
+	for _, pos := range p.Position(asset.OptionType) {
+		o := pos.UnderlyingOption()
+		if pos.SignedQuantity() == 0 || o == nil {
+			continue
+		}
+		underlying := pos.UnderlyingSymbol()
+		if underlying == "" {
+			underlying = o.Underlying()
+		}
+		gr := get(underlying)
+		gr.options = append(gr.options, &leg{pos: pos, remaining: pos.SignedQuantity()})
+	}
+
+	symbols := make([]string, 0, len(grouperBySymbol))
+	for symbol := range grouperBySymbol {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	var groups []*Group
+	for _, symbol := range symbols {
+		groups = append(groups, grouperBySymbol[symbol].group()...)
+	}
+
+	logInfo.Printf("Found %d groups in %d underlyings\n", len(groups), len(symbols))
+	return groups
+}
+
+func (gr *grouper) group() []*Group {
+	sort.Slice(gr.options, func(i, j int) bool {
+		a, b := gr.options[i].option(), gr.options[j].option()
+		if !a.ExpirationDate().Equal(b.ExpirationDate()) {
+			return a.ExpirationDate().Before(b.ExpirationDate())
+		}
+		if a.OptionType() != b.OptionType() {
+			return a.OptionType() < b.OptionType()
+		}
+		return a.Strike() < b.Strike()
+	})
+
+	matchers := []func() bool{
+		gr.matchIronCondor,
+		gr.matchCollar,
+		gr.matchCoveredCall,
+		gr.matchStraddle,
+		gr.matchStrangle,
+		gr.matchVertical,
+		gr.matchCalendar,
+		gr.matchDiagonal,
+	}
+	for _, match := range matchers {
+		for match() {
+		}
+	}
+
+	for _, l := range append(append([]*leg{}, gr.stocks...), gr.options...) {
+		if l.remaining != 0 {
+			gr.take(Single, []*leg{l}, []float64{l.sign()}, math.Abs(l.remaining))
+		}
+	}
+
+	for _, g := range gr.groups {
+		g.summarize()
+		logDebug.Printf("%s\n", g)
+	}
+	return gr.groups
+}
+
+//units returns how many units of a structure made of legs in ratios are left, 0 when a leg is on the wrong side
+func units(legs []*leg, ratios []float64) float64 {
+	n := math.Inf(1)
+	for i, l := range legs {
+		if l.remaining*ratios[i] <= 0 {
+			return 0
+		}
+		n = math.Min(n, math.Abs(l.remaining/ratios[i]))
+	}
+	return math.Floor(n + 1e-9)
+}
+
+//tryTake groups every whole unit of legs in ratios, returning false if there isn't one
+func (gr *grouper) tryTake(kind Kind, legs []*leg, ratios []float64) bool {
+	n := units(legs, ratios)
+	if n < 1 {
+		return false
+	}
+	gr.take(kind, legs, ratios, n)
+	return true
+}
+
+func (gr *grouper) take(kind Kind, legs []*leg, ratios []float64, n float64) {
+	g := &Group{Kind: kind, Underlying: gr.underlying, Quantity: n}
+	for i, l := range legs {
+		q := n * ratios[i]
+		l.remaining -= q
+		if math.Abs(l.remaining) < 1e-9 {
+			l.remaining = 0
+		}
+		g.Legs = append(g.Legs, Leg{Position: l.pos, Quantity: q})
+	}
+	gr.groups = append(gr.groups, g)
+}
+
+//open returns the legs of optionType with quantity left to group
+func (gr *grouper) open(optionType option.TypeOfOption) []*leg {
+	var legs []*leg
+	for _, l := range gr.options {
+		if l.remaining != 0 && l.option().OptionType() == optionType {
+			legs = append(legs, l)
+		}
+	}
+	return legs
+}
+
+func sameExpiration(a *leg, b *leg) bool {
+	return a.option().ExpirationDate().Equal(b.option().ExpirationDate())
+}
+
+//matchIronCondor finds a put vertical and a call vertical in the same expiration with the puts struck at or below
+//the calls, both short or both long the inside strikes
+func (gr *grouper) matchIronCondor() bool {
+	puts, calls := gr.open(option.PUT), gr.open(option.CALL)
+	for i, pOut := range puts {
+		for _, pIn := range puts[i+1:] {
+			if !sameExpiration(pOut, pIn) || pOut.sign() == pIn.sign() || pOut.option().Strike() == pIn.option().Strike() {
+				continue
+			}
+			for j, cIn := range calls {
+				for _, cOut := range calls[j+1:] {
+					if !sameExpiration(pIn, cIn) || !sameExpiration(cIn, cOut) || cIn.sign() != pIn.sign() || cOut.sign() == cIn.sign() {
+						continue
+					}
+					if cIn.option().Strike() < pIn.option().Strike() || cIn.option().Strike() == cOut.option().Strike() {
+						continue
+					}
+					legs := []*leg{pOut, pIn, cIn, cOut}
+					if gr.tryTake(IronCondor, legs, []float64{pOut.sign(), pIn.sign(), cIn.sign(), cOut.sign()}) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+//matchCollar finds long stock with a short call and a long put in the same expiration, one contract per lot of
+//shares
+func (gr *grouper) matchCollar() bool {
+	for _, s := range gr.stocks {
+		for _, c := range gr.open(option.CALL) {
+			for _, p := range gr.open(option.PUT) {
+				if !sameExpiration(c, p) || c.sign() > 0 || p.sign() < 0 {
+					continue
+				}
+				if gr.tryTake(Collar, []*leg{s, c, p}, []float64{c.pos.Multiplier(), -1, 1}) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+//matchCoveredCall finds long stock with a short call, one contract per lot of shares
+func (gr *grouper) matchCoveredCall() bool {
+	for _, s := range gr.stocks {
+		for _, c := range gr.open(option.CALL) {
+			if c.sign() > 0 {
+				continue
+			}
+			if gr.tryTake(CoveredCall, []*leg{s, c}, []float64{c.pos.Multiplier(), -1}) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//matchPutCall finds a put and a call on the same side in the same expiration, struck together or not
+func (gr *grouper) matchPutCall(kind Kind, sameStrike bool) bool {
+	for _, p := range gr.open(option.PUT) {
+		for _, c := range gr.open(option.CALL) {
+			if !sameExpiration(p, c) || p.sign() != c.sign() || (p.option().Strike() == c.option().Strike()) != sameStrike {
+				continue
+			}
+			if gr.tryTake(kind, []*leg{p, c}, []float64{p.sign(), c.sign()}) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (gr *grouper) matchStraddle() bool {
+	return gr.matchPutCall(Straddle, true)
+}
+
+func (gr *grouper) matchStrangle() bool {
+	return gr.matchPutCall(Strangle, false)
+}
+
+//matchSpread finds a long and a short option of the same type, with the expirations and strikes equal or not
+func (gr *grouper) matchSpread(kind Kind, sameExpiry bool, sameStrike bool) bool {
+	for _, optionType := range []option.TypeOfOption{option.PUT, option.CALL} {
+		legs := gr.open(optionType)
+		for i, a := range legs {
+			for _, b := range legs[i+1:] {
+				if a.sign() == b.sign() || sameExpiration(a, b) != sameExpiry || (a.option().Strike() == b.option().Strike()) != sameStrike {
+					continue
+				}
+				if gr.tryTake(kind, []*leg{a, b}, []float64{a.sign(), b.sign()}) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (gr *grouper) matchVertical() bool {
+	return gr.matchSpread(Vertical, true, false)
+}
+
+func (gr *grouper) matchCalendar() bool {
+	return gr.matchSpread(Calendar, false, true)
+}
+
+func (gr *grouper) matchDiagonal() bool {
+	return gr.matchSpread(Diagonal, false, false)
+}
+
+//summarize adds up the greeks, cost and P&L of the legs and works out the max loss
+func (g *Group) summarize() {
+	legs := make([]analytics.Leg, 0, len(g.Legs))
+	for _, l := range g.Legs {
+		mult := l.Position.Multiplier()
+		avg, _ := l.Position.AveragePrice().Value.Float64()
+		g.CostBasis += l.Quantity * avg * mult
+
+		pnl := l.Position.PnL()
+		share := l.share()
+		g.PnL.Add(portfolio.PnL{Unrealized: pnl.Unrealized * share, Realized: pnl.Realized * share, Day: pnl.Day * share})
+
+		o := l.Position.UnderlyingOption()
+		if l.Position.AssetType() != asset.OptionType {
+			o = nil
+			g.Delta += l.Quantity
+		} else {
+			size := l.Quantity * mult
+			g.Delta += o.Delta() * size
+			g.Gamma += o.Gamma() * size
+			g.Theta += o.Theta() * size
+			g.Vega += o.Vega() * size
+		}
+		legs = append(legs, analytics.Leg{Option: o, Quantity: l.Quantity, Price: avg})
+	}
+
+	if g.Kind == Calendar || g.Kind == Diagonal {
+		g.MaxLoss = g.timeSpreadMaxLoss()
+		return
+	}
+	_, g.MaxLoss = analytics.Extremes(legs)
+}
+
+//timeSpreadMaxLoss returns the max loss of a calendar or diagonal. Long the back month, the most it loses is the
+//debit plus the width the short strike is inside the long one. Long the front month, the short is naked once the
+//long expires
+func (g *Group) timeSpreadMaxLoss() float64 {
+	long, short := g.Legs[0], g.Legs[1]
+	if long.Quantity < 0 {
+		long, short = short, long
+	}
+	lo, so := long.Position.UnderlyingOption(), short.Position.UnderlyingOption()
+	if lo.ExpirationDate().Before(so.ExpirationDate()) {
+		return math.Inf(-1)
+	}
+
+	width := math.Max(lo.Strike()-so.Strike(), 0)
+	if lo.OptionType() == option.PUT {
+		width = math.Max(so.Strike()-lo.Strike(), 0)
+	}
+	return math.Min(-(g.CostBasis + width*long.Quantity*long.Position.Multiplier()), 0)
+}
@@ -0,0 +1,123 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package strategy
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/lib/financial"
+)
+
+var front = time.Date(2016, 7, 15, 16, 0, 0, 0, time.UTC)
+
+func addOption(t *testing.T, p *portfolio.Portfolio, underlying string, strike float64, expiration time.Time, typ option.TypeOfOption, quantity float64, price float64) {
+	o, err := option.NewOption(underlying, strike, expiration, typ, 100)
+	if err != nil {
+		t.Fatalf("Error creating option: %v", err)
+	}
+
+	pos := portfolio.NewPosition()
+	pos.SetSymbol(fmt.Sprintf("%s_%s%.0f_%s", underlying, typ, strike, expiration.Format("010206")))
+	pos.SetUnderlyingSymbol(underlying)
+	pos.SetAssetType(asset.OptionType)
+	pos.SetQuantity(math.Abs(quantity))
+	if quantity < 0 {
+		pos.SetPositionType(portfolio.ShortPosition)
+	}
+	pos.SetAveragePrice(financial.NewMoney(price))
+	pos.SetClosePrice(financial.NewMoney(price))
+	pos.SetUnderlyingOption(o)
+	p.AddPosition(asset.OptionType, pos)
+}
+
+func find(groups []*Group, underlying string, kind Kind) *Group {
+	for _, g := range groups {
+		if g.Underlying == underlying && g.Kind == kind {
+			return g
+		}
+	}
+	return nil
+}
+
+func TestFind(t *testing.T) {
+	p := portfolio.NewPortfolio()
+
+	// short iron condor 90/95/105/110 for a 2.00 credit
+	addOption(t, p, "AAA", 90, front, option.PUT, 1, 0.50)
+	addOption(t, p, "AAA", 95, front, option.PUT, -1, 1.50)
+	addOption(t, p, "AAA", 105, front, option.CALL, -1, 1.50)
+	addOption(t, p, "AAA", 110, front, option.CALL, 1, 0.50)
+
+	// 250 shares and 3 short calls: 2 covered, 1 naked, 50 shares left
+	stock := portfolio.NewPosition()
+	stock.SetSymbol("BBB")
+	stock.SetAssetType(asset.EquityType)
+	stock.SetQuantity(250)
+	stock.SetAveragePrice(financial.NewMoney(50))
+	stock.SetClosePrice(financial.NewMoney(50))
+	p.AddPosition(asset.EquityType, stock)
+	addOption(t, p, "BBB", 55, front, option.CALL, -3, 1.00)
+
+	// a long call calendar
+	addOption(t, p, "CCC", 20, front, option.CALL, -1, 0.40)
+	addOption(t, p, "CCC", 20, front.AddDate(0, 1, 0), option.CALL, 1, 1.00)
+
+	groups := Find(p)
+
+	ic := find(groups, "AAA", IronCondor)
+	if ic == nil || len(ic.Legs) != 4 || ic.Quantity != 1 {
+		t.Fatalf("expected an iron condor, got %v", groups)
+	}
+	if math.Abs(ic.CostBasis+200) > 1e-9 || math.Abs(ic.MaxLoss+300) > 1e-9 {
+		t.Errorf("iron condor got cost %.2f max loss %.2f, want -200 and -300", ic.CostBasis, ic.MaxLoss)
+	}
+
+	cc := find(groups, "BBB", CoveredCall)
+	if cc == nil || cc.Quantity != 2 || cc.Legs[0].Quantity != 200 || cc.Legs[1].Quantity != -2 {
+		t.Fatalf("expected 2 covered calls, got %v", cc)
+	}
+	// 200 shares at 50 less 200 of premium
+	if math.Abs(cc.MaxLoss+9800) > 1e-9 {
+		t.Errorf("covered call max loss got %.2f, want -9800", cc.MaxLoss)
+	}
+
+	var singles int
+	for _, g := range groups {
+		if g.Underlying == "BBB" && g.Kind == Single {
+			singles++
+			if g.Legs[0].Position.AssetType() == asset.OptionType && !math.IsInf(g.MaxLoss, -1) {
+				t.Errorf("the naked call should have unlimited loss, got %.2f", g.MaxLoss)
+			}
+		}
+	}
+	if singles != 2 {
+		t.Errorf("expected the 50 shares and the naked call left over, got %d singles", singles)
+	}
+
+	cal := find(groups, "CCC", Calendar)
+	if cal == nil || math.Abs(cal.MaxLoss+60) > 1e-9 {
+		t.Errorf("a long calendar risks its debit, got %v", cal)
+	}
+}
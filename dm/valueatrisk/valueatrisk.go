@@ -0,0 +1,359 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package valueatrisk computes the value at risk and expected shortfall of a portfolio. Every position, options
+//included, is fully repriced under each historical move of its underlying rather than approximated through delta
+package valueatrisk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/dm/pricing"
+	"github.com/marklaczynski/acidbath/lib/mjlog"
+)
+
+var (
+	logInfo  = log.New(mjlog.CreateInfoFile(), "INFO  [valueatrisk]: ", log.LstdFlags|log.Lshortfile)
+	logDebug = log.New(mjlog.CreateDebugFile(), "DEBUG [valueatrisk]: ", log.LstdFlags|log.Lshortfile)
+	logError = log.New(mjlog.CreateErrorFile(), "ERROR [valueatrisk]: ", log.LstdFlags|log.Lshortfile)
+)
+
+//errors returned by Compute
+var (
+	ErrInvalidConfidence = errors.New("confidence must be between 0 and 1")
+	ErrInvalidHorizon    = errors.New("horizon must be at least 1 day")
+	ErrNotEnoughHistory  = errors.New("not enough common price history to simulate")
+	ErrNoPositions       = errors.New("no positions could be priced")
+)
+
+//Method is how the loss distribution is turned into VaR and expected shortfall
+type Method int
+
+//enumeration values for Method
+const (
+	Historical Method = iota // the empirical quantile of the simulated P&L
+	Parametric               // a normal distribution fitted to the simulated P&L
+)
+
+func (m Method) String() string {
+	switch m {
+	case Historical:
+		return "Historical"
+	case Parametric:
+		return "Parametric"
+	}
+	return ""
+}
+
+//Config controls the simulation
+type Config struct {
+	Method     Method
+	Confidence float64                 // ie 0.95 or 0.99
+	Horizon    int                     // trading days the portfolio is held
+	Window     int                     // most recent daily returns used, 0 uses all of the common history
+	Rate       float64                 // risk free rate used to reprice options
+	Model      pricing.Model           // Black-Scholes-Merton if nil
+	Spots      map[string]float64      // underlying prices by symbol, the last trade or last close if missing
+	Histories  map[string]*asset.Stock // stocks with price history by symbol, the position's stock if missing
+	Now        time.Time               // time.Now() if zero
+}
+
+//DefaultConfig returns a one day 95% historical VaR over a year of history
+func DefaultConfig() Config {
+	return Config{
+		Method:     Historical,
+		Confidence: 0.95,
+		Horizon:    1,
+		Window:     asset.TradingDaysPerYear,
+	}
+}
+
+//Contribution is one position's share of the portfolio's VaR and expected shortfall. The contributions of all
+//positions add up to the portfolio's numbers
+type Contribution struct {
+	Symbol            string
+	Underlying        string
+	VaR               float64
+	ExpectedShortfall float64
+	StandaloneVaR     float64 // the VaR of the position held on its own
+}
+
+func (c Contribution) String() string {
+	return fmt.Sprintf("%s: VaR %.2f ES %.2f standalone %.2f", c.Symbol, c.VaR, c.ExpectedShortfall, c.StandaloneVaR)
+}
+
+//Result is the VaR and expected shortfall of a portfolio, as positive losses in dollars
+type Result struct {
+	Method            Method
+	Confidence        float64
+	Horizon           int
+	Scenarios         int
+	VaR               float64
+	ExpectedShortfall float64
+	Contributions     []Contribution
+	Skipped           []string // positions that couldn't be priced or had no history
+}
+
+func (r *Result) String() string {
+	return fmt.Sprintf("%s %d day %.1f%% VaR: %.2f ES: %.2f over %d scenarios", r.Method, r.Horizon, r.Confidence*100, r.VaR, r.ExpectedShortfall, r.Scenarios)
+}
+
+//Compute simulates the P&L of p over cfg.Horizon days for every historical window of returns of its
+//underlyings, and returns the VaR and expected shortfall at cfg.Confidence
+func Compute(p *portfolio.Portfolio, cfg Config) (*Result, error) {
+	if cfg.Confidence <= 0 || cfg.Confidence >= 1 {
+		return nil, ErrInvalidConfidence
+	}
+	if cfg.Horizon < 1 {
+		return nil, ErrInvalidHorizon
+	}
+	if cfg.Now.IsZero() {
+		cfg.Now = time.Now()
+	}
+
+	r := &Result{Method: cfg.Method, Confidence: cfg.Confidence, Horizon: cfg.Horizon}
+
+	var valuers []*valuer
+	histories := make(map[string]*asset.Stock)
+	positions := append(append([]*portfolio.PositionType{}, p.Position(asset.EquityType)...), p.Position(asset.OptionType)...)
+	for _, pos := range positions {
+		v, history, err := newValuer(pos, cfg)
+		if err != nil {
+			logError.Printf("Skipping %s: %s\n", pos.Symbol(), err)
+			r.Skipped = append(r.Skipped, pos.Symbol())
+			continue
+		}
+		valuers = append(valuers, v)
+		histories[v.Underlying] = history
+	}
+	if len(valuers) == 0 {
+		return nil, ErrNoPositions
+	}
+
+	moves, err := horizonReturns(histories, cfg.Window, cfg.Horizon)
+	if err != nil {
+		return nil, err
+	}
+	r.Scenarios = len(moves)
+
+	// pnl[i][s] is position i's P&L in scenario s
+	pnl := make([][]float64, len(valuers))
+	total := make([]float64, len(moves))
+	for i, v := range valuers {
+		pnl[i] = make([]float64, len(moves))
+		for s, move := range moves {
+			value, err := v.value(v.Spot*math.Exp(move[v.Underlying]), cfg.Horizon)
+			if err != nil {
+				return nil, err
+			}
+			pnl[i][s] = value - v.base
+			total[s] += pnl[i][s]
+		}
+	}
+
+	switch cfg.Method {
+	case Parametric:
+		r.VaR, r.ExpectedShortfall = parametric(total, total, cfg.Confidence)
+	default:
+		r.VaR, r.ExpectedShortfall = historical(total, total, cfg.Confidence)
+	}
+
+	for i, v := range valuers {
+		c := Contribution{Symbol: v.Symbol, Underlying: v.Underlying}
+		switch cfg.Method {
+		case Parametric:
+			c.VaR, c.ExpectedShortfall = parametric(pnl[i], total, cfg.Confidence)
+			c.StandaloneVaR, _ = parametric(pnl[i], pnl[i], cfg.Confidence)
+		default:
+			c.VaR, c.ExpectedShortfall = historical(pnl[i], total, cfg.Confidence)
+			c.StandaloneVaR, _ = historical(pnl[i], pnl[i], cfg.Confidence)
+		}
+		r.Contributions = append(r.Contributions, c)
+	}
+
+	logInfo.Printf("%s\n", r)
+	return r, nil
+}
+
+//historical returns the loss of pnl in the portfolio's VaR scenario and its average loss over the portfolio's
+//tail scenarios. With pnl equal to total these are the portfolio's VaR and expected shortfall
+func historical(pnl []float64, total []float64, confidence float64) (float64, float64) {
+	order := make([]int, len(total))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return total[order[a]] < total[order[b]] })
+
+	// 1 - 0.95 isn't exactly 0.05, don't let the rounding add a scenario to the tail
+	tail := int(math.Ceil((1-confidence)*float64(len(total)) - 1e-9))
+	if tail < 1 {
+		tail = 1
+	}
+
+	var sum float64
+	for _, s := range order[:tail] {
+		sum += pnl[s]
+	}
+
+	return -pnl[order[tail-1]], -sum / float64(tail)
+}
+
+//parametric returns pnl's share of the normal VaR and expected shortfall of total, allocated by each one's
+//covariance with total. With pnl equal to total these are the portfolio's VaR and expected shortfall
+func parametric(pnl []float64, total []float64, confidence float64) (float64, float64) {
+	mean, totalMean := average(pnl), average(total)
+
+	var cov, variance float64
+	for s := range total {
+		cov += (pnl[s] - mean) * (total[s] - totalMean)
+		variance += (total[s] - totalMean) * (total[s] - totalMean)
+	}
+	if variance == 0 {
+		return -mean, -mean
+	}
+
+	// the position's share of the portfolio's standard deviation, cov(pnl, total) / sd(total)
+	n := float64(len(total) - 1)
+	sd := (cov / n) / math.Sqrt(variance/n)
+	z := math.Sqrt2 * math.Erfinv(2*confidence-1)
+	pdf := math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+
+	return -mean + z*sd, -mean + pdf/(1-confidence)*sd
+}
+
+func average(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+//horizonReturns returns the overlapping horizon day log returns of each underlying, on the days every
+//underlying has a close
+func horizonReturns(histories map[string]*asset.Stock, window int, horizon int) ([]map[string]float64, error) {
+	closes := make(map[string]map[string]float64, len(histories))
+	var reference string
+	for symbol, s := range histories {
+		closes[symbol] = make(map[string]float64)
+		for _, ph := range s.HistoricalPrice() {
+			c, _ := ph.Close().Value.Float64()
+			if c > 0 {
+				closes[symbol][ph.TimeStamp().Format("2006-01-02")] = c
+			}
+		}
+		if reference == "" || len(closes[symbol]) < len(closes[reference]) ||
+			(len(closes[symbol]) == len(closes[reference]) && symbol < reference) {
+			reference = symbol
+		}
+	}
+
+	// the common days are a subset of every series, so the shortest one is the reference they're taken from
+	var common []string
+	for day := range closes[reference] {
+		ok := true
+		for symbol := range closes {
+			if _, found := closes[symbol][day]; !found {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			common = append(common, day)
+		}
+	}
+	sort.Strings(common)
+
+	if window > 0 && len(common) > window+1 {
+		common = common[len(common)-window-1:]
+	}
+	if len(common) < horizon+2 {
+		return nil, ErrNotEnoughHistory
+	}
+
+	var moves []map[string]float64
+	for end := horizon; end < len(common); end++ {
+		move := make(map[string]float64, len(closes))
+		for symbol, c := range closes {
+			move[symbol] = math.Log(c[common[end]] / c[common[end-horizon]])
+		}
+		moves = append(moves, move)
+	}
+
+	logDebug.Printf("%d common days, %d %d day scenarios\n", len(common), len(moves), horizon)
+	return moves, nil
+}
+
+//valuer values one position at a simulated underlying price
+type valuer struct {
+	*pricing.Position
+	now  time.Time
+	base float64
+}
+
+func newValuer(pos *portfolio.PositionType, cfg Config) (*valuer, *asset.Stock, error) {
+	underlying := pricing.PositionUnderlying(pos)
+	history, ok := cfg.Histories[underlying]
+	if !ok {
+		history = pos.UnderlyingStock()
+	}
+	if history == nil || len(history.HistoricalPrice()) == 0 {
+		return nil, nil, ErrNotEnoughHistory
+	}
+
+	prices := history.HistoricalPrice()
+	lastClose, _ := prices[len(prices)-1].Close().Value.Float64()
+	p, err := pricing.NewPosition(pos, cfg.Spots, lastClose, pricing.Market{Rate: cfg.Rate, Model: cfg.Model}, pricing.MidPrice, cfg.Now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	v := &valuer{Position: p, now: cfg.Now}
+	v.base, err = v.value(v.Spot, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return v, history, nil
+}
+
+//value returns the position's value at spot, days trading days from now. Implied volatility is held constant
+func (v *valuer) value(spot float64, days int) (float64, error) {
+	if v.Option == nil {
+		return v.Quantity * spot, nil
+	}
+
+	m := v.Market
+	m.Spot = spot
+
+	// the horizon is in trading days, the option ages by the same fraction of a year
+	when := v.now.Add(time.Duration(float64(days)*365/float64(asset.TradingDaysPerYear)*24) * time.Hour)
+	g, err := pricing.Price(v.Option, m, when)
+	if err != nil {
+		return 0, err
+	}
+
+	return v.Quantity * v.Multiplier * g.Price, nil
+}
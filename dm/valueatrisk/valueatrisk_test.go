@@ -0,0 +1,158 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package valueatrisk
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/asset"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/lib/financial"
+)
+
+var now = time.Date(2016, 6, 1, 10, 0, 0, 0, time.UTC)
+
+// testReturns is 18 days up 1%, one day down 3% and one down 5%
+func testReturns() []float64 {
+	r := make([]float64, 20)
+	for i := range r {
+		r[i] = 0.01
+	}
+	r[7], r[13] = -0.05, -0.03
+	return r
+}
+
+func stockWithHistory(symbol string, returns []float64) *asset.Stock {
+	s := asset.NewStock(symbol)
+	c := 100.0
+	history := []asset.PriceHistoryType{asset.NewPriceHistoryPoint(financial.Money{Value: new(big.Rat).SetFloat64(c)}, now.AddDate(0, 0, -len(returns)))}
+	for i, r := range returns {
+		c *= math.Exp(r)
+		history = append(history, asset.NewPriceHistoryPoint(financial.Money{Value: new(big.Rat).SetFloat64(c)}, now.AddDate(0, 0, i+1-len(returns))))
+	}
+	s.SetHistoricalPrice(&history)
+	return s
+}
+
+func testPortfolio(t *testing.T, withPut bool) *portfolio.Portfolio {
+	p := portfolio.NewPortfolio()
+
+	stock := portfolio.NewPosition()
+	stock.SetSymbol("XYZ")
+	stock.SetAssetType(asset.EquityType)
+	stock.SetQuantity(100)
+	stock.SetUnderlyingStock(asset.NewStock("XYZ"))
+	p.AddPosition(asset.EquityType, stock)
+
+	if withPut {
+		o, err := option.NewOption("XYZ", 95, now.AddDate(0, 0, 30), option.PUT, 100)
+		if err != nil {
+			t.Fatalf("Error creating option: %v", err)
+		}
+		o.SetImpliedVolatility(0.25)
+
+		put := portfolio.NewPosition()
+		put.SetSymbol("XYZ_P95")
+		put.SetUnderlyingSymbol("XYZ")
+		put.SetAssetType(asset.OptionType)
+		put.SetPositionType(portfolio.ShortPosition)
+		put.SetQuantity(2)
+		put.SetUnderlyingStock(asset.NewStock("XYZ"))
+		put.SetUnderlyingOption(o)
+		p.AddPosition(asset.OptionType, put)
+	}
+
+	return p
+}
+
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Rate = 0.01
+	cfg.Spots = map[string]float64{"XYZ": 100}
+	cfg.Histories = map[string]*asset.Stock{"XYZ": stockWithHistory("XYZ", testReturns())}
+	cfg.Now = now
+	return cfg
+}
+
+func TestHistorical(t *testing.T) {
+	cfg := testConfig()
+
+	// 1 scenario in the 5% tail, the -5% day
+	r, err := Compute(testPortfolio(t, false), cfg)
+	if err != nil {
+		t.Fatalf("Error computing VaR: %v", err)
+	}
+	want := 100 * 100 * (1 - math.Exp(-0.05))
+	if r.Scenarios != 20 || math.Abs(r.VaR-want) > 1e-9 || math.Abs(r.ExpectedShortfall-want) > 1e-9 {
+		t.Errorf("got %s, want VaR and ES %.2f", r, want)
+	}
+
+	// 2 scenarios in the 10% tail, VaR is the -3% day and ES the average of both
+	cfg.Confidence = 0.90
+	r, _ = Compute(testPortfolio(t, false), cfg)
+	three := 100 * 100 * (1 - math.Exp(-0.03))
+	if math.Abs(r.VaR-three) > 1e-9 || math.Abs(r.ExpectedShortfall-(want+three)/2) > 1e-9 {
+		t.Errorf("got %s, want VaR %.2f ES %.2f", r, three, (want+three)/2)
+	}
+}
+
+func TestContributionsAddUp(t *testing.T) {
+	for _, method := range []Method{Historical, Parametric} {
+		cfg := testConfig()
+		cfg.Method = method
+
+		r, err := Compute(testPortfolio(t, true), cfg)
+		if err != nil {
+			t.Fatalf("Error computing %s VaR: %v", method, err)
+		}
+		if len(r.Contributions) != 2 {
+			t.Fatalf("%s got %d contributions, want 2", method, len(r.Contributions))
+		}
+
+		var v, es float64
+		for _, c := range r.Contributions {
+			v += c.VaR
+			es += c.ExpectedShortfall
+		}
+		if math.Abs(v-r.VaR) > 1e-6 || math.Abs(es-r.ExpectedShortfall) > 1e-6 {
+			t.Errorf("%s contributions %.4f/%.4f don't add up to %.4f/%.4f", method, v, es, r.VaR, r.ExpectedShortfall)
+		}
+
+		// the short put adds to the stock's downside
+		if put := r.Contributions[1]; put.VaR <= 0 || r.VaR <= r.Contributions[0].StandaloneVaR {
+			t.Errorf("%s short put should add risk, got %s with %s", method, put, r)
+		}
+		if r.ExpectedShortfall < r.VaR {
+			t.Errorf("%s expected shortfall %.2f should be at least VaR %.2f", method, r.ExpectedShortfall, r.VaR)
+		}
+	}
+}
+
+func TestNoHistory(t *testing.T) {
+	cfg := testConfig()
+	cfg.Histories = nil
+
+	if _, err := Compute(testPortfolio(t, false), cfg); err != ErrNoPositions {
+		t.Errorf("expected ErrNoPositions, got %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+//Package volsurface builds an implied volatility surface from an option chain
+package volsurface
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/optionchain"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/pricing"
+	"github.com/marklaczynski/acidbath/lib/mjlog"
+)
+
+var (
+	logInfo  = log.New(mjlog.CreateInfoFile(), "INFO  [volsurface]: ", log.LstdFlags|log.Lshortfile)
+	logDebug = log.New(mjlog.CreateDebugFile(), "DEBUG [volsurface]: ", log.LstdFlags|log.Lshortfile)
+	logError = log.New(mjlog.CreateErrorFile(), "ERROR [volsurface]: ", log.LstdFlags|log.Lshortfile)
+)
+
+//ErrEmptySurface is returned when no expiration in the chain had a usable quote
+var ErrEmptySurface = errors.New("no usable quotes to build a volatility surface")
+
+//Config controls how the surface is built
+type Config struct {
+	Spot             float64             // underlying price
+	Rate             float64             // risk free rate
+	DividendYield    float64             // continuous dividend yield
+	Source           pricing.PriceSource // price solved for IV when Solve is set, or the broker has no IV
+	Solve            bool                // always solve IV from quotes rather than using the broker's value
+	MaxSpreadPercent float64             // quotes wider than this fraction of the mid are flagged (ie 0.25)
+	MinSpread        float64             // quotes this wide are never flagged by MaxSpreadPercent, so cheap options can be used
+	MaxSpread        float64             // quotes wider than this many dollars are flagged, 0 disables
+	Now              time.Time           // time the surface is built for, time.Now() if zero
+}
+
+//DefaultConfig returns a Config for spot that solves from the mid and flags quotes wider than both
+//25% of the mid and a nickel
+func DefaultConfig(spot float64, rate float64) Config {
+	return Config{
+		Spot:             spot,
+		Rate:             rate,
+		Source:           pricing.MidPrice,
+		MaxSpreadPercent: 0.25,
+		MinSpread:        0.05,
+	}
+}
+
+//Point is the implied volatility of one quoted option
+type Point struct {
+	Symbol    string
+	Type      option.TypeOfOption
+	Strike    float64
+	IV        float64
+	CallDelta float64 // delta of a call at this strike and IV, used to place puts and calls on one delta axis
+	Bid       float64
+	Ask       float64
+	Wide      bool // quote is too wide to trust, the point is not used for interpolation
+}
+
+//Smile is the implied volatility across strikes for one expiration. Each strike uses the out of the money option
+type Smile struct {
+	Expiration time.Time
+	Time       float64 // years to expiry
+	Forward    float64
+	Points     []Point // usable points, sorted by strike
+	Flagged    []Point // points rejected for wide quotes
+}
+
+//Surface is implied volatility by expiration and strike
+type Surface struct {
+	Underlying string
+	Spot       float64
+	Built      time.Time
+	Smiles     []*Smile // sorted by expiration
+}
+
+//TermPoint is the at the money volatility of one expiration
+type TermPoint struct {
+	Expiration time.Time
+	Time       float64
+	ATM        float64
+}
+
+//Build creates the surface for every expiration in oc
+func Build(oc *optionchain.OptionChain, cfg Config) (*Surface, error) {
+	if cfg.Spot <= 0 {
+		return nil, pricing.ErrInvalidSpot
+	}
+	if cfg.Now.IsZero() {
+		cfg.Now = time.Now()
+	}
+
+	s := &Surface{Underlying: oc.Underlying(), Spot: cfg.Spot, Built: cfg.Now}
+
+	for _, od := range oc.SortedExpirations() {
+		t := pricing.TimeToExpiry(od.Date(), cfg.Now)
+		if t <= 0 {
+			continue
+		}
+
+		smile := &Smile{
+			Expiration: od.Date(),
+			Time:       t,
+			Forward:    cfg.Spot * math.Exp((cfg.Rate-cfg.DividendYield)*t),
+		}
+
+		for _, os := range od.SortedStrikes() {
+			// out of the money options carry the volatility information, in the money ones are mostly intrinsic
+			typ := option.CALL
+			if os.Strike() < smile.Forward {
+				typ = option.PUT
+			}
+
+			o := os.Option(typ)
+			if o == nil {
+				continue
+			}
+
+			p, err := newPoint(o, t, cfg)
+			if err != nil {
+				logDebug.Printf("Skipping %s: %s\n", o.OptionTickerSymbol(), err)
+				continue
+			}
+
+			if p.Wide {
+				smile.Flagged = append(smile.Flagged, p)
+			} else {
+				smile.Points = append(smile.Points, p)
+			}
+		}
+
+		if len(smile.Points) == 0 {
+			logInfo.Printf("No usable quotes for %s %s\n", oc.Underlying(), od.Date().Format("2006-01-02"))
+			continue
+		}
+
+		s.Smiles = append(s.Smiles, smile)
+	}
+
+	if len(s.Smiles) == 0 {
+		return nil, ErrEmptySurface
+	}
+
+	return s, nil
+}
+
+func newPoint(o *option.Option, t float64, cfg Config) (Point, error) {
+	bid, _ := o.Bid().Value.Float64()
+	ask, _ := o.Ask().Value.Float64()
+
+	p := Point{
+		Symbol: o.OptionTickerSymbol(),
+		Type:   o.OptionType(),
+		Strike: o.Strike(),
+		Bid:    bid,
+		Ask:    ask,
+	}
+
+	in := pricing.Inputs{
+		Type:          o.OptionType(),
+		Spot:          cfg.Spot,
+		Strike:        o.Strike(),
+		Rate:          cfg.Rate,
+		DividendYield: cfg.DividendYield,
+		Time:          t,
+	}
+
+	p.IV = o.ImpliedVolatility()
+	if cfg.Solve || p.IV <= 0 {
+		iv, err := pricing.ImpliedVolatility(in, pricing.MarketPrice(o, cfg.Source))
+		if err != nil {
+			return p, err
+		}
+		p.IV = iv
+	}
+
+	in.Type = option.CALL
+	in.Volatility = p.IV
+	g, err := pricing.BlackScholes(in)
+	if err != nil {
+		return p, err
+	}
+	p.CallDelta = g.Delta
+
+	mid := (bid + ask) / 2
+	spread := ask - bid
+	switch {
+	case bid <= 0 || ask <= 0:
+		p.Wide = true
+	case cfg.MaxSpreadPercent > 0 && spread > math.Max(mid*cfg.MaxSpreadPercent, cfg.MinSpread):
+		p.Wide = true
+	case cfg.MaxSpread > 0 && spread > cfg.MaxSpread:
+		p.Wide = true
+	}
+
+	return p, nil
+}
+
+//IV returns the implied volatility at strike, interpolating linearly between quoted strikes and flat beyond them
+func (sm *Smile) IV(strike float64) float64 {
+	return interpolate(len(sm.Points), func(i int) (float64, float64) {
+		return sm.Points[i].Strike, sm.Points[i].IV
+	}, strike)
+}
+
+//ATM returns the implied volatility at the forward
+func (sm *Smile) ATM() float64 {
+	return sm.IV(sm.Forward)
+}
+
+//IVAtDelta returns the implied volatility at a call delta (ie 0.25 for the 25 delta call, 0.75 for the 25 delta put)
+func (sm *Smile) IVAtDelta(callDelta float64) float64 {
+	// call delta falls as strike rises, so walk the points from the highest strike to get an increasing axis
+	n := len(sm.Points)
+	return interpolate(n, func(i int) (float64, float64) {
+		p := sm.Points[n-1-i]
+		return p.CallDelta, p.IV
+	}, callDelta)
+}
+
+//Skew25 returns the 25 delta risk reversal: the 25 delta put's IV less the 25 delta call's IV
+func (sm *Smile) Skew25() float64 {
+	return sm.IVAtDelta(0.75) - sm.IVAtDelta(0.25)
+}
+
+func (sm *Smile) String() string {
+	return fmt.Sprintf("%s atm: %.4f skew25: %.4f points: %d flagged: %d", sm.Expiration.Format("2006-01-02"), sm.ATM(), sm.Skew25(), len(sm.Points), len(sm.Flagged))
+}
+
+//Smile returns the smile for expiration, or nil if it isn't on the surface
+func (s *Surface) Smile(expiration time.Time) *Smile {
+	for _, sm := range s.Smiles {
+		if sm.Expiration.Equal(expiration) {
+			return sm
+		}
+	}
+	return nil
+}
+
+//IV returns the implied volatility at strike for an option expiring t years out. Between expirations the total
+//variance (IV^2 * t) is interpolated linearly in time, beyond them the nearest expiration's volatility is used
+func (s *Surface) IV(strike float64, t float64) float64 {
+	if len(s.Smiles) == 0 || t <= 0 {
+		return 0
+	}
+
+	first, last := s.Smiles[0], s.Smiles[len(s.Smiles)-1]
+	if t <= first.Time {
+		return first.IV(strike)
+	}
+	if t >= last.Time {
+		return last.IV(strike)
+	}
+
+	i := sort.Search(len(s.Smiles), func(i int) bool { return s.Smiles[i].Time >= t })
+	before, after := s.Smiles[i-1], s.Smiles[i]
+
+	wb := before.IV(strike) * before.IV(strike) * before.Time
+	wa := after.IV(strike) * after.IV(strike) * after.Time
+	w := wb + (wa-wb)*(t-before.Time)/(after.Time-before.Time)
+
+	return math.Sqrt(math.Max(w, 0) / t)
+}
+
+//IVAt returns the implied volatility at strike for an option expiring on expiration
+func (s *Surface) IVAt(strike float64, expiration time.Time) float64 {
+	return s.IV(strike, pricing.TimeToExpiry(expiration, s.Built))
+}
+
+//TermStructure returns the at the money volatility of each expiration
+func (s *Surface) TermStructure() []TermPoint {
+	term := make([]TermPoint, 0, len(s.Smiles))
+	for _, sm := range s.Smiles {
+		term = append(term, TermPoint{Expiration: sm.Expiration, Time: sm.Time, ATM: sm.ATM()})
+	}
+	return term
+}
+
+//Flagged returns every quote on the surface that was too wide to use
+func (s *Surface) Flagged() []Point {
+	var flagged []Point
+	for _, sm := range s.Smiles {
+		flagged = append(flagged, sm.Flagged...)
+	}
+	return flagged
+}
+
+//interpolate linearly interpolates y at x over n points with increasing x, holding the end values flat
+func interpolate(n int, point func(i int) (float64, float64), x float64) float64 {
+	if n == 0 {
+		return 0
+	}
+
+	x0, y0 := point(0)
+	if x <= x0 || n == 1 {
+		return y0
+	}
+
+	for i := 1; i < n; i++ {
+		x1, y1 := point(i)
+		if x <= x1 {
+			if x1 == x0 {
+				return y1
+			}
+			return y0 + (y1-y0)*(x-x0)/(x1-x0)
+		}
+		x0, y0 = x1, y1
+	}
+
+	return y0
+}
@@ -0,0 +1,112 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package volsurface
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/marklaczynski/acidbath/dm/optionchain"
+	"github.com/marklaczynski/acidbath/dm/optionchain/option"
+	"github.com/marklaczynski/acidbath/dm/pricing"
+	"github.com/marklaczynski/acidbath/lib/financial"
+)
+
+// skewedVol is a smile that rises 0.2 vol points per dollar of strike below 100, scaled by expiry
+func skewedVol(strike float64, days int) float64 {
+	return 0.20 + float64(days)/1000 + 0.002*(100-strike)
+}
+
+func testChain(t *testing.T, now time.Time) *optionchain.OptionChain {
+	oc := optionchain.NewOptionChain("XYZ")
+
+	for _, days := range []int{30, 90} {
+		exp := now.AddDate(0, 0, days)
+		for strike := 80.0; strike <= 120; strike += 5 {
+			for _, typ := range []option.TypeOfOption{option.CALL, option.PUT} {
+				o, err := oc.NewOption("XYZ", strike, exp, typ, 100)
+				if err != nil {
+					t.Fatalf("Error creating option: %v", err)
+				}
+				o.SetOptionTickerSymbol(typ.String() + exp.Format("060102") + big.NewFloat(strike).String())
+
+				in := pricing.Inputs{Type: typ, Spot: 100, Strike: strike, Rate: 0.01, Volatility: skewedVol(strike, days), Time: pricing.TimeToExpiry(o.ExpirationDate(), now)}
+				g, _ := pricing.BlackScholes(in)
+
+				spread := 0.01
+				if strike == 120 && days == 30 {
+					// deep otm quote with no real market
+					spread = 2
+				}
+				o.SetBid(financial.NewMoney(math.Max(g.Price-spread/2, 0.01)))
+				o.SetAsk(financial.NewMoney(g.Price + spread/2))
+
+				if err := oc.AddOption(o); err != nil {
+					t.Fatalf("Error adding option: %v", err)
+				}
+			}
+		}
+	}
+
+	return oc
+}
+
+func TestBuild(t *testing.T) {
+	now := time.Now()
+	cfg := DefaultConfig(100, 0.01)
+	cfg.Now = now
+
+	s, err := Build(testChain(t, now), cfg)
+	if err != nil {
+		t.Fatalf("Error building surface: %v", err)
+	}
+
+	if len(s.Smiles) != 2 {
+		t.Fatalf("got %d smiles, want 2", len(s.Smiles))
+	}
+
+	near := s.Smiles[0]
+	if len(near.Flagged) != 1 || near.Flagged[0].Strike != 120 {
+		t.Errorf("expected the 120 strike to be flagged, got %+v", near.Flagged)
+	}
+
+	// the smile is linear in strike so interpolation is exact, up to the rounding of quotes to the mid
+	if iv := near.IV(92.5); math.Abs(iv-skewedVol(92.5, 30)) > 0.002 {
+		t.Errorf("iv at 92.5 got %.4f, want %.4f", iv, skewedVol(92.5, 30))
+	}
+	if atm := near.ATM(); math.Abs(atm-skewedVol(near.Forward, 30)) > 0.002 {
+		t.Errorf("atm got %.4f, want %.4f", atm, skewedVol(near.Forward, 30))
+	}
+	if skew := near.Skew25(); skew <= 0 {
+		t.Errorf("puts are richer than calls, skew should be positive, got %.4f", skew)
+	}
+
+	term := s.TermStructure()
+	if len(term) != 2 || term[1].ATM <= term[0].ATM {
+		t.Errorf("term structure should be upward sloping, got %+v", term)
+	}
+
+	// total variance interpolation sits between the two expirations
+	mid := (s.Smiles[0].Time + s.Smiles[1].Time) / 2
+	if iv := s.IV(100, mid); iv <= s.Smiles[0].IV(100) || iv >= s.Smiles[1].IV(100) {
+		t.Errorf("interpolated iv %.4f should be between %.4f and %.4f", iv, s.Smiles[0].IV(100), s.Smiles[1].IV(100))
+	}
+}
@@ -0,0 +1,421 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package date
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//market hours, as offsets from midnight New York
+const (
+	MarketOpen       = 9*time.Hour + 30*time.Minute
+	MarketClose      = 16 * time.Hour
+	MarketEarlyClose = 13 * time.Hour
+)
+
+//year lengths used to turn time to expiry into years
+const (
+	CalendarMinutesPerYear = 365 * 24 * 60
+	TradingDaysPerYear     = 252
+	TradingMinutesPerYear  = TradingDaysPerYear * 390
+)
+
+//ExpirationCycle is a listing cycle of equity and index options
+type ExpirationCycle int
+
+//enumeration values for ExpirationCycle
+const (
+	Monthly    ExpirationCycle = iota // third Friday of the month
+	Weekly                            // Fridays that aren't a monthly expiration
+	EndOfMonth                        // last trading day of the month
+	Quarterly                         // last trading day of March, June, September and December
+)
+
+func (ec ExpirationCycle) String() string {
+	switch ec {
+	case Monthly:
+		return "Monthly"
+	case Weekly:
+		return "Weekly"
+	case EndOfMonth:
+		return "EndOfMonth"
+	case Quarterly:
+		return "Quarterly"
+	}
+	return ""
+}
+
+//Calendar is the US equity market calendar: regular hours, exchange holidays and 1:00 PM early closes.
+//Every date it returns is midnight New York, the same as option expiration dates
+type Calendar struct {
+	location *time.Location
+	mutex    sync.Mutex
+	closures map[string]bool // unscheduled closures, like for a national day of mourning
+	years    map[int]*calendarYear
+}
+
+type calendarYear struct {
+	holidays    map[string]bool
+	earlyCloses map[string]bool
+}
+
+//NewCalendar returns the US equity market calendar
+func NewCalendar() (*Calendar, error) {
+	loc, err := Location()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Calendar{
+		location: loc,
+		closures: make(map[string]bool),
+		years:    make(map[int]*calendarYear),
+	}, nil
+}
+
+func dayKey(y int, m time.Month, d int) string {
+	return fmt.Sprintf("%04d-%02d-%02d", y, m, d)
+}
+
+//day returns midnight New York of the day t falls on in New York
+func (c *Calendar) day(t time.Time) time.Time {
+	y, m, d := t.In(c.location).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, c.location)
+}
+
+func (c *Calendar) key(t time.Time) string {
+	y, m, d := t.In(c.location).Date()
+	return dayKey(y, m, d)
+}
+
+//AddClosure marks the day of t as closed
+func (c *Calendar) AddClosure(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closures[c.key(t)] = true
+}
+
+func (c *Calendar) year(y int) *calendarYear {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if cy, ok := c.years[y]; ok {
+		return cy
+	}
+
+	cy := &calendarYear{holidays: make(map[string]bool), earlyCloses: make(map[string]bool)}
+	for _, h := range holidays(y) {
+		cy.holidays[dayKey(h.Date())] = true
+	}
+
+	// the day before Independence Day, the day after Thanksgiving and Christmas Eve close early when they trade
+	for _, e := range []time.Time{
+		time.Date(y, time.July, 3, 0, 0, 0, 0, time.UTC),
+		nthWeekday(y, time.November, time.Thursday, 4).AddDate(0, 0, 1),
+		time.Date(y, time.December, 24, 0, 0, 0, 0, time.UTC),
+	} {
+		if k := dayKey(e.Date()); !isWeekend(e) && !cy.holidays[k] {
+			cy.earlyCloses[k] = true
+		}
+	}
+
+	c.years[y] = cy
+	return cy
+}
+
+//holidays returns the NYSE holidays of year y as UTC dates
+func holidays(y int) []time.Time {
+	var days []time.Time
+
+	// New Year's Day isn't moved back into the previous year when it falls on a Saturday
+	newYear := time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if newYear.Weekday() == time.Sunday {
+		newYear = newYear.AddDate(0, 0, 1)
+	}
+	if newYear.Weekday() != time.Saturday {
+		days = append(days, newYear)
+	}
+
+	if y >= 1998 {
+		days = append(days, nthWeekday(y, time.January, time.Monday, 3))
+	}
+	days = append(days,
+		nthWeekday(y, time.February, time.Monday, 3),
+		easter(y).AddDate(0, 0, -2),
+		lastWeekday(y, time.May, time.Monday),
+	)
+	if y >= 2022 {
+		days = append(days, observed(time.Date(y, time.June, 19, 0, 0, 0, 0, time.UTC)))
+	}
+	days = append(days,
+		observed(time.Date(y, time.July, 4, 0, 0, 0, 0, time.UTC)),
+		nthWeekday(y, time.September, time.Monday, 1),
+		nthWeekday(y, time.November, time.Thursday, 4),
+		observed(time.Date(y, time.December, 25, 0, 0, 0, 0, time.UTC)),
+	)
+
+	return days
+}
+
+//observed moves a holiday on a Saturday to the Friday before and on a Sunday to the Monday after
+func observed(t time.Time) time.Time {
+	switch t.Weekday() {
+	case time.Saturday:
+		return t.AddDate(0, 0, -1)
+	case time.Sunday:
+		return t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+//nthWeekday returns the nth weekday of a month, as a UTC date
+func nthWeekday(y int, m time.Month, wd time.Weekday, n int) time.Time {
+	t := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(wd) - int(t.Weekday()) + 7) % 7
+	return t.AddDate(0, 0, offset+7*(n-1))
+}
+
+//lastWeekday returns the last weekday of a month, as a UTC date
+func lastWeekday(y int, m time.Month, wd time.Weekday) time.Time {
+	t := time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC)
+	offset := (int(t.Weekday()) - int(wd) + 7) % 7
+	return t.AddDate(0, 0, -offset)
+}
+
+//easter returns Easter Sunday of the Gregorian calendar
+func easter(y int) time.Time {
+	a := y % 19
+	b, c := y/100, y%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(y, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+//IsHoliday returns true if the market is closed for a holiday or an added closure on the day of t
+func (c *Calendar) IsHoliday(t time.Time) bool {
+	k := c.key(t)
+	c.mutex.Lock()
+	closed := c.closures[k]
+	c.mutex.Unlock()
+	return closed || c.year(t.In(c.location).Year()).holidays[k]
+}
+
+//IsEarlyClose returns true if the market closes at 1:00 PM on the day of t
+func (c *Calendar) IsEarlyClose(t time.Time) bool {
+	return c.IsTradingDay(t) && c.year(t.In(c.location).Year()).earlyCloses[c.key(t)]
+}
+
+//IsTradingDay returns true if the market opens on the day of t
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	return !isWeekend(t.In(c.location)) && !c.IsHoliday(t)
+}
+
+//MarketHours returns the open and close of the day of t, and false if the market doesn't open that day
+func (c *Calendar) MarketHours(t time.Time) (open time.Time, close time.Time, ok bool) {
+	if !c.IsTradingDay(t) {
+		return time.Time{}, time.Time{}, false
+	}
+
+	day := c.day(t)
+	open = day.Add(MarketOpen)
+	close = day.Add(MarketClose)
+	if c.IsEarlyClose(t) {
+		close = day.Add(MarketEarlyClose)
+	}
+	return open, close, true
+}
+
+//IsOpen returns true during regular market hours
+func (c *Calendar) IsOpen(t time.Time) bool {
+	open, close, ok := c.MarketHours(t)
+	return ok && !t.Before(open) && t.Before(close)
+}
+
+//NextTradingDay returns the first trading day after the day of t
+func (c *Calendar) NextTradingDay(t time.Time) time.Time {
+	day := c.day(t).AddDate(0, 0, 1)
+	for !c.IsTradingDay(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+//PreviousTradingDay returns the last trading day before the day of t
+func (c *Calendar) PreviousTradingDay(t time.Time) time.Time {
+	day := c.day(t).AddDate(0, 0, -1)
+	for !c.IsTradingDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+//onOrBefore returns the day of t if it trades, otherwise the trading day before it
+func (c *Calendar) onOrBefore(t time.Time) time.Time {
+	if c.IsTradingDay(t) {
+		return c.day(t)
+	}
+	return c.PreviousTradingDay(t)
+}
+
+//TradingDaysBetween returns the number of trading days after the day of from up to and including the day of to
+func (c *Calendar) TradingDaysBetween(from time.Time, to time.Time) int {
+	n := 0
+	for day := c.day(from).AddDate(0, 0, 1); !day.After(c.day(to)); day = day.AddDate(0, 0, 1) {
+		if c.IsTradingDay(day) {
+			n++
+		}
+	}
+	return n
+}
+
+//TradingMinutesBetween returns the minutes of regular market hours between from and to
+func (c *Calendar) TradingMinutesBetween(from time.Time, to time.Time) float64 {
+	var minutes float64
+	for day := c.day(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		open, close, ok := c.MarketHours(day)
+		if !ok {
+			continue
+		}
+		if open.Before(from) {
+			open = from
+		}
+		if close.After(to) {
+			close = to
+		}
+		if close.After(open) {
+			minutes += close.Sub(open).Minutes()
+		}
+	}
+	return minutes
+}
+
+//Settlement returns when options expiring on the day of expiration stop trading: the close, 4:00 PM unless it is an
+//early close. An expiration on a day the market is closed settles at the close of the trading day before
+func (c *Calendar) Settlement(expiration time.Time) time.Time {
+	_, close, _ := c.MarketHours(c.onOrBefore(expiration))
+	return close
+}
+
+//CalendarTimeToExpiry returns the calendar time from now to settlement in years, 0 once settled
+func (c *Calendar) CalendarTimeToExpiry(expiration time.Time, now time.Time) float64 {
+	minutes := c.Settlement(expiration).Sub(now).Minutes()
+	if minutes < 0 {
+		return 0
+	}
+	return minutes / CalendarMinutesPerYear
+}
+
+//TradingTimeToExpiry returns the regular market hours from now to settlement in trading years, so nights,
+//weekends and holidays don't count
+func (c *Calendar) TradingTimeToExpiry(expiration time.Time, now time.Time) float64 {
+	return c.TradingMinutesBetween(now, c.Settlement(expiration)) / TradingMinutesPerYear
+}
+
+//MonthlyExpiration returns the standard expiration of a month, the third Friday, or the Thursday before when the
+//Friday is a holiday
+func (c *Calendar) MonthlyExpiration(y int, m time.Month) time.Time {
+	f := nthWeekday(y, m, time.Friday, 3)
+	return c.onOrBefore(time.Date(f.Year(), f.Month(), f.Day(), 12, 0, 0, 0, c.location))
+}
+
+//EndOfMonthExpiration returns the last trading day of a month
+func (c *Calendar) EndOfMonthExpiration(y int, m time.Month) time.Time {
+	last := time.Date(y, m+1, 0, 12, 0, 0, 0, c.location)
+	return c.onOrBefore(last)
+}
+
+//Expirations returns the expirations of cycle from the day of from through the day of to, in order. Weekly
+//expirations don't include the Fridays that are monthly expirations
+func (c *Calendar) Expirations(cycle ExpirationCycle, from time.Time, to time.Time) []time.Time {
+	first, last := c.day(from), c.day(to)
+	inRange := func(t time.Time) bool {
+		return !t.Before(first) && !t.After(last)
+	}
+
+	var expirations []time.Time
+	switch cycle {
+	case Weekly:
+		friday := first.AddDate(0, 0, (int(time.Friday)-int(first.Weekday())+7)%7)
+		for ; !c.onOrBefore(friday).After(last); friday = friday.AddDate(0, 0, 7) {
+			e := c.onOrBefore(friday)
+			if inRange(e) && !e.Equal(c.MonthlyExpiration(friday.Year(), friday.Month())) {
+				expirations = append(expirations, e)
+			}
+		}
+	default:
+		for month := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, c.location); !month.After(last); month = month.AddDate(0, 1, 0) {
+			var e time.Time
+			switch cycle {
+			case Monthly:
+				e = c.MonthlyExpiration(month.Year(), month.Month())
+			case EndOfMonth:
+				e = c.EndOfMonthExpiration(month.Year(), month.Month())
+			case Quarterly:
+				if month.Month()%3 != 0 {
+					continue
+				}
+				e = c.EndOfMonthExpiration(month.Year(), month.Month())
+			}
+			if inRange(e) {
+				expirations = append(expirations, e)
+			}
+		}
+	}
+
+	return expirations
+}
+
+//Cycle returns the most significant cycle the day of expiration is an expiration of: monthly, then quarterly, end
+//of month and weekly. It returns false if no cycle expires that day
+func (c *Calendar) Cycle(expiration time.Time) (ExpirationCycle, bool) {
+	day := c.day(expiration)
+	if !c.IsTradingDay(day) {
+		return Monthly, false
+	}
+
+	y, m := day.Year(), day.Month()
+	if day.Equal(c.MonthlyExpiration(y, m)) {
+		return Monthly, true
+	}
+	if day.Equal(c.EndOfMonthExpiration(y, m)) {
+		if m%3 == 0 {
+			return Quarterly, true
+		}
+		return EndOfMonth, true
+	}
+
+	// a Friday, or the Thursday before a Friday holiday
+	if day.Weekday() == time.Friday || (day.Weekday() == time.Thursday && !c.IsTradingDay(day.AddDate(0, 0, 1))) {
+		return Weekly, true
+	}
+	return Monthly, false
+}
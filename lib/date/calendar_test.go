@@ -0,0 +1,135 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package date
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func newTestCalendar(t *testing.T) *Calendar {
+	c, err := NewCalendar()
+	if err != nil {
+		t.Fatalf("Error creating calendar: %v", err)
+	}
+	return c
+}
+
+func (c *Calendar) at(y int, m time.Month, d int, h int, min int) time.Time {
+	return time.Date(y, m, d, h, min, 0, 0, c.location)
+}
+
+func TestHolidays(t *testing.T) {
+	c := newTestCalendar(t)
+
+	tests := []struct {
+		y       int
+		m       time.Month
+		d       int
+		trading bool
+		early   bool
+	}{
+		{2016, time.January, 18, false, false},  // MLK
+		{2016, time.March, 25, false, false},    // Good Friday
+		{2016, time.May, 30, false, false},      // Memorial Day
+		{2016, time.July, 4, false, false},      // Independence Day
+		{2016, time.November, 24, false, false}, // Thanksgiving
+		{2016, time.November, 25, true, true},   // day after Thanksgiving
+		{2016, time.December, 26, false, false}, // Christmas on a Sunday
+		{2016, time.December, 23, true, false},
+		{2018, time.July, 3, true, true},
+		{2018, time.December, 24, true, true},
+		{2021, time.December, 31, true, false}, // New Year's Day 2022 is a Saturday
+		{2022, time.June, 20, false, false},    // Juneteenth on a Sunday
+		{2016, time.June, 11, false, false},    // Saturday
+	}
+
+	for _, v := range tests {
+		day := c.at(v.y, v.m, v.d, 10, 0)
+		if c.IsTradingDay(day) != v.trading || c.IsEarlyClose(day) != v.early {
+			t.Errorf("%s got trading %v early %v, want %v %v", day.Format("2006-01-02"), c.IsTradingDay(day), c.IsEarlyClose(day), v.trading, v.early)
+		}
+	}
+
+	c.AddClosure(c.at(2018, time.December, 5, 0, 0))
+	if c.IsTradingDay(c.at(2018, time.December, 5, 12, 0)) {
+		t.Errorf("an added closure should close the market")
+	}
+
+	if !c.IsOpen(c.at(2016, time.June, 10, 9, 30)) || c.IsOpen(c.at(2016, time.June, 10, 16, 0)) || c.IsOpen(c.at(2016, time.November, 25, 13, 30)) {
+		t.Errorf("market hours are 9:30 to 4:00, 1:00 on early closes")
+	}
+	if next := c.NextTradingDay(c.at(2016, time.July, 1, 12, 0)); !next.Equal(c.at(2016, time.July, 5, 0, 0)) {
+		t.Errorf("the next trading day after the long weekend got %s", next)
+	}
+}
+
+func TestExpirations(t *testing.T) {
+	c := newTestCalendar(t)
+
+	// third Friday is Good Friday
+	if e := c.MonthlyExpiration(2022, time.April); !e.Equal(c.at(2022, time.April, 14, 0, 0)) {
+		t.Errorf("April 2022 monthly got %s, want the Thursday", e)
+	}
+
+	quarterly := c.Expirations(Quarterly, c.at(2016, time.January, 1, 0, 0), c.at(2016, time.December, 31, 0, 0))
+	want := []int{31, 30, 30, 30}
+	if len(quarterly) != len(want) {
+		t.Fatalf("got quarterlies %v", quarterly)
+	}
+	for i, e := range quarterly {
+		if e.Day() != want[i] {
+			t.Errorf("quarterly %d got %s", i, e.Format("2006-01-02"))
+		}
+	}
+
+	weekly := c.Expirations(Weekly, c.at(2016, time.June, 1, 0, 0), c.at(2016, time.June, 30, 0, 0))
+	if len(weekly) != 3 || weekly[0].Day() != 3 || weekly[1].Day() != 10 || weekly[2].Day() != 24 {
+		t.Errorf("June 2016 weeklies got %v", weekly)
+	}
+
+	if cycle, ok := c.Cycle(c.at(2016, time.June, 17, 0, 0)); !ok || cycle != Monthly {
+		t.Errorf("June 17 2016 got %s", cycle)
+	}
+	if cycle, ok := c.Cycle(c.at(2016, time.June, 30, 0, 0)); !ok || cycle != Quarterly {
+		t.Errorf("June 30 2016 got %s", cycle)
+	}
+}
+
+func TestTimeToExpiry(t *testing.T) {
+	c := newTestCalendar(t)
+
+	// Friday's close to Monday's settlement is one trading day and three calendar days
+	friday := c.at(2016, time.June, 10, 16, 0)
+	monday := c.at(2016, time.June, 13, 0, 0)
+	if tt := c.TradingTimeToExpiry(monday, friday); math.Abs(tt-1.0/TradingDaysPerYear) > 1e-12 {
+		t.Errorf("trading time got %.6f, want %.6f", tt, 1.0/TradingDaysPerYear)
+	}
+	if ct := c.CalendarTimeToExpiry(monday, friday); math.Abs(ct-3.0/365) > 1e-12 {
+		t.Errorf("calendar time got %.6f, want %.6f", ct, 3.0/365)
+	}
+
+	if s := c.Settlement(c.at(2016, time.November, 25, 0, 0)); s.Hour() != 13 {
+		t.Errorf("an early close settles at 1:00 PM, got %s", s)
+	}
+	if c.CalendarTimeToExpiry(monday, monday.Add(17*time.Hour)) != 0 {
+		t.Errorf("settled options have no time left")
+	}
+}
@@ -20,6 +20,7 @@ package date
 
 import (
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -34,22 +35,35 @@ const (
 	LocalLocation      = "America/New_York"
 )
 
+// the LocalLocation time zone, loaded once. time.LoadLocation reads the zone file on every call
+var (
+	localOnce     sync.Once
+	localLocation *time.Location
+	localErr      error
+)
+
+//Location returns the LocalLocation time zone
+func Location() (*time.Location, error) {
+	localOnce.Do(func() {
+		localLocation, localErr = time.LoadLocation(LocalLocation)
+		if localErr != nil {
+			localErr = fmt.Errorf("Error loading location %s with error %v\n", LocalLocation, localErr)
+		}
+	})
+	return localLocation, localErr
+}
+
 // New returns a new time.Time structure with no timestamp
 // Date will always use UTC as the locale.
 func New(date time.Time) (time.Time, error) {
 	year, month, day := date.Date()
 
-	loc, err := time.LoadLocation(LocalLocation)
-	if err != nil {
-		return time.Time{}, fmt.Errorf("Error loading location %s with error %v\n", LocalLocation, err)
-	}
-
-	newDate, err := time.ParseInLocation(ParseShortFormat, fmt.Sprintf("%04d-%s-%02d", year, month, day), loc)
+	loc, err := Location()
 	if err != nil {
 		return time.Time{}, err
 	}
 
-	return newDate.Local(), nil
+	return time.Date(year, month, day, 0, 0, 0, 0, loc).Local(), nil
 }
 
 func (d Dates) Len() int {
@@ -18,9 +18,13 @@
 package financial
 
 import (
+	"bytes"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"math/big"
 
 	"github.com/marklaczynski/acidbath/lib/mjlog"
@@ -32,11 +36,188 @@ var (
 	logError = log.New(mjlog.CreateErrorFile(), "ERROR [financial]: ", log.LstdFlags|log.Lshortfile)
 )
 
-//Money represents financial amounts, which is implemented as big.Rat
+//errors returned by Money
+var (
+	ErrDivideByZero = errors.New("division by zero")
+	ErrInvalidMoney = errors.New("invalid money amount")
+)
+
+//maxDecimalPlaces is the most decimal places Money is written with. Amounts that need more are rounded
+const maxDecimalPlaces = 8
+
+//Money represents financial amounts, which is implemented as big.Rat.
+//The arithmetic methods never modify their operands and always return Money with its own Rat, so the results can
+//be stored without sharing a pointer. A nil Value is treated as 0
 type Money struct {
 	Value *big.Rat
 }
 
+//NewMoney returns value as Money. NaN and infinities are 0
+func NewMoney(value float64) Money {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return Zero()
+	}
+	return Money{Value: new(big.Rat).SetFloat64(value)}
+}
+
+//ParseMoney parses a decimal or fraction string like "1.25" or "5/4"
+func ParseMoney(s string) (Money, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Zero(), fmt.Errorf("%s: %q", ErrInvalidMoney, s)
+	}
+	return Money{Value: r}, nil
+}
+
+//Zero returns 0
+func Zero() Money {
+	return Money{Value: big.NewRat(0, 1)}
+}
+
+//rat returns the value, 0 when it is nil. Callers must not modify it
+func (m Money) rat() *big.Rat {
+	if m.Value == nil {
+		return big.NewRat(0, 1)
+	}
+	return m.Value
+}
+
+//Copy returns m with its own Rat
+func (m Money) Copy() Money {
+	return Money{Value: new(big.Rat).Set(m.rat())}
+}
+
+//Float64 returns the nearest float64 to m
+func (m Money) Float64() float64 {
+	f, _ := m.rat().Float64()
+	return f
+}
+
+//Add returns m + n
+func (m Money) Add(n Money) Money {
+	return Money{Value: new(big.Rat).Add(m.rat(), n.rat())}
+}
+
+//Sub returns m - n
+func (m Money) Sub(n Money) Money {
+	return Money{Value: new(big.Rat).Sub(m.rat(), n.rat())}
+}
+
+//Mul returns m * quantity, ie the value of quantity shares or contracts at price m. quantity is converted exactly
+func (m Money) Mul(quantity float64) Money {
+	if math.IsNaN(quantity) || math.IsInf(quantity, 0) {
+		return Zero()
+	}
+	return Money{Value: new(big.Rat).Mul(m.rat(), new(big.Rat).SetFloat64(quantity))}
+}
+
+//Div returns m / quantity, ie the price per share or contract of the amount m
+func (m Money) Div(quantity float64) (Money, error) {
+	if quantity == 0 || math.IsNaN(quantity) || math.IsInf(quantity, 0) {
+		return Zero(), ErrDivideByZero
+	}
+	return Money{Value: new(big.Rat).Quo(m.rat(), new(big.Rat).SetFloat64(quantity))}, nil
+}
+
+//Neg returns -m
+func (m Money) Neg() Money {
+	return Money{Value: new(big.Rat).Neg(m.rat())}
+}
+
+//Abs returns |m|
+func (m Money) Abs() Money {
+	return Money{Value: new(big.Rat).Abs(m.rat())}
+}
+
+//Cmp returns -1 if m < n, 0 if m == n and +1 if m > n
+func (m Money) Cmp(n Money) int {
+	return m.rat().Cmp(n.rat())
+}
+
+//Equal returns true if m == n
+func (m Money) Equal(n Money) bool {
+	return m.Cmp(n) == 0
+}
+
+//LessThan returns true if m < n
+func (m Money) LessThan(n Money) bool {
+	return m.Cmp(n) < 0
+}
+
+//GreaterThan returns true if m > n
+func (m Money) GreaterThan(n Money) bool {
+	return m.Cmp(n) > 0
+}
+
+//Sign returns -1 if m < 0, 0 if m == 0 and +1 if m > 0
+func (m Money) Sign() int {
+	return m.rat().Sign()
+}
+
+//IsZero returns true if m == 0
+func (m Money) IsZero() bool {
+	return m.Sign() == 0
+}
+
+//Mid returns the midpoint of bid and ask, or the side that is quoted when the other one is 0. The mid is often not
+//a valid order price, see RoundToTick
+func Mid(bid Money, ask Money) Money {
+	switch {
+	case bid.Sign() <= 0:
+		return ask.Copy()
+	case ask.Sign() <= 0:
+		return bid.Copy()
+	}
+	mid := new(big.Rat).Add(bid.rat(), ask.rat())
+	return Money{Value: mid.Quo(mid, big.NewRat(2, 1))}
+}
+
+//decimal returns m with as few decimal places as it takes to be exact, at least 2 and at most maxDecimalPlaces
+func (m Money) decimal() string {
+	r := m.rat()
+	scaled := new(big.Rat)
+	for places := 2; places < maxDecimalPlaces; places++ {
+		scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+		if scaled.Mul(r, new(big.Rat).SetInt(scale)).IsInt() {
+			return r.FloatString(places)
+		}
+	}
+	return r.FloatString(maxDecimalPlaces)
+}
+
+//MarshalJSON writes m as a JSON number
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.decimal()), nil
+}
+
+//UnmarshalJSON reads m from a JSON number or string. null and "" are 0
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+
+	if s == "null" || s == "" {
+		m.Value = big.NewRat(0, 1)
+		return nil
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return fmt.Errorf("%s: %s", ErrInvalidMoney, data)
+	}
+	m.Value = r
+	return nil
+}
+
+//MarshalXML writes m as the decimal character data of an element
+func (m Money) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(m.decimal(), start)
+}
+
 //UnmarshalXML unmarshal an Money type from an XML message
 func (m *Money) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	var s string
@@ -44,18 +225,16 @@ func (m *Money) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 		return fmt.Errorf("XMLFloat64 should come in as a string, got %s", start)
 	}
 
-	if m.Value == nil {
-		m.Value = big.NewRat(0, 1)
-	}
+	// always a new Rat, m may be a copy sharing its Value with another Money
+	m.Value = big.NewRat(0, 1)
 
 	//logDebug.Printf("s is getting parsed as: :%s:\n", s)
 	if s == "" {
 		return nil
 	}
 
-	var success bool
-	m.Value, success = m.Value.SetString(s)
-	if !success {
+	if _, success := m.Value.SetString(s); !success {
+		m.Value.SetInt64(0)
 		return fmt.Errorf("invalid rat number %s", s)
 	}
 	//logDebug.Printf("m.Value: :%s:\n", m.Value)
@@ -65,7 +244,7 @@ func (m *Money) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 }
 
 func (m Money) String() string {
-	return m.Value.FloatString(2)
+	return m.rat().FloatString(2)
 }
 
 type MoneyArray []Money
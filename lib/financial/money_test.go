@@ -0,0 +1,138 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package financial
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+)
+
+func money(t *testing.T, s string) Money {
+	m, err := ParseMoney(s)
+	if err != nil {
+		t.Fatalf("Error parsing %s: %v", s, err)
+	}
+	return m
+}
+
+func TestArithmetic(t *testing.T) {
+	bid, ask := money(t, "1.20"), money(t, "1.35")
+
+	if mid := Mid(bid, ask); !mid.Equal(money(t, "1.275")) {
+		t.Errorf("mid got %s", mid)
+	}
+	if mid := Mid(Zero(), ask); !mid.Equal(ask) {
+		t.Errorf("mid with no bid got %s", mid)
+	}
+	if v := ask.Sub(bid).Mul(100); !v.Equal(money(t, "15")) {
+		t.Errorf("spread of a contract got %s", v)
+	}
+	if v, err := money(t, "250").Div(200); err != nil || !v.Equal(money(t, "1.25")) {
+		t.Errorf("per share got %s %v", v, err)
+	}
+	if _, err := bid.Div(0); err != ErrDivideByZero {
+		t.Errorf("dividing by 0 got %v", err)
+	}
+	if !bid.LessThan(ask) || ask.Neg().Sign() >= 0 || !(Money{}).IsZero() {
+		t.Errorf("comparisons are wrong")
+	}
+
+	// results never share a Rat with the operands
+	sum := bid.Add(Zero())
+	sum.Value.SetInt64(5)
+	c := ask.Copy()
+	c.Value.SetInt64(5)
+	if !bid.Equal(money(t, "1.20")) || !ask.Equal(money(t, "1.35")) {
+		t.Errorf("operands were modified: %s %s", bid, ask)
+	}
+}
+
+func TestRoundToTick(t *testing.T) {
+	tests := []struct {
+		price    string
+		schedule TickSchedule
+		mode     RoundingMode
+		want     string
+	}{
+		{"1.275", StandardOptionTicks, RoundNearest, "1.30"},
+		{"1.275", StandardOptionTicks, RoundDown, "1.25"},
+		{"1.275", PennyOptionTicks, RoundNearest, "1.28"},
+		{"1.275", PennyOptionTicks, RoundDown, "1.27"},
+		{"2.98", StandardOptionTicks, RoundUp, "3.00"},
+		{"3.14", StandardOptionTicks, RoundNearest, "3.10"},
+		{"3.15", StandardOptionTicks, RoundNearest, "3.20"},
+		{"4.32", PennyOptionTicks, RoundUp, "4.35"},
+		{"-1.275", PennyOptionTicks, RoundNearest, "-1.28"},
+		{"-1.275", PennyOptionTicks, RoundDown, "-1.28"},
+		{"-1.275", PennyOptionTicks, RoundUp, "-1.27"},
+		{"0.51234", EquityTicks, RoundNearest, "0.5123"},
+		{"12.345", EquityTicks, RoundDown, "12.34"},
+	}
+
+	for _, v := range tests {
+		got := money(t, v.price).RoundToTick(v.schedule, v.mode)
+		if !got.Equal(money(t, v.want)) {
+			t.Errorf("%s %s got %s, want %s", v.price, v.mode, got, v.want)
+		}
+		if !v.schedule.IsValid(got) {
+			t.Errorf("%s is not a valid tick", got)
+		}
+	}
+
+	if StandardOptionTicks.IsValid(money(t, "3.05")) || !PennyOptionTicks.IsValid(money(t, "3.05")) {
+		t.Errorf("3.05 is a dime increment in standard and a nickel in penny")
+	}
+	if !PennyAllOptionTicks.IsValid(money(t, "5.23")) || PennyAllOptionTicks.IsValid(money(t, "5.235")) {
+		t.Errorf("penny all options trade in cents at every price")
+	}
+	if r := money(t, "2.345").Round(2); !r.Equal(money(t, "2.35")) {
+		t.Errorf("round got %s", r)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	var v struct {
+		Price Money
+		Fee   Money
+	}
+	if err := json.Unmarshal([]byte(`{"Price": 1.275, "Fee": "0.65"}`), &v); err != nil {
+		t.Fatalf("Error unmarshaling: %v", err)
+	}
+	if !v.Price.Equal(money(t, "1.275")) || !v.Fee.Equal(money(t, "0.65")) {
+		t.Errorf("unmarshaled %s %s", v.Price, v.Fee)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil || string(data) != `{"Price":1.275,"Fee":0.65}` {
+		t.Errorf("marshaled %s %v", data, err)
+	}
+
+	type quote struct {
+		Bid Money `xml:"bid"`
+	}
+	data, err = xml.Marshal(quote{Bid: money(t, "1/3")})
+	if err != nil || string(data) != "<quote><bid>0.33333333</bid></quote>" {
+		t.Errorf("marshaled %s %v", data, err)
+	}
+	var q quote
+	if err := xml.Unmarshal(data, &q); err != nil || !q.Bid.Equal(money(t, "0.33333333")) {
+		t.Errorf("round trip got %s %v", q.Bid, err)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package financial
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+)
+
+//Price is a fixed-point decimal price with PriceDecimals decimal places, ie 12500 is $1.25. It is a plain int64,
+//so unlike Money it never allocates, which matters for market data that is written on every streamed tick and read
+//far more often than it is used in arithmetic. Convert it to Money for anything else
+type Price int64
+
+//Price precision. Sub-penny equity prices are quoted to 4 places
+const (
+	PriceDecimals       = 4
+	PriceScale    Price = 10000
+)
+
+//PriceFromFloat returns f rounded to the nearest Price, half away from 0. NaN, infinities and values out of range
+//are 0. Use PriceFromFloat32 for values that were sent as a float32
+func PriceFromFloat(f float64) Price {
+	scaled := math.Round(f * float64(PriceScale))
+	if math.IsNaN(scaled) || math.Abs(scaled) >= math.MaxInt64 {
+		return 0
+	}
+	return Price(scaled)
+}
+
+//PriceFromFloat32 returns the price that was sent as the float32 f. A float32 only holds about 7 digits, so above
+//$838 it is off from the decimal price by more than half of the last decimal place and rounding f itself can
+//land on the wrong price, ie a bid of 1234.56 is 1234.5601. The shortest decimal that is the same float32 is
+//the price that was sent, so that is what is rounded
+func PriceFromFloat32(f float32) Price {
+	d, err := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'f', -1, 32), 64)
+	if err != nil {
+		return 0
+	}
+	return PriceFromFloat(d)
+}
+
+//PriceFromMoney returns m rounded to the nearest Price, half away from 0. Values out of range are 0
+func PriceFromMoney(m Money) Price {
+	scaled := new(big.Rat).Mul(m.rat(), big.NewRat(int64(PriceScale), 1))
+	q := roundTo(scaled, big.NewRat(1, 1), RoundNearest).Num()
+	if !q.IsInt64() {
+		return 0
+	}
+	return Price(q.Int64())
+}
+
+//Money returns p as Money
+func (p Price) Money() Money {
+	return Money{Value: big.NewRat(int64(p), int64(PriceScale))}
+}
+
+//Float64 returns p as a float64
+func (p Price) Float64() float64 {
+	return float64(p) / float64(PriceScale)
+}
+
+//Sign returns -1 if p < 0, 0 if p == 0 and +1 if p > 0
+func (p Price) Sign() int {
+	switch {
+	case p < 0:
+		return -1
+	case p > 0:
+		return 1
+	}
+	return 0
+}
+
+//MidPrice returns the midpoint of bid and ask rounded half away from 0, or the side that is quoted when the other
+//one is 0
+func MidPrice(bid Price, ask Price) Price {
+	switch {
+	case bid <= 0:
+		return ask
+	case ask <= 0:
+		return bid
+	}
+	sum := bid + ask
+	return sum/2 + sum%2
+}
+
+//String returns p with as few decimal places as it takes to be exact, at least 2, ie 1.25 and 0.5123
+func (p Price) String() string {
+	abs := int64(p)
+	sign := ""
+	if abs < 0 {
+		abs, sign = -abs, "-"
+	}
+
+	whole, frac := abs/int64(PriceScale), abs%int64(PriceScale)
+	places := PriceDecimals
+	for places > 2 && frac%10 == 0 {
+		frac /= 10
+		places--
+	}
+
+	digits := strconv.FormatInt(frac, 10)
+	for len(digits) < places {
+		digits = "0" + digits
+	}
+	return sign + strconv.FormatInt(whole, 10) + "." + digits
+}
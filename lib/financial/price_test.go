@@ -0,0 +1,73 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package financial
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPrice(t *testing.T) {
+	tests := []struct {
+		f    float64
+		want Price
+		s    string
+	}{
+		{float64(float32(1.23)), 12300, "1.23"},
+		{float64(float32(0.05)), 500, "0.05"},
+		{0.51234, 5123, "0.5123"},
+		{-2.5, -25000, "-2.50"},
+		{1.00005, 10001, "1.0001"},
+		{math.NaN(), 0, "0.00"},
+		{math.Inf(1), 0, "0.00"},
+	}
+
+	for _, v := range tests {
+		p := PriceFromFloat(v.f)
+		if p != v.want || p.String() != v.s {
+			t.Errorf("%v got %d %s, want %d %s", v.f, p, p, v.want, v.s)
+		}
+		if back := PriceFromMoney(p.Money()); back != p {
+			t.Errorf("%s round trip through Money got %s", p, back)
+		}
+	}
+
+	// float32 prices above $838 are too coarse to round directly
+	for _, v := range []struct {
+		f    float32
+		want Price
+	}{{1234.56, 12345600}, {4321.12, 43211200}, {838.87, 8388700}, {0.0125, 125}, {float32(math.NaN()), 0}} {
+		if p := PriceFromFloat32(v.f); p != v.want {
+			t.Errorf("float32 %v got %d, want %d", v.f, p, v.want)
+		}
+	}
+
+	if p := PriceFromMoney(Mid(NewMoney(1.20), NewMoney(1.35))); p != 12750 {
+		t.Errorf("mid from Money got %s", p)
+	}
+	if p := MidPrice(12000, 12500); p != 12250 {
+		t.Errorf("mid got %s", p)
+	}
+	if p := MidPrice(0, 12500); p != 12500 {
+		t.Errorf("mid with no bid got %s", p)
+	}
+	if p := MidPrice(12000, 12001); p != 12001 {
+		t.Errorf("odd mid should round half up, got %d", p)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+   AcidBath - framework for your trading
+   Copyright (C) 2016 Mark Laczynski
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package financial
+
+import (
+	"math/big"
+)
+
+//RoundingMode selects the direction prices are rounded to a tick
+type RoundingMode int
+
+//enumeration values for RoundingMode
+const (
+	RoundNearest RoundingMode = iota // nearest tick, half a tick rounds away from 0
+	RoundUp                          // toward +infinity, ie a buyer paying up
+	RoundDown                        // toward -infinity, ie a seller giving up
+)
+
+func (rm RoundingMode) String() string {
+	switch rm {
+	case RoundNearest:
+		return "RoundNearest"
+	case RoundUp:
+		return "RoundUp"
+	case RoundDown:
+		return "RoundDown"
+	}
+	return ""
+}
+
+//TickSchedule is the minimum price increment by price level. Prices below Threshold trade in Below increments,
+//prices at or above it in Above increments
+type TickSchedule struct {
+	Threshold Money
+	Below     Money
+	Above     Money
+}
+
+//Tick schedules of US listed options and equities
+var (
+	//StandardOptionTicks are nickel increments under $3 and dimes at $3 and above
+	StandardOptionTicks = TickSchedule{Threshold: Money{big.NewRat(3, 1)}, Below: Money{big.NewRat(5, 100)}, Above: Money{big.NewRat(10, 100)}}
+	//PennyOptionTicks are the penny program's penny increments under $3 and nickels at $3 and above
+	PennyOptionTicks = TickSchedule{Threshold: Money{big.NewRat(3, 1)}, Below: Money{big.NewRat(1, 100)}, Above: Money{big.NewRat(5, 100)}}
+	//PennyAllOptionTicks are penny increments at every price, as SPY, QQQ and IWM options are quoted. No option
+	//trades in a finer increment
+	PennyAllOptionTicks = TickSchedule{Threshold: Money{big.NewRat(3, 1)}, Below: Money{big.NewRat(1, 100)}, Above: Money{big.NewRat(1, 100)}}
+	//EquityTicks are penny increments at $1 and above and hundredths of a penny below
+	EquityTicks = TickSchedule{Threshold: Money{big.NewRat(1, 1)}, Below: Money{big.NewRat(1, 10000)}, Above: Money{big.NewRat(1, 100)}}
+)
+
+//Tick returns the increment price trades in. The level is the absolute price, so net credits use the same ticks as
+//debits
+func (ts TickSchedule) Tick(price Money) Money {
+	if price.Abs().LessThan(ts.Threshold) {
+		return ts.Below.Copy()
+	}
+	return ts.Above.Copy()
+}
+
+//IsValid returns true if price is a whole number of ticks
+func (ts TickSchedule) IsValid(price Money) bool {
+	ticks := new(big.Rat).Quo(price.rat(), ts.Tick(price).rat())
+	return ticks.IsInt()
+}
+
+//Round returns price rounded to a valid tick in the direction of mode. The threshold is a whole number of both
+//ticks, so a price rounded across it is valid at its new level too
+func (ts TickSchedule) Round(price Money, mode RoundingMode) Money {
+	return Money{roundTo(price.rat(), ts.Tick(price).rat(), mode)}
+}
+
+//RoundToTick returns m rounded to a valid price of ts in the direction of mode,
+//ie financial.Mid(bid, ask).RoundToTick(financial.PennyOptionTicks, financial.RoundDown)
+func (m Money) RoundToTick(ts TickSchedule, mode RoundingMode) Money {
+	return ts.Round(m, mode)
+}
+
+//Round returns m rounded to places decimal places, half away from 0
+func (m Money) Round(places int) Money {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+	return Money{roundTo(m.rat(), new(big.Rat).SetFrac(big.NewInt(1), scale), RoundNearest)}
+}
+
+//roundTo returns r rounded to a multiple of tick
+func roundTo(r *big.Rat, tick *big.Rat, mode RoundingMode) *big.Rat {
+	ticks := new(big.Rat).Quo(r, tick)
+	num, den := ticks.Num(), ticks.Denom()
+
+	// floor division, so the quotient is toward -infinity for negative prices too
+	q, rem := new(big.Int).DivMod(num, den, new(big.Int))
+	if rem.Sign() != 0 {
+		switch mode {
+		case RoundUp:
+			q.Add(q, big.NewInt(1))
+		case RoundNearest:
+			// rem is in [0, den). Round up past half, and at half away from 0
+			twice := new(big.Int).Lsh(rem, 1)
+			if c := twice.Cmp(den); c > 0 || (c == 0 && num.Sign() > 0) {
+				q.Add(q, big.NewInt(1))
+			}
+		}
+	}
+
+	return new(big.Rat).Mul(new(big.Rat).SetInt(q), tick)
+}
@@ -182,6 +182,8 @@ const (
 	OrderRejection       // An order was rejected
 	OrderTooLateToCancel // A request to cancel an order has been received but the order cannot be canceled either because it was already canceled, filled, or for some other reason
 	OrderOut             // Indicates "You Are Out" - that the order has been canceled
+	OrderSubscribed      // The account activity subscription was accepted by the broker
+	OrderError           // The broker sent an error on the account activity stream
 )
 
 func (oe OrderEvent) String() string {
@@ -212,6 +214,32 @@ func (oe OrderEvent) String() string {
 		return "OrderTooLateToCancel"
 	case OrderOut:
 		return "OrderOut"
+	case OrderSubscribed:
+		return "OrderSubscribed"
+	case OrderError:
+		return "OrderError"
+	}
+	return ""
+}
+
+//OrderInstruction represents the side of an order as reported by the brokerage (buy/sell)
+type OrderInstruction int
+
+//enumeration values for OrderInstruction
+const (
+	InvalidOrderInstruction OrderInstruction = iota
+	Buy
+	Sell
+)
+
+func (oi OrderInstruction) String() string {
+	switch oi {
+	case InvalidOrderInstruction:
+		return "Invalid or Unsupported Order Instruction"
+	case Buy:
+		return "buy"
+	case Sell:
+		return "sell"
 	}
 	return ""
 }
@@ -25,16 +25,19 @@ import (
 	"math/big"
 	"net/http"
 	"os"
+	"sync"
 	"text/template"
 
 	"log"
 
 	genericBroker "github.com/marklaczynski/acidbath/broker/generic"
+	"github.com/marklaczynski/acidbath/broker/risk"
 	"github.com/marklaczynski/acidbath/dm/asset"
 	"github.com/marklaczynski/acidbath/dm/optionchain/option"
 	"github.com/marklaczynski/acidbath/dm/order"
 	"github.com/marklaczynski/acidbath/dm/orderbook"
 	"github.com/marklaczynski/acidbath/dm/portfolio"
+	"github.com/marklaczynski/acidbath/dm/strategy"
 	eventProcFactory "github.com/marklaczynski/acidbath/eventproc/factory"
 	"github.com/marklaczynski/acidbath/lib/financial"
 	"github.com/marklaczynski/acidbath/lib/mjlog"
@@ -54,6 +57,7 @@ var (
 	//tmp TODO: clean this up...
 	readyToSendOptUpdatesChan chan bool                                = make(chan bool)
 	userSelectedStock         *asset.Stock                             = nil
+	userSelectedStockMutex    sync.RWMutex // userSelectedStock is updated from the event handlers' goroutines
 	loginFunc                 func(brokerSession genericBroker.Broker) = nil
 )
 
@@ -278,14 +282,18 @@ func ReqOptChainHandler(w http.ResponseWriter, r *http.Request, brokerSession ge
 
 	// snapshot xml
 	// TODO: wrap this function in something like "IsValidSymbol"
-	previousUserSelectedSTock := userSelectedStock
-	userSelectedStock = asset.NewStock(reqOptChainHandlerParams.Symbol)
-	err = brokerSession.RetrieveSnapshot(reqOptChainHandlerParams.Symbol, asset.EquityType, userSelectedStock)
+	// the stock is only shared with the event handlers once it's streaming, and is guarded by userSelectedStockMutex from then on
+	selectedStock := asset.NewStock(reqOptChainHandlerParams.Symbol)
+	err = brokerSession.RetrieveSnapshot(reqOptChainHandlerParams.Symbol, asset.EquityType, selectedStock)
 	if err != nil {
 		logError.Printf("Error Getting Snapshot: %s", err)
 		return fmt.Errorf("Error Getting Snapshot: %s", err)
 	}
 
+	userSelectedStockMutex.RLock()
+	previousUserSelectedSTock := userSelectedStock
+	userSelectedStockMutex.RUnlock()
+
 	if previousUserSelectedSTock != nil {
 		err = brokerSession.RemoveStockOptionsFromStream(previousUserSelectedSTock)
 		if err != nil {
@@ -294,12 +302,16 @@ func ReqOptChainHandler(w http.ResponseWriter, r *http.Request, brokerSession ge
 		}
 	}
 
-	err = brokerSession.AddStockOptionsToStream(userSelectedStock)
+	err = brokerSession.AddStockOptionsToStream(selectedStock)
 	if err != nil {
 		logInfo.Printf("Error calling AddStockOptionsToStream: %s\n", err)
 		return fmt.Errorf("Error calling AddStockOptionsToStream: %s\n", err)
 	}
 
+	userSelectedStockMutex.Lock()
+	defer userSelectedStockMutex.Unlock()
+	userSelectedStock = selectedStock
+
 	type UiOptionType struct {
 		Bid    string
 		Ask    string
@@ -317,9 +329,12 @@ func ReqOptChainHandler(w http.ResponseWriter, r *http.Request, brokerSession ge
 	}
 
 	var optionChain struct {
+		Quote       uiQuoteModel
 		Expirations map[string]expiration
 	}
 
+	optionChain.Quote = newUIQuoteModel(&userSelectedStock.Quote)
+
 	// send data to ui
 	//oc, doneChan := brokerSession.OptionChain()
 	oc := userSelectedStock.OptionChain()
@@ -368,6 +383,58 @@ func ReqOptChainHandler(w http.ResponseWriter, r *http.Request, brokerSession ge
 	return nil
 }
 
+//uiQuoteModel is the equity quote sent to the ui
+type uiQuoteModel struct {
+	Symbol        string
+	Description   string
+	Exchange      string
+	Bid           string
+	Ask           string
+	BidSize       string
+	AskSize       string
+	Last          string
+	LastSize      string
+	Open          string
+	High          string
+	Low           string
+	Close         string
+	Change        string
+	ChangePercent string
+	Volume        string
+	YearHigh      string
+	YearLow       string
+	YearRange     string // where the last trade sits in the 52 week range, 0% at the low
+	RealTime      bool
+}
+
+func newUIQuoteModel(q *asset.Quote) uiQuoteModel {
+	uiQuote := uiQuoteModel{
+		Symbol:        q.Symbol(),
+		Description:   q.Description(),
+		Exchange:      q.Exchange(),
+		Bid:           q.BidPrice().Value.FloatString(2),
+		Ask:           q.AskPrice().Value.FloatString(2),
+		BidSize:       fmt.Sprintf("%d", q.BidSize()),
+		AskSize:       fmt.Sprintf("%d", q.AskSize()),
+		Last:          q.LastTradePrice().Value.FloatString(2),
+		LastSize:      fmt.Sprintf("%d", q.LastSize()),
+		Open:          q.OpenPrice().Value.FloatString(2),
+		High:          q.HighPrice().Value.FloatString(2),
+		Low:           q.LowPrice().Value.FloatString(2),
+		Close:         q.ClosePrice().Value.FloatString(2),
+		Change:        q.Change().Value.FloatString(2),
+		ChangePercent: fmt.Sprintf("%.2f%%", q.ChangePercent()*100),
+		Volume:        fmt.Sprintf("%d", q.Volume()),
+		YearHigh:      q.YearHigh().Value.FloatString(2),
+		YearLow:       q.YearLow().Value.FloatString(2),
+		RealTime:      q.RealTime(),
+	}
+	if position, ok := q.YearRangePosition(); ok {
+		uiQuote.YearRange = fmt.Sprintf("%.0f%%", position*100)
+	}
+	return uiQuote
+}
+
 func PortfolioUpdateEvent(w http.ResponseWriter, r *http.Request, brokerSession genericBroker.Broker) error {
 	f, ok := w.(http.Flusher)
 	if !ok {
@@ -382,9 +449,22 @@ func PortfolioUpdateEvent(w http.ResponseWriter, r *http.Request, brokerSession
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
+	type uiTradeModel struct {
+		Underlying string
+		Strategy   string
+		Quantity   string
+		Delta      string
+		Theta      string
+		Vega       string
+		CostBasis  string
+		MaxLoss    string
+		PnL        string
+	}
+
 	type uiPortfolioModel struct {
 		NetLiquidity      string
 		OptionBuyingPower string
+		Trades            []uiTradeModel
 	}
 
 	portfolioChan := brokerSession.RegisterPortfolioUpdateChan("handler")
@@ -402,6 +482,21 @@ func PortfolioUpdateEvent(w http.ResponseWriter, r *http.Request, brokerSession
 				OptionBuyingPower: fmt.Sprintf("%.2f", recentPortfolio.Balance().OptionBuyingPower()),
 			}
 
+			for _, g := range strategy.Find(recentPortfolio) {
+				pnl := g.PnL
+				uiPortfolio.Trades = append(uiPortfolio.Trades, uiTradeModel{
+					Underlying: g.Underlying,
+					Strategy:   g.Kind.String(),
+					Quantity:   fmt.Sprintf("%.0f", g.Quantity),
+					Delta:      fmt.Sprintf("%.1f", g.Delta),
+					Theta:      fmt.Sprintf("%.2f", g.Theta),
+					Vega:       fmt.Sprintf("%.2f", g.Vega),
+					CostBasis:  fmt.Sprintf("%.2f", g.CostBasis),
+					MaxLoss:    fmt.Sprintf("%.2f", g.MaxLoss),
+					PnL:        fmt.Sprintf("%.2f", pnl.Unrealized+pnl.Realized),
+				})
+			}
+
 			data, err := json.Marshal(uiPortfolio)
 			if err != nil {
 				logError.Printf("Could not marshal recentPortfolio into json\n")
@@ -436,8 +531,15 @@ func OrderUpdateEvent(w http.ResponseWriter, r *http.Request, brokerSession gene
 	w.Header().Set("Connection", "keep-alive")
 
 	type uiOrderMessageModel struct {
-		OrderID    string
-		OrderEvent string
+		OrderID        string
+		OrderEvent     string
+		Symbol         string
+		Instruction    string
+		FillQuantity   string
+		FillPrice      string
+		LeavesQuantity string
+		Charges        string
+		RejectReason   string
 	}
 
 	orderMessageChan := brokerSession.RegisterOrderUpdateChan("handler")
@@ -450,8 +552,15 @@ func OrderUpdateEvent(w http.ResponseWriter, r *http.Request, brokerSession gene
 			logInfo.Printf("Received an orderMessage update %v\n", orderMessage)
 
 			uiOrder := uiOrderMessageModel{
-				OrderID:    orderMessage.OrderID(),
-				OrderEvent: orderMessage.OrderEvent().String(),
+				OrderID:        orderMessage.OrderID(),
+				OrderEvent:     orderMessage.OrderEvent().String(),
+				Symbol:         orderMessage.Symbol(),
+				Instruction:    orderMessage.Instruction().String(),
+				FillQuantity:   fmt.Sprintf("%.0f", orderMessage.FillQuantity()),
+				FillPrice:      orderMessage.FillPrice().Value.FloatString(2),
+				LeavesQuantity: fmt.Sprintf("%.0f", orderMessage.LeavesQuantity()),
+				Charges:        orderMessage.TotalCharges().Value.FloatString(2),
+				RejectReason:   orderMessage.RejectReason(),
 			}
 
 			data, err := json.Marshal(uiOrder)
@@ -473,6 +582,53 @@ func OrderUpdateEvent(w http.ResponseWriter, r *http.Request, brokerSession gene
 	return nil
 }
 
+func QuoteUpdateEvent(w http.ResponseWriter, r *http.Request, brokerSession genericBroker.Broker) error {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		logError.Printf("Error with Serve HTTP")
+		http.Error(w, "Streaming unsupported! make better handling in future", http.StatusInternalServerError)
+		return nil
+	}
+
+	conClosedNotification := w.(http.CloseNotifier).CloseNotify()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	quoteChan := brokerSession.RegisterQuoteUpdateChan("handler")
+
+	// If I don't include this for loop, then i get an error on the web browser. I think it's because the connection gets lost if this routine ends
+	logDebug.Printf("starting for loop in QuoteUpdateEvent\n")
+	for {
+		select {
+		case q := <-quoteChan:
+			logInfo.Printf("Received a quote update %v\n", q.Symbol())
+
+			//update local dm, the broker sends the whole quote with the streamed fields merged in
+			userSelectedStockMutex.Lock()
+			if userSelectedStock != nil && userSelectedStock.Symbol() == q.Symbol() {
+				userSelectedStock.Quote = *q
+			}
+			userSelectedStockMutex.Unlock()
+
+			data, err := json.Marshal(newUIQuoteModel(q))
+			if err != nil {
+				logError.Printf("Could not marshal quote into json\n")
+				return errors.New("Could not marshal quote into json\n")
+			}
+			logDebug.Printf("data:%s\n", data)
+
+			//"data:" must the the first thing sent (part of the SSE contract) and ended with 2 newlines \n\n
+			fmt.Fprintf(w, "data:%s\n\n", data)
+			f.Flush()
+		case <-conClosedNotification:
+			logDebug.Printf("Quote HTTP Connection closed\n")
+			return nil
+		}
+	}
+}
+
 func OptionUpdateEvent(w http.ResponseWriter, r *http.Request, brokerSession genericBroker.Broker) error {
 	f, ok := w.(http.Flusher)
 	if !ok {
@@ -507,10 +663,12 @@ func OptionUpdateEvent(w http.ResponseWriter, r *http.Request, brokerSession gen
 		case ready = <-readyToSendOptUpdatesChan:
 		case o := <-optionChan:
 			//update local dm
+			userSelectedStockMutex.Lock()
 			if userSelectedStock != nil && userSelectedStock.OptionChain() != nil {
-				userSelectedStock.OptionChain().Option(o.OptionTickerSymbol()).SetBid(o.Bid())
-				userSelectedStock.OptionChain().Option(o.OptionTickerSymbol()).SetAsk(o.Ask())
+				userSelectedStock.OptionChain().Option(o.OptionTickerSymbol()).SetBidPrice(o.BidPrice())
+				userSelectedStock.OptionChain().Option(o.OptionTickerSymbol()).SetAskPrice(o.AskPrice())
 			}
+			userSelectedStockMutex.Unlock()
 
 			//send update to ui dm
 			if ready == true {
@@ -570,7 +728,11 @@ func TrackOptionHandler(w http.ResponseWriter, r *http.Request, brokerSession ge
 	}
 
 	logInfo.Printf("tracking: %s \n", trackOptionParam.Symbol)
-	list, _ := brokerSession.AddOptionToStrategy(userSelectedStock.OptionChain().Option(trackOptionParam.Symbol), eventProcFactory.Reference)
+	userSelectedStockMutex.RLock()
+	opt := userSelectedStock.OptionChain().Option(trackOptionParam.Symbol)
+	userSelectedStockMutex.RUnlock()
+
+	list, _ := brokerSession.AddOptionToStrategy(opt, eventProcFactory.Reference)
 
 	var instrumentList struct {
 		Instrument []string
@@ -608,7 +770,11 @@ func UntrackOptionHandler(w http.ResponseWriter, r *http.Request, brokerSession
 	}
 
 	logInfo.Printf("tracking: %s \n", trackOptionParam.Symbol)
-	list, _ := brokerSession.RemoveOptionFromStrategy(userSelectedStock.OptionChain().Option(trackOptionParam.Symbol), eventProcFactory.Reference)
+	userSelectedStockMutex.RLock()
+	opt := userSelectedStock.OptionChain().Option(trackOptionParam.Symbol)
+	userSelectedStockMutex.RUnlock()
+
+	list, _ := brokerSession.RemoveOptionFromStrategy(opt, eventProcFactory.Reference)
 
 	var instrumentList struct {
 		Instrument []string
@@ -624,9 +790,8 @@ func UntrackOptionHandler(w http.ResponseWriter, r *http.Request, brokerSession
 	return nil
 }
 
-func TestOrderHandler(w http.ResponseWriter, r *http.Request, brokerSession genericBroker.Broker) error {
-	logInfo.Printf("TestOrderHandler\n")
-
+//testOrder is the order sent and previewed from the sandbox
+func testOrder() *order.Order {
 	order := order.New()
 
 	order.SetAction(orderconst.SellToOpen)
@@ -637,13 +802,71 @@ func TestOrderHandler(w http.ResponseWriter, r *http.Request, brokerSession gene
 	//TODO: fix this up this is hardcoded for right now, which is fine, but after 06/15/2018 this will stop working.
 	order.SetSymbol("SPY_061518P100")
 
+	return order
+}
+
+func TestPreviewOrderHandler(w http.ResponseWriter, r *http.Request, brokerSession genericBroker.Broker) error {
+	logInfo.Printf("TestPreviewOrderHandler\n")
+
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+
+	var previewResponse struct {
+		Preview string  `json:"preview"`
+		Fees    float64 `json:"fees"`
+		Net     float64 `json:"net"`
+		Error   string  `json:"error"`
+	}
+
+	preview, err := brokerSession.PreviewOrder(testOrder())
+	if err != nil {
+		logError.Printf("Error previewing order: %s\n", err)
+		previewResponse.Error = fmt.Sprintf("Error previewing order: %s", err)
+	} else {
+		previewResponse.Preview = preview.String()
+		previewResponse.Fees = preview.Fees.Total()
+		previewResponse.Net = preview.Net
+	}
+
+	if err := json.NewEncoder(w).Encode(previewResponse); err != nil {
+		logError.Printf("System error: %s", err)
+		return err
+	}
+
+	return err
+}
+
+func TestOrderHandler(w http.ResponseWriter, r *http.Request, brokerSession genericBroker.Broker) error {
+	logInfo.Printf("TestOrderHandler\n")
+
+	order := testOrder()
+
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+
+	// risk rejections carry the rule that failed, so the ui can show why the order wasn't sent
+	var orderResponse struct {
+		Rule  string `json:"rule"`
+		Error string `json:"error"`
+	}
+
 	err := brokerSession.SendSingleLegOptionTrade(order)
 	if err != nil {
-		logError.Printf("Error sending order")
-		return errors.New("Error sending order")
+		logError.Printf("Error sending order: %s\n", err)
+		if v, ok := err.(*risk.Violation); ok {
+			orderResponse.Rule = v.Rule.String()
+			orderResponse.Error = v.Reason
+		} else {
+			orderResponse.Error = fmt.Sprintf("Error sending order: %s", err)
+		}
 	}
 
-	return nil
+	if err := json.NewEncoder(w).Encode(orderResponse); err != nil {
+		logError.Printf("System error: %s", err)
+		return err
+	}
+
+	return err
 }
 
 func TestCancelOrderHandler(w http.ResponseWriter, r *http.Request, brokerSession genericBroker.Broker) error {
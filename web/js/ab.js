@@ -34,6 +34,7 @@ app.controller('mainController', ['$scope', 'userAuth', '$http', function($scope
 	$scope.loginDisabled = false;
 	$scope.logoutDisabled = false;
 	$scope.optionChain = null;
+	$scope.quote = null;
 	$scope.trackedOptions = null;
 
 	$scope.login = function() {
@@ -80,7 +81,15 @@ app.controller('mainController', ['$scope', 'userAuth', '$http', function($scope
 	};
 
 	$scope.testOrder = function() {
-		$http.post('/testOrderHandler', {})
+		$http.post('/testOrderHandler', {}).then(function(resp) {
+			$scope.orderRejection = resp.data;
+		});
+	};
+
+	$scope.previewOrder = function() {
+		$http.post('/testPreviewOrderHandler', {}).then(function(resp) {
+			$scope.orderPreview = resp.data;
+		});
 	};
 
 	$scope.cancelOrder = function() {
@@ -93,6 +102,7 @@ app.controller('mainController', ['$scope', 'userAuth', '$http', function($scope
 		).then(function(resp) {
 			//console.log(resp.data);
 			$scope.optionChain = resp.data;
+			$scope.quote = resp.data.Quote;
 			$http.post('/releaseOptionUpdatesEvents', {});
 		});
 	};
@@ -192,6 +202,20 @@ app.controller('mainController', ['$scope', 'userAuth', '$http', function($scope
 			var bnp = JSON.parse(e.data)
 			$scope.optBuyingPower = bnp.OptionBuyingPower;
 			$scope.netLiq = bnp.NetLiquidity;
+			$scope.trades = bnp.Trades;
+		};
+
+		// Create HTML5 EventSource for quote update event
+		var quoteUpdateEvent = new EventSource('/quoteUpdateEvent');
+
+		quoteUpdateEvent.onmessage = function(e) {
+			//console.log(e.data)
+			var quote = JSON.parse(e.data);
+
+			if ($scope.quote != null && $scope.quote.Symbol == quote.Symbol) {
+				$scope.quote = quote;
+				$scope.$apply();
+			}
 		};
 
 		// Create HTML5 EventSource for option update event